@@ -0,0 +1,168 @@
+// import-users : import users with pre-hashed passwords from another
+// system into the SQLite database of a SQLUserStore.
+//
+// The input file is either a JSON array or a CSV file with a header row.
+// Both use the fields id, user_name, format, hash, salt, iterations and
+// status of auth.ImportedUser, e.g.
+//
+//	user_name,format,hash,salt,iterations
+//	peter,pbkdf2-sha256,5d41402abc4b2a76b9719d911017c592...,s4lt,10000
+//
+// Usage:
+//
+//	import-users -database users.db -input users.csv [-report report.json]
+package main
+
+import (
+	"database/sql"
+	"encoding/csv"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/mezorian/go-auth-example/pkg/auth"
+	_ "modernc.org/sqlite"
+)
+
+func main() {
+	databasePath := flag.String("database", "", "path of the SQLite database of the users")
+	inputPath := flag.String("input", "", "path of the JSON or CSV file with the users to import")
+	reportPath := flag.String("report", "", "optional path to which the report is written as JSON")
+	flag.Parse()
+
+	if *databasePath == "" || *inputPath == "" {
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	if error := run(*databasePath, *inputPath, *reportPath, os.Stdout); error != nil {
+		fmt.Fprintln(os.Stderr, error)
+		os.Exit(1)
+	}
+}
+
+// import the users of the input file and print the report
+func run(databasePath string, inputPath string, reportPath string, output io.Writer) (error error) {
+	importedUsers, error := readImportedUsers(inputPath)
+	if error != nil {
+		return error
+	}
+
+	db, error := sql.Open("sqlite", "file:"+databasePath+"?_pragma=busy_timeout(5000)")
+	if error != nil {
+		return error
+	}
+	defer db.Close()
+
+	userStore, error := auth.NewSQLUserStore(db)
+	if error != nil {
+		return error
+	}
+
+	// the report is printed even if the import failed in between
+	report, importError := auth.ImportUsers(userStore, importedUsers)
+	printReport(output, report)
+	if reportPath != "" {
+		if error = writeReport(reportPath, report); error != nil {
+			return error
+		}
+	}
+
+	return importError
+}
+
+// read the users from a JSON or CSV file depending on its extension
+func readImportedUsers(path string) (importedUsers []auth.ImportedUser, error error) {
+	content, error := os.ReadFile(path)
+	if error != nil {
+		return nil, error
+	}
+
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".json":
+		error = json.Unmarshal(content, &importedUsers)
+		return importedUsers, error
+	case ".csv":
+		return parseCSV(content)
+	default:
+		return nil, fmt.Errorf("Error : Unknown input format of '%s'. Please use a .json or .csv file!", path)
+	}
+}
+
+// parse a CSV file whose header row names the fields of the users
+func parseCSV(content []byte) (importedUsers []auth.ImportedUser, error error) {
+	records, error := csv.NewReader(strings.NewReader(string(content))).ReadAll()
+	if error != nil {
+		return nil, error
+	}
+	if len(records) == 0 {
+		return nil, nil
+	}
+
+	header := records[0]
+	for lineNumber, record := range records[1:] {
+		var importedUser auth.ImportedUser
+		for i, column := range header {
+			value := record[i]
+			switch strings.TrimSpace(column) {
+			case "id":
+				importedUser.ID = value
+			case "user_name":
+				importedUser.UserName = value
+			case "format":
+				importedUser.Format = value
+			case "hash":
+				importedUser.Hash = value
+			case "salt":
+				importedUser.Salt = value
+			case "iterations":
+				if value != "" {
+					importedUser.Iterations, error = strconv.Atoi(value)
+					if error != nil {
+						return nil, fmt.Errorf("Error : Invalid iterations '%s' in line %d!", value, lineNumber+2)
+					}
+				}
+			case "status":
+				importedUser.Status = auth.UserStatus(value)
+			default:
+				return nil, fmt.Errorf("Error : Unknown column '%s'!", column)
+			}
+		}
+		importedUsers = append(importedUsers, importedUser)
+	}
+
+	return importedUsers, nil
+}
+
+// print the user names of the report grouped by their result
+func printReport(output io.Writer, report *auth.ImportReport) {
+	fmt.Fprintf(output, "imported: %d\n", len(report.Imported))
+	for _, userName := range report.Imported {
+		fmt.Fprintf(output, "  %s\n", userName)
+	}
+
+	fmt.Fprintf(output, "conflicting: %d\n", len(report.Conflicting))
+	for _, userName := range report.Conflicting {
+		fmt.Fprintf(output, "  %s\n", userName)
+	}
+
+	fmt.Fprintf(output, "skipped: %d\n", len(report.Skipped))
+	for _, problem := range report.Skipped {
+		fmt.Fprintf(output, "  %s : %s\n", problem.UserName, problem.Reason)
+	}
+}
+
+// write the report as JSON file
+func writeReport(path string, report *auth.ImportReport) (error error) {
+	content, error := json.MarshalIndent(report, "", "  ")
+	if error != nil {
+		return error
+	}
+
+	return os.WriteFile(path, content, 0644)
+}
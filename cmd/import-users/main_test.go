@@ -0,0 +1,153 @@
+package main
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/mezorian/go-auth-example/pkg/auth"
+	"github.com/stretchr/testify/assert"
+)
+
+// hash of a password in the format auth.HashFormatSHA256Salted
+func sha256Hash(salt string, password string) string {
+	digest := sha256.Sum256([]byte(salt + password))
+	return hex.EncodeToString(digest[:])
+}
+
+// write the content to a file with the given name in a temporary directory
+func writeInputFile(t *testing.T, name string, content string) string {
+	path := filepath.Join(t.TempDir(), name)
+	assert.Equal(t, nil, os.WriteFile(path, []byte(content), 0644))
+
+	return path
+}
+
+func TestParseCSV(t *testing.T) {
+	testCaseValues := []struct {
+		name          string
+		content       string
+		importedUsers []auth.ImportedUser
+		errorMessage  string
+	}{
+		{"valid rows", "id,user_name,format,hash,salt,iterations,status\n1,peter,pbkdf2-sha256,abc,s4lt,10000,pending\n2,anna,sha256-salted,def,salt,,\n", []auth.ImportedUser{
+			{ID: "1", UserName: "peter", Format: "pbkdf2-sha256", Hash: "abc", Salt: "s4lt", Iterations: 10000, Status: auth.StatusPending},
+			{ID: "2", UserName: "anna", Format: "sha256-salted", Hash: "def", Salt: "salt"},
+		}, ""},
+		{"columns in any order", "hash, user_name\nabc,peter\n", []auth.ImportedUser{{UserName: "peter", Hash: "abc"}}, ""},
+		{"empty file", "", nil, ""},
+		{"header only", "user_name,hash\n", nil, ""},
+		{"unknown column", "user_name,password\npeter,supersecret\n", nil, "Error : Unknown column 'password'!"},
+		{"invalid iterations", "user_name,iterations\npeter,10000\nanna,many\n", nil, "Error : Invalid iterations 'many' in line 3!"},
+		{"missing field", "user_name,hash\npeter\n", nil, "record on line 2: wrong number of fields"},
+	}
+
+	for _, testCaseValue := range testCaseValues {
+		importedUsers, error := parseCSV([]byte(testCaseValue.content))
+		if testCaseValue.errorMessage == "" {
+			assert.Equal(t, nil, error, testCaseValue.name)
+		} else {
+			assert.Equal(t, testCaseValue.errorMessage, error.Error(), testCaseValue.name)
+		}
+		assert.Equal(t, testCaseValue.importedUsers, importedUsers, testCaseValue.name)
+	}
+}
+
+func TestReadImportedUsers(t *testing.T) {
+	testCaseValues := []struct {
+		fileName      string
+		content       string
+		importedUsers []auth.ImportedUser
+		errorMessage  string
+	}{
+		{"users.json", `[{"id":"1","user_name":"peter","format":"pbkdf2-sha256","hash":"abc","salt":"s4lt","iterations":10000,"status":"pending"}]`,
+			[]auth.ImportedUser{{ID: "1", UserName: "peter", Format: "pbkdf2-sha256", Hash: "abc", Salt: "s4lt", Iterations: 10000, Status: auth.StatusPending}}, ""},
+		{"USERS.JSON", `[{"user_name":"peter"}]`, []auth.ImportedUser{{UserName: "peter"}}, ""},
+		{"users.json", `[]`, []auth.ImportedUser{}, ""},
+		{"users.json", `{"user_name":"peter"}`, nil, "json: cannot unmarshal object into Go value of type []auth.ImportedUser"},
+		{"users.csv", "user_name\npeter\n", []auth.ImportedUser{{UserName: "peter"}}, ""},
+		{"users.txt", "peter", nil, "Error : Unknown input format of '"},
+	}
+
+	for _, testCaseValue := range testCaseValues {
+		importedUsers, error := readImportedUsers(writeInputFile(t, testCaseValue.fileName, testCaseValue.content))
+		if testCaseValue.errorMessage == "" {
+			assert.Equal(t, nil, error, testCaseValue.fileName)
+			assert.Equal(t, testCaseValue.importedUsers, importedUsers, testCaseValue.fileName)
+		} else {
+			assert.Contains(t, error.Error(), testCaseValue.errorMessage, testCaseValue.fileName)
+		}
+	}
+
+	_, error := readImportedUsers(filepath.Join(t.TempDir(), "missing.csv"))
+	assert.ErrorIs(t, error, os.ErrNotExist)
+}
+
+func TestRun(t *testing.T) {
+	hash := sha256Hash("salt", "supersecret")
+	testCaseValues := []struct {
+		name         string
+		fileName     string
+		content      string
+		report       auth.ImportReport
+		errorMessage string
+	}{
+		{"valid rows", "users.csv", "id,user_name,format,hash,salt,status\n1,peter,sha256-salted," + hash + ",salt,\n2,anna,sha256-salted," + hash + ",salt,pending\n",
+			auth.ImportReport{Imported: []string{"peter", "anna"}, Skipped: []auth.ImportProblem{}, Conflicting: []string{}}, ""},
+		{"valid json", "users.json", `[{"user_name":"peter","format":"sha256-salted","hash":"` + hash + `","salt":"salt"}]`,
+			auth.ImportReport{Imported: []string{"peter"}, Skipped: []auth.ImportProblem{}, Conflicting: []string{}}, ""},
+		{"unknown status", "users.csv", "user_name,format,hash,salt,status\npeter,sha256-salted," + hash + ",salt,sleeping\n",
+			auth.ImportReport{Imported: []string{}, Skipped: []auth.ImportProblem{{UserName: "peter", Reason: "Error : Unknown user status 'sleeping'!"}}, Conflicting: []string{}}, ""},
+		{"duplicate users", "users.csv", "id,user_name,format,hash,salt\n1,peter,sha256-salted," + hash + ",salt\n2,peter,sha256-salted," + hash + ",salt\n1,anna,sha256-salted," + hash + ",salt\n",
+			auth.ImportReport{Imported: []string{"peter"}, Skipped: []auth.ImportProblem{}, Conflicting: []string{"peter", "anna"}}, ""},
+		{"bad header", "users.csv", "user_name,password\npeter,supersecret\n", auth.ImportReport{}, "Error : Unknown column 'password'!"},
+	}
+
+	for _, testCaseValue := range testCaseValues {
+		directory := t.TempDir()
+		databasePath := filepath.Join(directory, "users.db")
+		reportPath := filepath.Join(directory, "report.json")
+		var output bytes.Buffer
+
+		error := run(databasePath, writeInputFile(t, testCaseValue.fileName, testCaseValue.content), reportPath, &output)
+		if testCaseValue.errorMessage != "" {
+			assert.Equal(t, testCaseValue.errorMessage, error.Error(), testCaseValue.name)
+			assert.Equal(t, "", output.String(), testCaseValue.name)
+			continue
+		}
+		assert.Equal(t, nil, error, testCaseValue.name)
+
+		// the report is printed and written as JSON
+		assert.Contains(t, output.String(), "imported: ", testCaseValue.name)
+		content, error := os.ReadFile(reportPath)
+		assert.Equal(t, nil, error, testCaseValue.name)
+		var report auth.ImportReport
+		assert.Equal(t, nil, json.Unmarshal(content, &report), testCaseValue.name)
+		assert.Equal(t, testCaseValue.report, report, testCaseValue.name)
+
+		// the imported users are stored in the database
+		db, error := sql.Open("sqlite", "file:"+databasePath)
+		assert.Equal(t, nil, error)
+		userStore, error := auth.NewSQLUserStore(db)
+		assert.Equal(t, nil, error)
+		users, error := userStore.ListUsers()
+		assert.Equal(t, nil, error)
+		assert.Equal(t, len(testCaseValue.report.Imported), len(users), testCaseValue.name)
+		db.Close()
+	}
+}
+
+func TestRunPrintsReport(t *testing.T) {
+	hash := sha256Hash("salt", "supersecret")
+	inputPath := writeInputFile(t, "users.csv", "user_name,format,hash,salt\npeter,sha256-salted,"+hash+",salt\npeter,sha256-salted,"+hash+",salt\nanna,md5,"+hash+",salt\n")
+	var output bytes.Buffer
+
+	error := run(filepath.Join(t.TempDir(), "users.db"), inputPath, "", &output)
+	assert.Equal(t, nil, error)
+	assert.Equal(t, "imported: 1\n  peter\nconflicting: 1\n  peter\nskipped: 1\n  anna : Error : Unknown hash format 'md5'!\n", output.String())
+}
@@ -1,332 +1,612 @@
 package auth
 
 import (
-	"os"
+	"errors"
+	"strconv"
+	"sync"
+	"time"
 
-	jwt "github.com/dgrijalva/jwt-go"
 	"github.com/google/uuid"
+	log "github.com/sirupsen/logrus"
 	"golang.org/x/crypto/bcrypt"
 )
 
+// AuthHandler : sign up, log in and authenticate users. An AuthHandler
+// is safe for concurrent use, e.g. from several net/http handlers.
 type AuthHandler struct {
-	passwordRuleRegex string
-	userNameRuleRegex string
-	UsersByID         map[string]*User
-	userIDsByUserName map[string]*string
+	userNameRules     []Rule
+	passwordRules     []Rule
+	passwordPolicy    *PasswordPolicy
+	passwordHasher    PasswordHasher
+	rulesMutex        sync.RWMutex
+	userStore         UserStore
+	refreshTokenStore RefreshTokenStore
+	sessionStore      SessionStore
+	revocationStore   RevocationStore
+	oneTimeTokenStore OneTimeTokenStore
+	notifier          Notifier
+	tokenConfig       tokenConfig
+	configMutex       sync.RWMutex
+
+	// receives all returned errors, nil if they are not logged
+	logger      log.FieldLogger
+	loggerMutex sync.RWMutex
+
+	// serializes checking the user name and adding the new user
+	// to the user store, so that a user name can not be taken
+	// twice by concurrent sign ups
+	signUpMutex sync.Mutex
+
+	// time of the last removal of expired entries from the revocation list
+	lastRevocationPrune  time.Time
+	revocationPruneMutex sync.Mutex
+
+	// time of the last removal of expired sessions
+	lastSessionPrune  time.Time
+	sessionPruneMutex sync.Mutex
+
+	// time of the last removal of expired refresh tokens
+	lastRefreshTokenPrune  time.Time
+	refreshTokenPruneMutex sync.Mutex
+
+	// time of the last removal of expired one-time tokens
+	lastOneTimeTokenPrune  time.Time
+	oneTimeTokenPruneMutex sync.Mutex
+
+	// time at which the SecretProvider was asked for a changed secret
+	lastSecretReload  time.Time
+	secretReloadMutex sync.Mutex
+
+	// hides which user names exist, guarded by the rulesMutex
+	enumerationProtection bool
+
+	// refuses logins without verified email address, guarded by the rulesMutex
+	emailVerificationRequired bool
+
+	// limits the logins, nil if they are not limited. Guarded by the rulesMutex
+	rateLimiter *RateLimiter
+
+	// hash of a random password, which is verified for unknown users
+	dummyHash      string
+	dummyHashMutex sync.Mutex
 }
 
-func NewAuthHandler() *AuthHandler {
-	authH := new(AuthHandler)
-	authH.passwordRuleRegex = ""
-	authH.userNameRuleRegex = ""
-	authH.UsersByID = make(map[string]*User)
-	authH.userIDsByUserName = make(map[string]*string)
+// NewAuthHandler : Create a new AuthHandler which keeps its users in
+// memory unless WithUserStore is given. A secret, a signing key or a key
+// ring has to be given, so that a missing or weak secret is reported here
+// and not on every login. The error wraps ErrNoSecret or ErrInvalidKey then.
+func NewAuthHandler(options ...Option) (authH *AuthHandler, error error) {
+	authH = new(AuthHandler)
+	authH.userStore = NewInMemoryUserStore()
+	authH.passwordHasher = NewBcryptHasher()
+	authH.refreshTokenStore = NewInMemoryRefreshTokenStore()
+	authH.sessionStore = NewInMemorySessionStore()
+	authH.revocationStore = NewInMemoryRevocationStore()
+	authH.oneTimeTokenStore = NewInMemoryOneTimeTokenStore()
+	authH.logger = log.StandardLogger()
+	authH.tokenConfig.accessTokenLifetime = DefaultAccessTokenLifetime
+	authH.tokenConfig.refreshTokenLifetime = DefaultRefreshTokenLifetime
+	authH.tokenConfig.passwordResetTokenLifetime = DefaultPasswordResetTokenLifetime
+	authH.tokenConfig.emailVerificationTokenLifetime = DefaultEmailVerificationTokenLifetime
+	authH.tokenConfig.clock = SystemClock{}
+	authH.tokenConfig.allowedAlgorithms = append([]string(nil), DefaultAllowedAlgorithms...)
+
+	for _, option := range options {
+		if error = option(authH); error != nil {
+			return nil, error
+		}
+	}
+
+	if authH.tokenConfig.keyRing == nil {
+		return nil, authH.newError(ErrNoSecret, "Error : No Secret or signing key for JWT generation set! Please use WithSecret, WithSecretProvider, WithSigningKey or WithKeyRing.")
+	}
+
+	return authH, nil
+}
+
+// SetLogger : Set the logger to which all returned errors are written.
+// Setting nil disables logging. Can be called while the AuthHandler is in use.
+func (a *AuthHandler) SetLogger(logger log.FieldLogger) {
+	a.loggerMutex.Lock()
+	defer a.loggerMutex.Unlock()
+
+	a.logger = logger
+}
 
-	return authH
+// get the logger to which the errors are written
+func (a *AuthHandler) getLogger() log.FieldLogger {
+	a.loggerMutex.RLock()
+	defer a.loggerMutex.RUnlock()
+
+	return a.logger
+}
+
+// create a new error of the given kind and write it to the log
+func (a *AuthHandler) newError(kind error, errorMessage string) error {
+	return a.logError(newError(kind, errorMessage))
+}
+
+// write an error, e.g. from the user store, to the log
+func (a *AuthHandler) logError(error error) error {
+	return logError(a.getLogger(), error)
+}
+
+// GetUserStore : Get the UserStore which is used by the AuthHandler
+func (a *AuthHandler) GetUserStore() UserStore {
+	return a.userStore
 }
 
 // GetUserByUserName : Get user struct by user name
 func (a *AuthHandler) GetUserByUserName(userName string) (user *User, error error) {
+	user, error = a.userStore.GetUserByUserName(userName)
+	return user, a.logError(error)
+}
 
-	if userID, userIDFound := a.userIDsByUserName[userName]; userIDFound {
-		user = a.UsersByID[*userID]
-		if user == nil {
-			error = LogNewError("Error : No user found for ID : '" + *userID + "' !")
+// maxUserUpdateAttempts : how often updateUser applies a change again if
+// the user was changed concurrently
+const maxUserUpdateAttempts = 5
+
+// userUpdate : change of a stored user which is applied by updateUser. It
+// gets a copy of the current user and may be called again if the user was
+// changed concurrently, so it must not have side effects.
+type userUpdate func(user *User) (error error)
+
+// apply the change to the current state of the user and store it, so that
+// concurrent changes of other fields are not overwritten. Returns the user
+// before and after the change.
+func (a *AuthHandler) updateUser(userID string, update userUpdate) (oldUser *User, user *User, error error) {
+	for attempt := 0; attempt < maxUserUpdateAttempts; attempt++ {
+		oldUser, error = a.userStore.GetUserByID(userID)
+		if error != nil {
+			return nil, nil, a.logError(error)
+		}
+
+		user = oldUser.copy()
+		if error = update(user); error != nil {
+			return nil, nil, error
+		}
+
+		error = a.userStore.CompareAndUpdateUser(oldUser, user)
+		if !errors.Is(error, ErrConflict) {
+			break
 		}
-	} else {
-		error = LogNewError("Error : No user found for UserName : '" + userName + "' !")
+	}
+	if error != nil {
+		return nil, nil, a.logError(error)
 	}
 
-	return user, error
+	return oldUser, user, nil
+}
+
+// AddUserRule : Add a regex rule which every new user name has to match.
+// The regex is compiled once here, an invalid regex returns an *InvalidRuleError.
+func (a *AuthHandler) AddUserRule(regex string) (error error) {
+	rule, error := NewRegexRule("Username", regex)
+	if error == nil {
+		a.AddCustomUserRule(rule)
+	}
+
+	return a.logError(error)
+}
+
+// AddPasswordRule : Add a regex rule which every new password has to match.
+// The regex is compiled once here, an invalid regex returns an *InvalidRuleError.
+// Use NewAllOfRegexRule for rules which would need lookahead assertions.
+func (a *AuthHandler) AddPasswordRule(regex string) (error error) {
+	rule, error := NewRegexRule("Password", regex)
+	if error == nil {
+		a.AddCustomPasswordRule(rule)
+	}
+
+	return a.logError(error)
+}
+
+// AddCustomUserRule : Add an arbitrary rule which every new user name has to pass
+func (a *AuthHandler) AddCustomUserRule(rule Rule) {
+	a.rulesMutex.Lock()
+	defer a.rulesMutex.Unlock()
 
+	a.userNameRules = append(a.userNameRules, rule)
 }
 
-// TODO : from here --> continue with User and password rule
-
-// func (a *AuthHandler) AddUserRule(regex string) {
-// 	a.userNameRuleRegex = regex
-// }
-//
-// func (a *AuthHandler) AddPasswordRule(regex string) {
-// 	a.passwordRuleRegex = regex
-// }
-//
-// // check if username parameter is a valid username
-// func (a *AuthHandler) CheckUserNameRule(username string) (successful bool, error string) {
-// 	return a.CheckRegexRule(a.userNameRuleRegex, username, "UserName")
-// }
-//
-// // check if password parameter is a valid password
-// func (a *AuthHandler) CheckPasswordRule(password string) (successful bool, error string) {
-// 	return a.CheckRegexRule(a.passwordRuleRegex, password, "Password")
-// }
-//
-// // Check if a value is matching a given regular expression
-// func (a *AuthHandler) CheckRegexRule(regex string, value string, nameOfRule string) (successful bool, error string) {
-//
-// 	// try to match value string againts regex
-// 	matched, err := regexp.MatchString(regex, value)
-//
-// 	// check if regex is a valid regular expression
-// 	if err != nil {
-// 		print("1\n")
-// 		successful = false
-// 		error = "Error : Cannot apply invalid regex rule for " + nameOfRule + " : " + err.Error()
-// 		// check if value matched with regex
-// 	} else if matched {
-// 		print("2\n")
-// 		successful = true
-// 		error = ""
-// 		// check if value does not match with regex
-// 	} else {
-// 		print("3\n" + regex + "xxx" + value)
-// 		successful = false
-// 		error = "Error : " + nameOfRule + " does not comply to rules. Please make sure it fits to the following regular expression : " + regex
-// 	}
-//
-// 	return successful, error
-// }
-
-// TODO : to here --> continue with User and password rule
-
-// CheckIfUserNameIsFree : check if user name is not used yet
-func (a *AuthHandler) CheckIfUserNameIsFree(userName string) (successful bool, error error) {
+// AddCustomPasswordRule : Add an arbitrary rule which every new password has to pass
+func (a *AuthHandler) AddCustomPasswordRule(rule Rule) {
+	a.rulesMutex.Lock()
+	defer a.rulesMutex.Unlock()
+
+	a.passwordRules = append(a.passwordRules, rule)
+}
+
+// CheckUserNameRule : check if username parameter is a valid username
+func (a *AuthHandler) CheckUserNameRule(userName string) (error error) {
+	a.rulesMutex.RLock()
+	defer a.rulesMutex.RUnlock()
+
+	return a.logError(checkRules(a.userNameRules, userName))
+}
+
+// CheckPasswordRule : check if password parameter is a valid password
+func (a *AuthHandler) CheckPasswordRule(password string) (error error) {
+	a.rulesMutex.RLock()
+	defer a.rulesMutex.RUnlock()
+
+	return a.logError(checkRules(a.passwordRules, password))
+}
+
+// SetPasswordPolicy : Set the policy which is evaluated for every new password
+func (a *AuthHandler) SetPasswordPolicy(policy *PasswordPolicy) {
+	a.rulesMutex.Lock()
+	defer a.rulesMutex.Unlock()
+
+	a.passwordPolicy = policy
+}
+
+// SetPasswordHasher : Set the algorithm with which new passwords are
+// hashed. Hashes of bcrypt, Argon2id, scrypt and the imported legacy
+// formats can still be verified afterwards. They are replaced by a hash of this hasher on the next
+// successful login, as are hashes with outdated parameters.
+func (a *AuthHandler) SetPasswordHasher(passwordHasher PasswordHasher) (error error) {
+	if passwordHasher == nil {
+		return a.newError(ErrInvalidInput, "Error : Password hasher must not be nil!")
+	}
+
+	a.rulesMutex.Lock()
+	a.passwordHasher = passwordHasher
+	a.rulesMutex.Unlock()
+
+	// the hash for unknown users has to take as long as the new hashes
+	a.resetDummyHash()
+	return nil
+}
+
+// SetBcryptCost : Hash new passwords with bcrypt and the given cost.
+// Defaults to bcrypt with bcrypt.DefaultCost.
+func (a *AuthHandler) SetBcryptCost(cost int) (error error) {
+	if cost < bcrypt.MinCost || cost > bcrypt.MaxCost {
+		return a.newError(ErrInvalidInput, "Error : Bcrypt cost has to be between "+strconv.Itoa(bcrypt.MinCost)+" and "+strconv.Itoa(bcrypt.MaxCost)+"!")
+	}
+
+	return a.SetPasswordHasher(&BcryptHasher{Cost: cost})
+}
+
+// get the hasher with which new passwords are hashed
+func (a *AuthHandler) getPasswordHasher() PasswordHasher {
+	a.rulesMutex.RLock()
+	defer a.rulesMutex.RUnlock()
+
+	return a.passwordHasher
+}
+
+// check the password with the hasher which created the hash
+func (a *AuthHandler) verifyPassword(password string, hashedPassword string) (matches bool, error error) {
+	hashers := append([]PasswordHasher{a.getPasswordHasher()}, knownPasswordHashers()...)
+	for _, hasher := range hashers {
+		if hasher.Supports(hashedPassword) {
+			return hasher.Verify(password, hashedPassword)
+		}
+	}
+
+	return false, newError(ErrInternal, "Error : Unknown password hash algorithm!")
+}
+
+// replace the hash of the user after a successful login if it was not
+// created by the current hasher with its current parameters. The hash is
+// only replaced if the user was not changed since it was read, so that
+// e.g. a concurrent password change or status change is not undone.
+// Errors are only logged, as the login itself was successful.
+func (a *AuthHandler) rehashPassword(user *User, password string) {
+	hasher := a.getPasswordHasher()
+	if hasher.Supports(user.HashedPassword) && !hasher.NeedsRehash(user.HashedPassword) {
+		return
+	}
+
+	hashedPassword, error := hasher.Hash(password)
+	if a.logError(error) != nil {
+		return
+	}
+
+	updatedUser := user.copy()
+	updatedUser.HashedPassword = hashedPassword
+	error = a.userStore.CompareAndUpdateUser(user, updatedUser)
+	if error == nil {
+		user.HashedPassword = hashedPassword
+	} else if !errors.Is(error, ErrConflict) {
+		a.logError(error)
+	}
+}
+
+// CheckPasswordPolicy : check password against the password policy. All
+// violated rules are returned at once in a *PasswordPolicyError.
+func (a *AuthHandler) CheckPasswordPolicy(userName string, password string) (error error) {
+	a.rulesMutex.RLock()
+	policy := a.passwordPolicy
+	a.rulesMutex.RUnlock()
+
+	if policy == nil {
+		return nil
+	}
+
+	return a.logError(policy.Check(userName, password))
+}
+
+// CheckRegexRule : Check if a value is matching a given regular expression.
+// The regex is compiled on every call, registered rules should be used
+// for checks which are done repeatedly.
+func (a *AuthHandler) CheckRegexRule(regex string, value string, nameOfRule string) (error error) {
+	rule, error := NewRegexRule(nameOfRule, regex)
+	if error == nil {
+		error = rule.Check(value)
+	}
+
+	return a.logError(error)
+}
+
+// CheckIfUserNameIsFree : check if user name is not used yet. Returns
+// ErrEnumerationProtected if the enumeration protection is enabled.
+func (a *AuthHandler) CheckIfUserNameIsFree(userName string) (error error) {
+	if a.isEnumerationProtected() {
+		return a.logError(ErrEnumerationProtected)
+	}
+
+	return a.checkIfUserNameIsFree(userName)
+}
+
+// check if user name is not used yet independent of the enumeration protection
+func (a *AuthHandler) checkIfUserNameIsFree(userName string) (error error) {
 	// try to get user by user name
-	user, _ := a.GetUserByUserName(userName)
+	user, lookUpError := a.userStore.GetUserByUserName(userName)
 
-	// if user was not found everything is fine
-	// otherwise return error
-	if user == nil {
-		successful = true
+	// if user was not found everything is fine. If the
+	// user exists or the lookup failed return error
+	if user == nil && errors.Is(lookUpError, ErrUserNotFound) {
 		error = nil
+	} else if user == nil {
+		error = a.logError(lookUpError)
 	} else {
-		successful = false
-		error = LogNewError("Error : Username '" + userName + "' already used. Please choose a different Username!")
+		error = a.newError(ErrUsernameTaken, "Error : Username '"+userName+"' already used. Please choose a different Username!")
+	}
+
+	return error
+}
+
+// check a new password against the password rules and the password policy
+func (a *AuthHandler) checkNewPassword(userName string, password string) (error error) {
+	error = a.CheckPasswordRule(password)
+	if error == nil {
+		error = a.CheckPasswordPolicy(userName, password)
 	}
 
-	return successful, error
+	return error
 }
 
 // PreSignUpCheck : Do pre checks to verify if user can be created
-func (a *AuthHandler) PreSignUpCheck(userName string, password string) (successful bool, error error) {
+func (a *AuthHandler) PreSignUpCheck(userName string, password string) (error error) {
 
 	// check if user name or password is not empty
-	if len(userName) > 0 && len(password) > 0 {
-		successful = true
-		error = nil
-	} else {
-		successful = false
-		error = LogNewError("Error : Please enter a valid username and password!")
+	if len(userName) == 0 || len(password) == 0 {
+		error = a.newError(ErrInvalidInput, "Error : Please enter a valid username and password!")
 	}
 
-	// TODO : from here --> continue with User and password rule
-	// if successful {
-	// 	successful, error = a.CheckUserNameRule(username)
-	// }
-	//
-	// if successful {
-	// 	successful, error = a.CheckPasswordRule(password)
-	// }
-	// TODO : to here --> continue with User and password rule
+	if error == nil {
+		error = a.checkUserNameIsNoEmail(userName)
+	}
+
+	if error == nil {
+		error = a.CheckUserNameRule(userName)
+	}
 
-	if successful {
-		successful, error = a.CheckIfUserNameIsFree(userName)
+	if error == nil {
+		error = a.checkNewPassword(userName, password)
 	}
 
-	return successful, error
+	// with enumeration protection a taken user name is only
+	// noticed after hashing the password in CreateNewUser
+	if error == nil && !a.isEnumerationProtected() {
+		error = a.checkIfUserNameIsFree(userName)
+	}
+
+	return error
+}
+
+// CreateNewUser : Create a new user and add it to the UserStore. With
+// enumeration protection neither a user nor an error is returned if the
+// user name is taken.
+func (a *AuthHandler) CreateNewUser(userName string, password string) (user *User, error error) {
+	return a.createNewUser(userName, "", password)
 }
 
-// CreateNewUser : Create a new user and add it to the User maps
-func (a *AuthHandler) CreateNewUser(userName string, password string) (successful bool, error error) {
-	var user User
+// create a new user with the already normalized email address, which may be empty
+func (a *AuthHandler) createNewUser(userName string, email string, password string) (user *User, error error) {
+	if error = a.checkUserNameIsNoEmail(userName); error != nil {
+		return nil, error
+	}
+
+	user = new(User)
 
-	// set ID and UserName
+	// set ID, UserName, Email and Status
 	user.ID = uuid.New().String()
 	user.UserName = userName
+	user.Email = email
+	user.Status = StatusActive
 
 	// hash and set password
-	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
-	if err == nil {
-		user.HashedPassword = string(hashedPassword)
-		successful = true
-		error = nil
-	} else {
-		successful = false
-		error = LogNewError("Error : Unable to hash password for user '" + userName + "' !")
+	hashedPassword, hashError := a.getPasswordHasher().Hash(password)
+	if hashError != nil {
+		a.logError(hashError)
+		return nil, a.newError(ErrInternal, "Error : Unable to hash password for user '"+userName+"' !")
+	}
+	user.HashedPassword = hashedPassword
+
+	// check user name and email again and add new user to user store in
+	// one step. Hashing is done before, so that concurrent sign ups only
+	// have to wait for the (fast) store operations.
+	a.signUpMutex.Lock()
+	error = a.checkIfUserNameIsFree(userName)
+	if error == nil {
+		error = a.checkIfEmailIsFree(email)
 	}
+	if error == nil {
+		error = a.logError(a.userStore.CreateUser(user))
+	}
+	a.signUpMutex.Unlock()
 
-	// add new user to user maps
-	if successful {
-		a.UsersByID[user.ID] = &user
-		a.userIDsByUserName[user.UserName] = &user.ID
+	if (errors.Is(error, ErrUsernameTaken) || errors.Is(error, ErrEmailTaken)) && a.isEnumerationProtected() {
+		return nil, nil
+	} else if error != nil {
+		return nil, error
 	}
 
-	return successful, error
+	return user, nil
 }
 
-// SignUp : sign up / register a new user
-func (a *AuthHandler) SignUp(userName string, password string) (successful bool, error error) {
+// SignUp : sign up / register a new user and return the created user.
+// With enumeration protection neither a user nor an error is returned if
+// the user name is taken, see SetEnumerationProtection.
+func (a *AuthHandler) SignUp(userName string, password string) (user *User, error error) {
 
-	successful, error = a.PreSignUpCheck(userName, password)
-	if successful {
-		successful, error = a.CreateNewUser(userName, password)
+	error = a.PreSignUpCheck(userName, password)
+	if error == nil {
+		user, error = a.CreateNewUser(userName, password)
 	}
 
-	return successful, error
+	return user, error
 }
 
 // PreLogInCheck : Do pre checks to verify if login can be performed
-func (a *AuthHandler) PreLogInCheck(userName string, password string) (successful bool, error error) {
+func (a *AuthHandler) PreLogInCheck(userName string, password string) (error error) {
 
 	// check if user name or password is not empty
-	if len(userName) > 0 && len(password) > 0 {
-		successful = true
-		error = nil
-	} else {
-		successful = false
-		error = LogNewError("Error : Please enter a valid username and password!")
-	}
-
-	return successful, error
-}
-
-func (a *AuthHandler) AuthenticateByJWT(JWT string) (bool, error) {
-	var successful bool
-	var err error
-
-	secret := []byte(a.GetSecretForJWTGeneration())
-
-	// try to parse JWT / check if JWT is in a valid format
-	token, err := jwt.Parse(JWT, func(token *jwt.Token) (interface{}, error) {
-		// check token signing method etc
-		return secret, nil
-	})
-
-	// if parsing was successful try get claims
-	// otherwise exit with error
-	if err == nil {
-		claims, ok := token.Claims.(jwt.MapClaims)
-
-		// if getting claims is successful try to get
-		// corresponding user and compare JWTs
-		if ok {
-			// todo add error handling here
-			username, _ := claims["UserName"].(string)
-			user, errorFindingUser := a.GetUserByUserName(string(username))
-			// check if there was an error while getting the username
-			// which was found in the claims. If no error exists the
-			// user is a valid known user. If the user is not existing
-			// there seems to be something wrong with the claims. In this
-			// case we exit with an error
-			if errorFindingUser == nil {
-				if JWT == user.AccessToken {
-					successful = true
-					err = nil
-				} else {
-					successful = false
-					err = LogNewError("Error : Authentication Failed. JWT AccessToken is not valid!")
-				}
-			} else {
-				successful = false
-				err = LogNewError("Error : Authentication Failed. JWT AccessToken is not valid!")
-			}
-		} else {
-			successful = false
-			err = LogNewError("Error : Authentication Failed. JWT AccessToken is not valid!")
-		}
-	} else {
-		successful = false
-		err = LogNewError("Error : Authentication Failed. JWT AccessToken is not valid!")
+	if len(userName) == 0 || len(password) == 0 {
+		error = a.newError(ErrInvalidCredentials, "Error : Please enter a valid username and password!")
 	}
 
-	return successful, err
-
+	return error
 }
 
-func (a *AuthHandler) AuthenticateByPassword(userName string, password string) (successful bool, error error) {
-	// try to get user by user name
-	user, _ := a.GetUserByUserName(userName)
+// AuthenticateByPassword : Check if password is valid for the user and
+// return the authenticated user. The user is identified by user name or
+// by email address. An outdated hash of the password is
+// replaced by a hash of the current PasswordHasher. Unknown users take
+// as long as wrong passwords, so that the response time does not reveal
+// which user names exist. With a RateLimiter a *RateLimitError is
+// returned if there were too many logins of the user name. Users who are
+// not active get an *AccountStatusError after entering the right password.
+func (a *AuthHandler) AuthenticateByPassword(userName string, password string) (user *User, error error) {
+	return a.authenticateWithRateLimit(userName, password, ClientInfo{})
+}
 
+// check if the password is valid for the user which was looked up by
+// user name or email address, nil if there is no such user
+func (a *AuthHandler) verifyCredentials(user *User, password string) (verifiedUser *User, error error) {
 	// if user is existing try to validate the password
-	// if not exit with error
-	if user != nil {
-		comparisonError := bcrypt.CompareHashAndPassword([]byte(user.HashedPassword), []byte(password))
-		if comparisonError == nil {
-			successful = true
-			error = nil
-		} else {
-			successful = false
-			error = LogNewError("Error : Please enter a valid username and password!")
-		}
-	} else {
-		successful = false
-		error = LogNewError("Error : Please enter a valid username and password!")
+	// if not do the same work and exit with error
+	if user == nil {
+		a.verifyDummyPassword(password)
+		return nil, a.newError(ErrInvalidCredentials, "Error : Please enter a valid username and password!")
+	}
+
+	matches, verifyError := a.verifyPassword(password, user.HashedPassword)
+	if !matches {
+		a.logError(verifyError)
+		return nil, a.newError(ErrInvalidCredentials, "Error : Please enter a valid username and password!")
+	}
+
+	// the status is only revealed to users who know the password
+	if error = a.checkUserStatus(user); error != nil {
+		return nil, error
+	}
+	if error = a.checkEmailVerified(user); error != nil {
+		return nil, error
 	}
 
-	return successful, error
+	// the algorithm or its parameters may be outdated
+	a.rehashPassword(user, password)
+
+	return user, nil
 }
 
-// Try to get secret string from environment of host machine
-// If nothing can be found return error
-func (a *AuthHandler) GetSecretForJWTGeneration() (secret string) {
-	secret = os.Getenv("SECRET")
-	return secret
+// LogInResult : result of a successful login or refresh. The refresh
+// token can be exchanged for a new result with Refresh.
+type LogInResult struct {
+	User                  *User
+	Session               *Session
+	AccessToken           string
+	AccessTokenExpiresAt  time.Time
+	RefreshToken          string
+	RefreshTokenExpiresAt time.Time
 }
 
-// GenerateJWT : generate JWT token for user with secret and store it in user
-func (a *AuthHandler) GenerateJWT(user *User) (successful bool, error error) {
-	// get secret
-	secret := a.GetSecretForJWTGeneration()
+// LogIn : Try to login user with given credentials and after successful login
+// try to generate JWT and refresh token for further authentication. The
+// user name can be replaced by the email address of the user.
+func (a *AuthHandler) LogIn(userName string, password string) (result *LogInResult, error error) {
+	return a.LogInFromClient(userName, password, ClientInfo{})
+}
 
-	// if secret is set, generate jwt token
-	// otherwise exit with error
-	if secret != "" {
+// LogInFromClient : Login like LogIn and store the information about the
+// client in the new session. Its IP is used for the rate limit per IP. Each login creates a new session, the other
+// sessions of the user stay valid.
+func (a *AuthHandler) LogInFromClient(userName string, password string, client ClientInfo) (result *LogInResult, error error) {
 
-		// create token
-		token := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.MapClaims{
-			"UserName": user.UserName,
-			"Test":     "Hello World",
-		})
+	error = a.PreLogInCheck(userName, password)
+	if error != nil {
+		return nil, error
+	}
 
-		// sign token
-		signedToken, error := token.SignedString([]byte(secret))
-		if error != nil {
-			successful = false
-			error = LogNewError(error.Error())
-		} else {
-			successful = true
-			error = nil
-		}
+	// if pre checks were successful try to
+	// authenticate with given user name and password
+	user, error := a.authenticateWithRateLimit(userName, password, client)
+	if error != nil {
+		return nil, error
+	}
 
-		user.AccessToken = signedToken
+	// if authentication was successful try to create
+	// a new session and generate its tokens
+	return a.startSession(user, client)
+}
 
-	} else {
-		successful = false
-		error = LogNewError("Error : No Secret for JWT generation set!")
+// create a new session of the user and generate its tokens
+func (a *AuthHandler) startSession(user *User, client ClientInfo) (result *LogInResult, error error) {
+	session, error := a.createSession(user, client)
+	if error != nil {
+		return nil, error
 	}
 
-	return successful, error
+	result, error = a.issueTokens(user, session)
+	if error != nil {
+		a.sessionStore.DeleteSession(session.ID)
+		return nil, error
+	}
 
+	return result, nil
 }
 
-// LogIn : Try to login user with given credentials and after successful login
-//         try to generate JWT for further authentication
-func (a *AuthHandler) LogIn(userName string, password string) (successful bool, error error) {
-
-	successful, error = a.PreLogInCheck(userName, password)
+// generate an access token and a refresh token of the session for the
+// user and extend the session to the lifetime of the refresh token
+func (a *AuthHandler) issueTokens(user *User, session *Session) (result *LogInResult, error error) {
+	accessToken, claims, error := a.generateAccessToken(user, session)
+	if error != nil {
+		return nil, error
+	}
 
-	// if pre checks were successful try to
-	// authenticate with given user name and password
-	if successful {
-		successful, error = a.AuthenticateByPassword(userName, password)
+	refreshToken, storedRefreshToken, error := a.generateRefreshToken(user, session.ID)
+	if error != nil {
+		return nil, error
 	}
 
-	// if authentication was successful
-	// try to generate JWT token
-	if successful {
-		user, _ := a.GetUserByUserName(userName)
-		successful, error = a.GenerateJWT(user)
+	session.LastSeenAt = storedRefreshToken.IssuedAt
+	session.ExpiresAt = storedRefreshToken.ExpiresAt
+	error = a.logError(a.sessionStore.UpdateSession(session))
+	if error != nil {
+		return nil, error
 	}
 
-	return successful, error
+	return &LogInResult{
+		User:                  user,
+		Session:               session,
+		AccessToken:           accessToken,
+		AccessTokenExpiresAt:  claims.ExpiresAt.Time,
+		RefreshToken:          refreshToken,
+		RefreshTokenExpiresAt: storedRefreshToken.ExpiresAt,
+	}, nil
 }
@@ -0,0 +1,17 @@
+package auth
+
+import "time"
+
+// Clock : source of the current time. It can be replaced in tests
+// to check time dependent behavior like token expiry.
+type Clock interface {
+	Now() time.Time
+}
+
+// SystemClock : Clock which returns the time of the host machine
+type SystemClock struct{}
+
+// Now : Get the current time of the host machine
+func (SystemClock) Now() time.Time {
+	return time.Now()
+}
@@ -0,0 +1,455 @@
+package auth
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/BurntSushi/toml"
+	"golang.org/x/crypto/bcrypt"
+	"gopkg.in/yaml.v3"
+)
+
+// Config : settings of an AuthHandler which can be loaded from a YAML,
+// JSON or TOML file, so that a deployment can configure authentication
+// without recompiling. Fields which are not set keep their defaults.
+// Stores, the clock, the logger and custom rules can not be written to a
+// file and are given as options next to WithConfig.
+//
+// Example in YAML:
+//
+//	access_token_lifetime: 10m
+//	issuer: https://auth.example.com
+//	secret_file: /run/secrets/jwt
+//	user_name_rules: ['^[a-z0-9_]{3,32}$']
+//	password_policy:
+//	  min_length: 12
+//	  character_classes: [lowercase, uppercase, digit]
+type Config struct {
+	AccessTokenLifetime            Duration              `json:"access_token_lifetime"`
+	RefreshTokenLifetime           Duration              `json:"refresh_token_lifetime"`
+	PasswordResetTokenLifetime     Duration              `json:"password_reset_token_lifetime"`
+	EmailVerificationTokenLifetime Duration              `json:"email_verification_token_lifetime"`
+	Issuer                         string                `json:"issuer"`
+	Audience                       []string              `json:"audience"`
+	ClockSkew                      Duration              `json:"clock_skew"`
+	AllowedAlgorithms              []string              `json:"allowed_algorithms"`
+	ValidationMode                 string                `json:"validation_mode"`
+	SecretEnv                      string                `json:"secret_env"`
+	SecretFile                     string                `json:"secret_file"`
+	SigningKey                     *KeyConfig            `json:"signing_key"`
+	UserNameRules                  []string              `json:"user_name_rules"`
+	PasswordRules                  []string              `json:"password_rules"`
+	PasswordPolicy                 *PasswordPolicyConfig `json:"password_policy"`
+	BcryptCost                     int                   `json:"bcrypt_cost"`
+	PasswordHasher                 *PasswordHasherConfig `json:"password_hasher"`
+	EnumerationProtection          bool                  `json:"enumeration_protection"`
+	EmailVerificationRequired      bool                  `json:"email_verification_required"`
+	RateLimiter                    *RateLimiterConfig    `json:"rate_limiter"`
+}
+
+// KeyConfig : PEM file of the key with which access tokens are signed or
+// verified. A verifier only sets the public key file.
+type KeyConfig struct {
+	Algorithm      string `json:"algorithm"`
+	PrivateKeyFile string `json:"private_key_file"`
+	PublicKeyFile  string `json:"public_key_file"`
+}
+
+// PasswordPolicyConfig : rules of the password policy, character classes
+// are lowercase, uppercase, digit and special
+type PasswordPolicyConfig struct {
+	MinLength             int      `json:"min_length"`
+	MaxLength             int      `json:"max_length"`
+	CharacterClasses      []string `json:"character_classes"`
+	MaxRepeatedCharacters int      `json:"max_repeated_characters"`
+	NoUserName            bool     `json:"no_username"`
+	DictionaryFile        string   `json:"dictionary_file"`
+}
+
+// PasswordHasherConfig : algorithm with which new passwords are hashed:
+// bcrypt, argon2id or scrypt. Parameters which are not set keep the
+// defaults of the algorithm. Cost is used by bcrypt, memory (in KiB),
+// iterations and parallelism by Argon2id and cost_log2, block_size and
+// parallelism by scrypt.
+type PasswordHasherConfig struct {
+	Algorithm   string `json:"algorithm"`
+	Cost        int    `json:"cost"`
+	Memory      uint32 `json:"memory"`
+	Iterations  uint32 `json:"iterations"`
+	Parallelism uint8  `json:"parallelism"`
+	CostLog2    uint8  `json:"cost_log2"`
+	BlockSize   int    `json:"block_size"`
+}
+
+// RateLimiterConfig : limits of the logins per user name and per IP, see
+// RateLimiter. Settings which are not set keep the defaults of
+// NewRateLimiter. The counters are kept in memory.
+type RateLimiterConfig struct {
+	UserNameBurst    int      `json:"user_name_burst"`
+	UserNameInterval Duration `json:"user_name_interval"`
+	IPBurst          int      `json:"ip_burst"`
+	IPInterval       Duration `json:"ip_interval"`
+	BackoffBase      Duration `json:"backoff_base"`
+	BackoffMax       Duration `json:"backoff_max"`
+	LockoutThreshold int      `json:"lockout_threshold"`
+	LockoutDuration  Duration `json:"lockout_duration"`
+	FailureWindow    Duration `json:"failure_window"`
+}
+
+// Duration : time.Duration which is written like "15m" or "720h" in
+// configuration files
+type Duration time.Duration
+
+// UnmarshalJSON : Parse a duration string like "15m"
+func (d *Duration) UnmarshalJSON(data []byte) (error error) {
+	var value string
+	if error = json.Unmarshal(data, &value); error != nil {
+		return newError(ErrInvalidConfig, "Error : Duration has to be a string like \"15m\" but is "+string(data)+"!")
+	}
+
+	duration, error := time.ParseDuration(value)
+	if error != nil {
+		return newError(ErrInvalidConfig, "Error : Invalid duration '"+value+"' : "+error.Error())
+	}
+	*d = Duration(duration)
+
+	return nil
+}
+
+// MarshalJSON : Write the duration as string like "15m0s"
+func (d Duration) MarshalJSON() ([]byte, error) {
+	return json.Marshal(time.Duration(d).String())
+}
+
+// LoadConfig : Load the configuration from a file. The format is chosen
+// by the extension of the file: .yaml, .yml, .json or .toml.
+func LoadConfig(path string) (config *Config, error error) {
+	data, error := os.ReadFile(path)
+	if error != nil {
+		return nil, newError(ErrInvalidConfig, "Error : Unable to read configuration file '"+path+"' : "+error.Error())
+	}
+
+	format := strings.TrimPrefix(strings.ToLower(filepath.Ext(path)), ".")
+	return ParseConfig(data, format)
+}
+
+// ParseConfig : Parse a configuration in the given format: yaml, json or
+// toml. Unknown keys are rejected, so that typos do not silently keep the
+// defaults.
+func ParseConfig(data []byte, format string) (config *Config, error error) {
+	// all formats are converted to JSON first, so that the
+	// json tags of the Config are used for every format
+	var values map[string]interface{}
+	switch format {
+	case "yaml", "yml":
+		error = yaml.Unmarshal(data, &values)
+	case "json":
+		error = json.Unmarshal(data, &values)
+	case "toml":
+		error = toml.Unmarshal(data, &values)
+	default:
+		return nil, newError(ErrInvalidConfig, "Error : Unknown configuration format '"+format+"'. Please use yaml, json or toml!")
+	}
+	if error != nil {
+		return nil, newError(ErrInvalidConfig, "Error : Unable to parse "+format+" configuration : "+error.Error())
+	}
+
+	jsonData, error := json.Marshal(values)
+	if error != nil {
+		return nil, newError(ErrInvalidConfig, "Error : Unable to convert "+format+" configuration : "+error.Error())
+	}
+
+	config = new(Config)
+	decoder := json.NewDecoder(bytes.NewReader(jsonData))
+	decoder.DisallowUnknownFields()
+	if error = decoder.Decode(config); error != nil {
+		var configError *Error
+		if errors.As(error, &configError) {
+			return nil, configError
+		}
+		return nil, newError(ErrInvalidConfig, "Error : Invalid "+format+" configuration : "+error.Error())
+	}
+
+	return config, nil
+}
+
+// WithConfig : Apply all settings of the configuration. Options which are
+// given after WithConfig override its settings.
+func WithConfig(config *Config) Option {
+	return func(authH *AuthHandler) (error error) {
+		if config == nil {
+			return authH.newError(ErrInvalidConfig, "Error : Configuration must not be nil!")
+		}
+
+		options, error := config.options()
+		if error != nil {
+			return authH.logError(error)
+		}
+		for _, option := range options {
+			if error = option(authH); error != nil {
+				return error
+			}
+		}
+
+		return nil
+	}
+}
+
+// convert the configuration to options, the key options come
+// first as they reset the allowed algorithms
+func (c *Config) options() (options []Option, error error) {
+	switch {
+	case c.SecretEnv != "" && c.SecretFile != "", (c.SecretEnv != "" || c.SecretFile != "") && c.SigningKey != nil:
+		return nil, newError(ErrInvalidConfig, "Error : Please configure only one of secret_env, secret_file and signing_key!")
+	case c.SecretEnv != "":
+		options = append(options, WithSecretProvider(NewEnvSecretProvider(c.SecretEnv)))
+	case c.SecretFile != "":
+		options = append(options, WithSecretProvider(NewFileSecretProvider(c.SecretFile)))
+	case c.SigningKey != nil:
+		signingKey, error := c.SigningKey.load()
+		if error != nil {
+			return nil, error
+		}
+		options = append(options, WithSigningKey(signingKey))
+	}
+
+	if len(c.AllowedAlgorithms) > 0 {
+		options = append(options, WithAllowedAlgorithms(c.AllowedAlgorithms...))
+	}
+	if c.AccessTokenLifetime != 0 {
+		options = append(options, WithAccessTokenLifetime(time.Duration(c.AccessTokenLifetime)))
+	}
+	if c.RefreshTokenLifetime != 0 {
+		options = append(options, WithRefreshTokenLifetime(time.Duration(c.RefreshTokenLifetime)))
+	}
+	if c.PasswordResetTokenLifetime != 0 {
+		options = append(options, WithPasswordResetTokenLifetime(time.Duration(c.PasswordResetTokenLifetime)))
+	}
+	if c.EmailVerificationTokenLifetime != 0 {
+		options = append(options, WithEmailVerificationTokenLifetime(time.Duration(c.EmailVerificationTokenLifetime)))
+	}
+	if c.Issuer != "" {
+		options = append(options, WithIssuer(c.Issuer))
+	}
+	if len(c.Audience) > 0 {
+		options = append(options, WithAudience(c.Audience...))
+	}
+	if c.ClockSkew != 0 {
+		options = append(options, WithClockSkew(time.Duration(c.ClockSkew)))
+	}
+	if c.ValidationMode != "" {
+		validationMode, error := parseValidationMode(c.ValidationMode)
+		if error != nil {
+			return nil, error
+		}
+		options = append(options, WithValidationMode(validationMode))
+	}
+	for _, regex := range c.UserNameRules {
+		options = append(options, WithUserRule(regex))
+	}
+	for _, regex := range c.PasswordRules {
+		options = append(options, WithPasswordRule(regex))
+	}
+	if c.PasswordPolicy != nil {
+		policy, error := c.PasswordPolicy.policy()
+		if error != nil {
+			return nil, error
+		}
+		options = append(options, WithPasswordPolicy(policy))
+	}
+	if c.BcryptCost != 0 && c.PasswordHasher != nil {
+		return nil, newError(ErrInvalidConfig, "Error : Please configure only one of bcrypt_cost and password_hasher!")
+	}
+	if c.BcryptCost != 0 {
+		options = append(options, WithBcryptCost(c.BcryptCost))
+	}
+	if c.PasswordHasher != nil {
+		passwordHasher, error := c.PasswordHasher.hasher()
+		if error != nil {
+			return nil, error
+		}
+		options = append(options, WithPasswordHasher(passwordHasher))
+	}
+	if c.EnumerationProtection {
+		options = append(options, WithEnumerationProtection())
+	}
+	if c.EmailVerificationRequired {
+		options = append(options, WithEmailVerificationRequired())
+	}
+	if c.RateLimiter != nil {
+		rateLimiter, error := c.RateLimiter.rateLimiter()
+		if error != nil {
+			return nil, error
+		}
+		options = append(options, WithRateLimiter(rateLimiter))
+	}
+
+	return options, nil
+}
+
+// load the private key or, if only the public key is configured, the public key
+func (k *KeyConfig) load() (signingKey *SigningKey, error error) {
+	if k.PrivateKeyFile != "" {
+		return LoadSigningKeyFromFile(k.Algorithm, k.PrivateKeyFile)
+	}
+	if k.PublicKeyFile != "" {
+		return LoadVerificationKeyFromFile(k.Algorithm, k.PublicKeyFile)
+	}
+
+	return nil, newError(ErrInvalidConfig, "Error : Please configure private_key_file or public_key_file of the signing_key!")
+}
+
+// create the password policy with all configured rules
+func (p *PasswordPolicyConfig) policy() (policy *PasswordPolicy, error error) {
+	policy = NewPasswordPolicy()
+	if p.MinLength > 0 {
+		policy.AddRule(MinLengthRule{Length: p.MinLength})
+	}
+	if p.MaxLength > 0 {
+		policy.AddRule(MaxLengthRule{Length: p.MaxLength})
+	}
+	if len(p.CharacterClasses) > 0 {
+		rule := CharacterClassRule{}
+		for _, name := range p.CharacterClasses {
+			class, error := parseCharacterClass(name)
+			if error != nil {
+				return nil, error
+			}
+			rule.Classes = append(rule.Classes, class)
+		}
+		policy.AddRule(rule)
+	}
+	if p.MaxRepeatedCharacters > 0 {
+		policy.AddRule(MaxRepeatedCharactersRule{Count: p.MaxRepeatedCharacters})
+	}
+	if p.NoUserName {
+		policy.AddRule(NoUserNameRule{})
+	}
+	if p.DictionaryFile != "" {
+		rule, error := NewDictionaryRuleFromFile(p.DictionaryFile)
+		if error != nil {
+			return nil, error
+		}
+		policy.AddRule(rule)
+	}
+
+	return policy, nil
+}
+
+// create the configured password hasher
+func (p *PasswordHasherConfig) hasher() (passwordHasher PasswordHasher, error error) {
+	switch p.Algorithm {
+	case "bcrypt":
+		hasher := NewBcryptHasher()
+		if p.Cost != 0 {
+			if p.Cost < bcrypt.MinCost || p.Cost > bcrypt.MaxCost {
+				return nil, newError(ErrInvalidConfig, "Error : Cost of bcrypt has to be between "+strconv.Itoa(bcrypt.MinCost)+" and "+strconv.Itoa(bcrypt.MaxCost)+"!")
+			}
+			hasher.Cost = p.Cost
+		}
+		return hasher, nil
+	case "argon2id":
+		hasher := NewArgon2idHasher()
+		if p.Memory != 0 {
+			hasher.Memory = p.Memory
+		}
+		if p.Iterations != 0 {
+			hasher.Iterations = p.Iterations
+		}
+		if p.Parallelism != 0 {
+			hasher.Parallelism = p.Parallelism
+		}
+		return hasher, nil
+	case "scrypt":
+		hasher := NewScryptHasher()
+		if p.CostLog2 != 0 {
+			if p.CostLog2 >= 32 {
+				return nil, newError(ErrInvalidConfig, "Error : cost_log2 of scrypt has to be less than 32!")
+			}
+			hasher.CostLog2 = p.CostLog2
+		}
+		if p.BlockSize != 0 {
+			hasher.BlockSize = p.BlockSize
+		}
+		if p.Parallelism != 0 {
+			hasher.Parallelism = int(p.Parallelism)
+		}
+		return hasher, nil
+	default:
+		return nil, newError(ErrInvalidConfig, "Error : Unknown password hasher algorithm '"+p.Algorithm+"'. Please use bcrypt, argon2id or scrypt!")
+	}
+}
+
+// get the validation mode for its name in configuration files
+func parseValidationMode(name string) (validationMode ValidationMode, error error) {
+	switch name {
+	case "stateful":
+		return StatefulValidation, nil
+	case "stateless":
+		return StatelessValidation, nil
+	case "stateless_without_revocation":
+		return StatelessValidationWithoutRevocation, nil
+	default:
+		return 0, newError(ErrInvalidConfig, "Error : Unknown validation_mode '"+name+"'. Please use stateful, stateless or stateless_without_revocation!")
+	}
+}
+
+// get the character class for its name in configuration files
+func parseCharacterClass(name string) (class CharacterClass, error error) {
+	switch name {
+	case "lowercase":
+		return Lowercase, nil
+	case "uppercase":
+		return Uppercase, nil
+	case "digit":
+		return Digit, nil
+	case "special":
+		return Special, nil
+	default:
+		return 0, newError(ErrInvalidConfig, "Error : Unknown character class '"+name+"'. Please use lowercase, uppercase, digit or special!")
+	}
+}
+
+// create the rate limiter with the configured settings
+func (r *RateLimiterConfig) rateLimiter() (rateLimiter *RateLimiter, error error) {
+	rateLimiter = NewRateLimiter()
+	if r.UserNameBurst != 0 {
+		rateLimiter.UserNameLimit.Burst = r.UserNameBurst
+	}
+	if r.UserNameInterval != 0 {
+		rateLimiter.UserNameLimit.Interval = time.Duration(r.UserNameInterval)
+	}
+	if r.IPBurst != 0 {
+		rateLimiter.IPLimit.Burst = r.IPBurst
+	}
+	if r.IPInterval != 0 {
+		rateLimiter.IPLimit.Interval = time.Duration(r.IPInterval)
+	}
+	if r.BackoffBase != 0 {
+		rateLimiter.BackoffBase = time.Duration(r.BackoffBase)
+	}
+	if r.BackoffMax != 0 {
+		rateLimiter.BackoffMax = time.Duration(r.BackoffMax)
+	}
+	if r.LockoutThreshold != 0 {
+		rateLimiter.LockoutThreshold = r.LockoutThreshold
+	}
+	if r.LockoutDuration != 0 {
+		rateLimiter.LockoutDuration = time.Duration(r.LockoutDuration)
+	}
+	if r.FailureWindow != 0 {
+		rateLimiter.FailureWindow = time.Duration(r.FailureWindow)
+	}
+
+	if error = rateLimiter.validate(); error != nil {
+		return nil, newError(ErrInvalidConfig, error.Error())
+	}
+
+	return rateLimiter, nil
+}
@@ -0,0 +1,105 @@
+package auth
+
+import (
+	"sync"
+	"time"
+)
+
+// Counter : state of the login throttling of a single user name or client
+// IP, see RateLimiter
+type Counter struct {
+	// Tokens : tokens left in the bucket at UpdatedAt
+	Tokens    float64
+	UpdatedAt time.Time
+	// Failures : number of failed logins since the last successful one
+	Failures    int
+	LastFailure time.Time
+	// LockedUntil : end of the lockout, zero if the key is not locked
+	LockedUntil time.Time
+	// ExpiresAt : time after which the counter is equal to a new one
+	// and can be removed
+	ExpiresAt time.Time
+}
+
+// CounterStore : persistence layer of the counters of the RateLimiter.
+// Several AuthHandlers which share a store, e.g. on several servers,
+// share their limits. Errors have to wrap ErrStore for failures of the
+// underlying storage.
+type CounterStore interface {
+	// UpdateCounter : Atomically replace the counter of the key by the
+	// result of update, which gets the current counter or a zero counter
+	// if there is none. Shared stores can call update again if the counter
+	// was changed concurrently, so update must not have side effects.
+	UpdateCounter(key string, update func(counter Counter) Counter) (counter Counter, error error)
+	// GetCounter : Get the counter of the key or a zero counter if there is none
+	GetCounter(key string) (counter Counter, error error)
+	// DeleteCounter : Remove the counter of the key if there is one
+	DeleteCounter(key string) (error error)
+	// PruneExpired : Remove all counters which expired before now
+	PruneExpired(now time.Time) (error error)
+}
+
+// InMemoryCounterStore : default CounterStore which keeps the counters in
+// a map, so limits are only shared by AuthHandlers of the same process.
+// The store is safe for concurrent use.
+type InMemoryCounterStore struct {
+	mutex    sync.Mutex
+	counters map[string]Counter
+}
+
+// NewInMemoryCounterStore : Create a new empty in-memory counter store
+func NewInMemoryCounterStore() *InMemoryCounterStore {
+	store := new(InMemoryCounterStore)
+	store.counters = make(map[string]Counter)
+
+	return store
+}
+
+// UpdateCounter : Replace the counter of the key while holding the lock of the store
+func (s *InMemoryCounterStore) UpdateCounter(key string, update func(counter Counter) Counter) (counter Counter, error error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	counter = update(s.counters[key])
+	s.counters[key] = counter
+	return counter, nil
+}
+
+// GetCounter : Get the counter of the key
+func (s *InMemoryCounterStore) GetCounter(key string) (counter Counter, error error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	return s.counters[key], nil
+}
+
+// DeleteCounter : Remove the counter of the key
+func (s *InMemoryCounterStore) DeleteCounter(key string) (error error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	delete(s.counters, key)
+	return nil
+}
+
+// PruneExpired : Remove all counters which expired before now
+func (s *InMemoryCounterStore) PruneExpired(now time.Time) (error error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	for key, counter := range s.counters {
+		if counter.ExpiresAt.Before(now) {
+			delete(s.counters, key)
+		}
+	}
+
+	return nil
+}
+
+// Len : Get the number of counters, e.g. to monitor the size of the store
+func (s *InMemoryCounterStore) Len() int {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	return len(s.counters)
+}
@@ -0,0 +1,276 @@
+package auth
+
+import (
+	"errors"
+	"net/mail"
+	"strings"
+	"time"
+)
+
+// DefaultEmailVerificationTokenLifetime : lifetime of email verification tokens if nothing else is configured
+const DefaultEmailVerificationTokenLifetime = 24 * time.Hour
+
+// maximum length of an email address, see RFC 5321
+const maxEmailLength = 254
+
+// NormalizeEmail : Check that the email address is a plain address like
+// peter@example.com without display name and return it in the form in
+// which it is stored. Surrounding spaces are removed and the whole address
+// is lower-cased, as nearly all mail servers treat the local part case
+// insensitively, so that the same address can not be registered twice.
+func NormalizeEmail(email string) (normalized string, error error) {
+	email = strings.TrimSpace(email)
+	address, parseError := mail.ParseAddress(email)
+	if parseError != nil || address.Name != "" || address.Address != email || len(email) > maxEmailLength {
+		return "", newError(ErrInvalidEmail, "Error : '"+email+"' is not a valid email address!")
+	}
+
+	return strings.ToLower(email), nil
+}
+
+// SetEmailVerificationRequired : Refuse logins of users who did not verify
+// their email address with VerifyEmail yet, users without email address
+// included. Tokens which were issued before are not affected. Disabled by
+// default.
+func (a *AuthHandler) SetEmailVerificationRequired(required bool) {
+	a.rulesMutex.Lock()
+	defer a.rulesMutex.Unlock()
+
+	a.emailVerificationRequired = required
+}
+
+// check if logins require a verified email address
+func (a *AuthHandler) isEmailVerificationRequired() bool {
+	a.rulesMutex.RLock()
+	defer a.rulesMutex.RUnlock()
+
+	return a.emailVerificationRequired
+}
+
+// SetEmailVerificationTokenLifetime : Set how long email verification tokens are valid
+func (a *AuthHandler) SetEmailVerificationTokenLifetime(lifetime time.Duration) {
+	a.configMutex.Lock()
+	defer a.configMutex.Unlock()
+
+	a.tokenConfig.emailVerificationTokenLifetime = lifetime
+}
+
+// GetUserByEmail : Get user struct by email address, the address is normalized before
+func (a *AuthHandler) GetUserByEmail(email string) (user *User, error error) {
+	email, error = NormalizeEmail(email)
+	if error == nil {
+		user, error = a.userStore.GetUserByEmail(email)
+	}
+
+	return user, a.logError(error)
+}
+
+// get the user by user name or, if the identifier contains an '@', by
+// email address. New user names must not contain an '@', so that they can
+// not shadow the email address of another user. Identifiers with an '@'
+// are only looked up as user name if no user has this email address,
+// e.g. for imported users.
+func (a *AuthHandler) getUserByIdentifier(identifier string) (user *User, error error) {
+	if !strings.Contains(identifier, "@") {
+		return a.userStore.GetUserByUserName(identifier)
+	}
+
+	if email, emailError := NormalizeEmail(identifier); emailError == nil {
+		user, error = a.userStore.GetUserByEmail(email)
+		if !errors.Is(error, ErrUserNotFound) {
+			return user, error
+		}
+	}
+
+	return a.userStore.GetUserByUserName(identifier)
+}
+
+// check that the user name can not be confused with an email address
+func (a *AuthHandler) checkUserNameIsNoEmail(userName string) (error error) {
+	if strings.Contains(userName, "@") {
+		return a.newError(ErrInvalidInput, "Error : Username must not contain '@'!")
+	}
+
+	return nil
+}
+
+// check if the email address is not used yet independent of the
+// enumeration protection. It must not be the user name of another user
+// either, as logins look up email addresses first. Other spellings of
+// such user names are refused by the UserStore.
+func (a *AuthHandler) checkIfEmailIsFree(email string) (error error) {
+	if email == "" {
+		return nil
+	}
+
+	// if user was not found everything is fine. If the
+	// user exists or the lookup failed return error
+	user, lookUpError := a.userStore.GetUserByEmail(email)
+	if user == nil && errors.Is(lookUpError, ErrUserNotFound) {
+		user, lookUpError = a.userStore.GetUserByUserName(email)
+	}
+	if user == nil && errors.Is(lookUpError, ErrUserNotFound) {
+		error = nil
+	} else if user == nil {
+		error = a.logError(lookUpError)
+	} else {
+		error = a.newError(ErrEmailTaken, "Error : Email '"+email+"' already used. Please choose a different email address!")
+	}
+
+	return error
+}
+
+// SignUpWithEmail : sign up like SignUp and store the email address of the
+// user. The address is normalized with NormalizeEmail and has to be unique,
+// otherwise ErrEmailTaken is returned. If a Notifier is set, a token to
+// verify the address is sent right away, errors of the Notifier are only
+// logged as the user was created. With enumeration protection neither a
+// user nor an error is returned if the user name or the email address is
+// taken, see SetEnumerationProtection.
+func (a *AuthHandler) SignUpWithEmail(userName string, email string, password string) (user *User, error error) {
+	email, error = NormalizeEmail(email)
+	if error != nil {
+		return nil, a.logError(error)
+	}
+
+	error = a.PreSignUpCheck(userName, password)
+	if error == nil && !a.isEnumerationProtected() {
+		error = a.checkIfEmailIsFree(email)
+	}
+	if error == nil {
+		user, error = a.createNewUser(userName, email, password)
+	}
+
+	if user != nil && a.notifier != nil {
+		a.sendEmailVerification(user)
+	}
+
+	return user, error
+}
+
+// SetEmail : Change the email address of the user, an empty address
+// removes it. The new address is not verified until VerifyEmail is
+// called with a new token, tokens for the old address are refused. If a
+// Notifier is set, the token is sent to the new address right away.
+func (a *AuthHandler) SetEmail(userID string, email string) (error error) {
+	if email != "" {
+		email, error = NormalizeEmail(email)
+		if error != nil {
+			return a.logError(error)
+		}
+	}
+
+	oldUser, user, error := a.updateUser(userID, changeEmail(email))
+	if error != nil || oldUser.Email == email {
+		return error
+	}
+
+	// tokens which were sent to the old address must not verify the new one
+	error = a.logError(a.oneTimeTokenStore.DeleteOneTimeTokens(userID, PurposeEmailVerification))
+	if error == nil && email != "" && a.notifier != nil {
+		error = a.sendEmailVerification(user)
+	}
+
+	return error
+}
+
+// replace the email address, a new address is not verified
+func changeEmail(email string) userUpdate {
+	return func(user *User) (error error) {
+		if user.Email != email {
+			user.Email = email
+			user.EmailVerifiedAt = time.Time{}
+		}
+		return nil
+	}
+}
+
+// SendEmailVerification : Send a new token with which the user can verify
+// the email address through the Notifier, e.g. if the last token expired.
+// The new token replaces the earlier tokens of the user.
+func (a *AuthHandler) SendEmailVerification(userID string) (error error) {
+	if a.notifier == nil {
+		return a.newError(ErrNotAllowed, "Error : Email verification is not possible without a notifier!")
+	}
+
+	user, error := a.userStore.GetUserByID(userID)
+	if error != nil {
+		return a.logError(error)
+	}
+	if user.Email == "" {
+		return a.newError(ErrInvalidInput, "Error : User '"+user.UserName+"' has no email address!")
+	}
+	if user.IsEmailVerified() {
+		return a.newError(ErrInvalidInput, "Error : Email address of user '"+user.UserName+"' is already verified!")
+	}
+
+	return a.sendEmailVerification(user)
+}
+
+// issue a one-time token for the current email address of the user and
+// hand it to the Notifier
+func (a *AuthHandler) sendEmailVerification(user *User) (error error) {
+	lifetime := a.getTokenConfig().emailVerificationTokenLifetime
+	token, storedToken, error := a.issueOneTimeToken(user, PurposeEmailVerification, lifetime)
+	if error != nil {
+		return error
+	}
+
+	return a.logError(a.notifier.Notify(Notification{
+		Kind:      NotificationEmailVerification,
+		UserID:    user.ID,
+		UserName:  user.UserName,
+		Email:     user.Email,
+		Token:     token,
+		ExpiresAt: storedToken.ExpiresAt,
+	}))
+}
+
+// VerifyEmail : Mark the email address of the user as verified with a
+// token which was sent by SignUpWithEmail, SetEmail or
+// SendEmailVerification. The token can only be used once and is refused
+// if the email address of the user was changed in the meantime. Returns
+// the verified user.
+func (a *AuthHandler) VerifyEmail(token string) (user *User, error error) {
+	storedToken, error := a.getOneTimeToken(token, PurposeEmailVerification)
+	if error != nil {
+		return nil, error
+	}
+	if error = a.useOneTimeToken(storedToken); error != nil {
+		return nil, error
+	}
+
+	now := a.getTokenConfig().clock.Now()
+	_, user, error = a.updateUser(storedToken.UserID, a.verifyEmailAddress(storedToken.Email, now))
+	if errors.Is(error, ErrUserNotFound) {
+		return nil, a.newError(ErrInvalidToken, "Error : Email verification token is not valid!")
+	} else if error != nil {
+		return nil, error
+	}
+
+	return user, nil
+}
+
+// mark the email address as verified if the user still has this address
+func (a *AuthHandler) verifyEmailAddress(email string, verifiedAt time.Time) userUpdate {
+	return func(user *User) (error error) {
+		if user.Email != email {
+			return a.newError(ErrInvalidToken, "Error : Email verification token is not valid for the current email address!")
+		}
+		if user.IsEmailVerified() {
+			return a.newError(ErrTokenUsed, "Error : Email address is already verified!")
+		}
+
+		user.EmailVerifiedAt = verifiedAt
+		return nil
+	}
+}
+
+// check that the user verified the email address if this is required
+func (a *AuthHandler) checkEmailVerified(user *User) (error error) {
+	if !a.isEmailVerificationRequired() || user.IsEmailVerified() {
+		return nil
+	}
+
+	return a.newError(ErrEmailNotVerified, "Error : Please verify your email address first!")
+}
@@ -0,0 +1,66 @@
+package auth
+
+import (
+	"github.com/google/uuid"
+)
+
+// SetEnumerationProtection : Prevent that clients can find out which user
+// names exist. With the protection enabled
+//   - CheckIfUserNameIsFree returns ErrEnumerationProtected
+//   - SignUp with a taken user name does the same work as a successful sign
+//     up and returns neither a user nor an error instead of ErrUsernameTaken,
+//     so callers have to answer both cases in the same way, e.g. with
+//     "please check your inbox"
+//
+// Logins of unknown users are indistinguishable from wrong passwords
+// independent of this setting. Disabled by default.
+func (a *AuthHandler) SetEnumerationProtection(enabled bool) {
+	a.rulesMutex.Lock()
+	defer a.rulesMutex.Unlock()
+
+	a.enumerationProtection = enabled
+}
+
+// check if the enumeration protection is enabled
+func (a *AuthHandler) isEnumerationProtected() bool {
+	a.rulesMutex.RLock()
+	defer a.rulesMutex.RUnlock()
+
+	return a.enumerationProtection
+}
+
+// verify the password against a hash of a random password. Used for
+// unknown users, so that their logins take as long as logins with a wrong
+// password, as long as the hash of the user was created by the current
+// hasher with its current parameters.
+func (a *AuthHandler) verifyDummyPassword(password string) {
+	dummyHash := a.getDummyHash()
+	if dummyHash != "" {
+		a.verifyPassword(password, dummyHash)
+	}
+}
+
+// get the hash of a random password created by the current hasher. It is
+// created on first use, as hashing is slow, and after the hasher changed.
+func (a *AuthHandler) getDummyHash() string {
+	a.dummyHashMutex.Lock()
+	defer a.dummyHashMutex.Unlock()
+
+	if a.dummyHash == "" {
+		dummyHash, error := a.getPasswordHasher().Hash(uuid.New().String())
+		if a.logError(error) != nil {
+			return ""
+		}
+		a.dummyHash = dummyHash
+	}
+
+	return a.dummyHash
+}
+
+// drop the hash of the random password after the hasher changed
+func (a *AuthHandler) resetDummyHash() {
+	a.dummyHashMutex.Lock()
+	defer a.dummyHashMutex.Unlock()
+
+	a.dummyHash = ""
+}
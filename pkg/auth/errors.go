@@ -0,0 +1,87 @@
+package auth
+
+import (
+	"errors"
+	"net/http"
+)
+
+// Sentinel errors of the auth package. All errors returned by the
+// AuthHandler and the stores wrap one of them, so callers can check
+// for them with errors.Is instead of comparing error messages.
+var (
+	ErrInvalidInput       = errors.New("auth: invalid input")
+	ErrUserNotFound       = errors.New("auth: user not found")
+	ErrSessionNotFound    = errors.New("auth: session not found")
+	ErrUsernameTaken      = errors.New("auth: username already taken")
+	ErrEmailTaken         = errors.New("auth: email already taken")
+	ErrConflict           = errors.New("auth: concurrent modification")
+	ErrInvalidCredentials = errors.New("auth: invalid credentials")
+	ErrInvalidToken       = errors.New("auth: invalid token")
+	ErrNoSecret           = errors.New("auth: no secret for JWT generation set")
+	ErrInvalidKey         = errors.New("auth: invalid signing key")
+	ErrPolicyViolation    = errors.New("auth: policy violation")
+	ErrInvalidRule        = errors.New("auth: invalid rule")
+	ErrInvalidConfig      = errors.New("auth: invalid configuration")
+	ErrNotAllowed         = errors.New("auth: operation not allowed")
+	ErrRateLimited        = errors.New("auth: too many requests")
+	ErrAccountInactive    = errors.New("auth: account not active")
+	ErrStore              = errors.New("auth: store operation failed")
+	ErrInternal           = errors.New("auth: internal error")
+)
+
+// More specific errors which additionally match the more general
+// sentinel error they wrap, e.g. errors.Is(ErrTokenExpired, ErrInvalidToken)
+var (
+	ErrTokenExpired            = &Error{Kind: ErrInvalidToken, Message: "auth: token expired"}
+	ErrUnexpectedSigningMethod = &Error{Kind: ErrInvalidToken, Message: "auth: unexpected signing method"}
+	ErrUnknownKeyID            = &Error{Kind: ErrInvalidToken, Message: "auth: unknown key ID"}
+	ErrRefreshTokenReused      = &Error{Kind: ErrInvalidToken, Message: "auth: refresh token reused"}
+	ErrTokenRevoked            = &Error{Kind: ErrInvalidToken, Message: "auth: token revoked"}
+	ErrTokenUsed               = &Error{Kind: ErrInvalidToken, Message: "auth: token already used"}
+	ErrWeakSecret              = &Error{Kind: ErrInvalidKey, Message: "auth: secret too weak"}
+	ErrEnumerationProtected    = &Error{Kind: ErrNotAllowed, Message: "auth: user names can not be checked with enumeration protection"}
+	ErrAccountLocked           = &Error{Kind: ErrRateLimited, Message: "auth: account locked"}
+	ErrAccountPending          = &Error{Kind: ErrAccountInactive, Message: "auth: account pending"}
+	ErrAccountDisabled         = &Error{Kind: ErrAccountInactive, Message: "auth: account disabled"}
+	ErrAccountDeleted          = &Error{Kind: ErrAccountInactive, Message: "auth: account deleted"}
+	ErrInvalidStatusTransition = &Error{Kind: ErrInvalidInput, Message: "auth: invalid status transition"}
+	ErrInvalidEmail            = &Error{Kind: ErrInvalidInput, Message: "auth: invalid email address"}
+	ErrEmailNotVerified        = &Error{Kind: ErrNotAllowed, Message: "auth: email not verified"}
+)
+
+// Error : error with a human readable message which wraps one of the
+// sentinel errors of this package
+type Error struct {
+	Kind    error
+	Message string
+}
+
+func (e *Error) Error() string {
+	return e.Message
+}
+
+func (e *Error) Unwrap() error {
+	return e.Kind
+}
+
+// HTTPStatus : Map an error of this package to a fitting HTTP status code
+func HTTPStatus(error error) int {
+	switch {
+	case error == nil:
+		return http.StatusOK
+	case errors.Is(error, ErrInvalidInput), errors.Is(error, ErrPolicyViolation):
+		return http.StatusBadRequest
+	case errors.Is(error, ErrInvalidCredentials), errors.Is(error, ErrInvalidToken):
+		return http.StatusUnauthorized
+	case errors.Is(error, ErrUserNotFound), errors.Is(error, ErrSessionNotFound):
+		return http.StatusNotFound
+	case errors.Is(error, ErrUsernameTaken), errors.Is(error, ErrEmailTaken), errors.Is(error, ErrConflict):
+		return http.StatusConflict
+	case errors.Is(error, ErrNotAllowed), errors.Is(error, ErrAccountInactive):
+		return http.StatusForbidden
+	case errors.Is(error, ErrRateLimited):
+		return http.StatusTooManyRequests
+	default:
+		return http.StatusInternalServerError
+	}
+}
@@ -0,0 +1,135 @@
+package auth
+
+import (
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"math/big"
+	"net/http"
+)
+
+// JSONWebKey : public key in the JSON Web Key format (RFC 7517)
+type JSONWebKey struct {
+	KeyType   string `json:"kty"`
+	KeyID     string `json:"kid,omitempty"`
+	Use       string `json:"use,omitempty"`
+	Algorithm string `json:"alg,omitempty"`
+	N         string `json:"n,omitempty"`
+	E         string `json:"e,omitempty"`
+	Curve     string `json:"crv,omitempty"`
+	X         string `json:"x,omitempty"`
+	Y         string `json:"y,omitempty"`
+}
+
+// JSONWebKeySet : set of public keys in the JWKS format, which is served to
+// services verifying the tokens of an AuthHandler
+type JSONWebKeySet struct {
+	Keys []JSONWebKey `json:"keys"`
+}
+
+// JSONWebKey : Get the public key in the JSON Web Key format. HMAC keys
+// are secret and return false.
+func (k *SigningKey) JSONWebKey(keyID string) (jsonWebKey JSONWebKey, ok bool) {
+	jsonWebKey = JSONWebKey{KeyID: keyID, Use: "sig", Algorithm: k.Algorithm()}
+
+	switch publicKey := k.PublicKey().(type) {
+	case *rsa.PublicKey:
+		jsonWebKey.KeyType = "RSA"
+		jsonWebKey.N = encodeBase64URL(publicKey.N.Bytes())
+		jsonWebKey.E = encodeBase64URL(big.NewInt(int64(publicKey.E)).Bytes())
+	case *ecdsa.PublicKey:
+		size := (publicKey.Curve.Params().BitSize + 7) / 8
+		jsonWebKey.KeyType = "EC"
+		jsonWebKey.Curve = publicKey.Curve.Params().Name
+		jsonWebKey.X = encodeBase64URL(publicKey.X.FillBytes(make([]byte, size)))
+		jsonWebKey.Y = encodeBase64URL(publicKey.Y.FillBytes(make([]byte, size)))
+	case ed25519.PublicKey:
+		jsonWebKey.KeyType = "OKP"
+		jsonWebKey.Curve = "Ed25519"
+		jsonWebKey.X = encodeBase64URL(publicKey)
+	default:
+		return JSONWebKey{}, false
+	}
+
+	return jsonWebKey, true
+}
+
+// Thumbprint : Get the JWK thumbprint (RFC 7638) of the key, which is used
+// as key ID if no other ID is given
+func (k *SigningKey) Thumbprint() string {
+	// the required members of the key type ordered lexicographically
+	var members interface{}
+	if jsonWebKey, ok := k.JSONWebKey(""); !ok {
+		members = struct {
+			K   string `json:"k"`
+			Kty string `json:"kty"`
+		}{encodeBase64URL(k.verifyKey.([]byte)), "oct"}
+	} else if jsonWebKey.KeyType == "RSA" {
+		members = struct {
+			E   string `json:"e"`
+			Kty string `json:"kty"`
+			N   string `json:"n"`
+		}{jsonWebKey.E, jsonWebKey.KeyType, jsonWebKey.N}
+	} else if jsonWebKey.KeyType == "EC" {
+		members = struct {
+			Crv string `json:"crv"`
+			Kty string `json:"kty"`
+			X   string `json:"x"`
+			Y   string `json:"y"`
+		}{jsonWebKey.Curve, jsonWebKey.KeyType, jsonWebKey.X, jsonWebKey.Y}
+	} else {
+		members = struct {
+			Crv string `json:"crv"`
+			Kty string `json:"kty"`
+			X   string `json:"x"`
+		}{jsonWebKey.Curve, jsonWebKey.KeyType, jsonWebKey.X}
+	}
+
+	membersJSON, _ := json.Marshal(members)
+	thumbprint := sha256.Sum256(membersJSON)
+	return encodeBase64URL(thumbprint[:])
+}
+
+// JWKS : Get the public keys of the key ring which currently verify tokens.
+// Keys of a scheduled rotation are already contained, HMAC keys never.
+func (r *KeyRing) JWKS() *JSONWebKeySet {
+	jwks := &JSONWebKeySet{Keys: []JSONWebKey{}}
+	for _, key := range r.verificationKeys() {
+		if jsonWebKey, ok := key.key.JSONWebKey(key.keyID); ok {
+			jwks.Keys = append(jwks.Keys, jsonWebKey)
+		}
+	}
+	return jwks
+}
+
+// ServeHTTP : Serve the JWKS document of the key ring, e.g. under
+// /.well-known/jwks.json
+func (r *KeyRing) ServeHTTP(writer http.ResponseWriter, request *http.Request) {
+	if request.Method != http.MethodGet && request.Method != http.MethodHead {
+		writer.Header().Set("Allow", "GET, HEAD")
+		http.Error(writer, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+		return
+	}
+
+	writer.Header().Set("Content-Type", "application/jwk-set+json")
+	writer.Header().Set("Cache-Control", "public, max-age=300")
+	json.NewEncoder(writer).Encode(r.JWKS())
+}
+
+// JWKSHandler : Get an HTTP handler which serves the public keys of the
+// key ring of the AuthHandler as JWKS document. HMAC keys, e.g. of a
+// secret or a SecretProvider, are never published, so an empty set is
+// served if only HMAC keys are used.
+func (a *AuthHandler) JWKSHandler() http.Handler {
+	return http.HandlerFunc(func(writer http.ResponseWriter, request *http.Request) {
+		a.GetKeyRing().ServeHTTP(writer, request)
+	})
+}
+
+// encode bytes in the unpadded base64url format used by JWTs and JWKs
+func encodeBase64URL(value []byte) string {
+	return base64.RawURLEncoding.EncodeToString(value)
+}
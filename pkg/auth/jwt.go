@@ -0,0 +1,444 @@
+package auth
+
+import (
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/google/uuid"
+)
+
+// DefaultAccessTokenLifetime : lifetime of access tokens if nothing else is configured
+const DefaultAccessTokenLifetime = 15 * time.Minute
+
+// DefaultAllowedAlgorithms : signing algorithms which are accepted during
+// authentication if nothing else is configured
+var DefaultAllowedAlgorithms = []string{"HS256"}
+
+// Claims : claims of the access tokens generated by the AuthHandler.
+// The registered claim sub holds the ID of the user, sid the ID of the
+// session in which the token was issued.
+type Claims struct {
+	UserName  string `json:"UserName"`
+	SessionID string `json:"sid"`
+	jwt.RegisteredClaims
+}
+
+// Principal : user which was authenticated by a JWT together with the
+// claims of the JWT and the session of the JWT
+type Principal struct {
+	User    *User
+	Session *Session
+	Claims  *Claims
+}
+
+// tokenConfig : settings for generating and validating JWTs
+type tokenConfig struct {
+	accessTokenLifetime  time.Duration
+	refreshTokenLifetime time.Duration
+	issuer               string
+	audience             []string
+	clockSkew            time.Duration
+	clock                Clock
+	allowedAlgorithms    []string
+	keyRing              *KeyRing
+	secretProvider       SecretProvider
+	validationMode       ValidationMode
+
+	passwordResetTokenLifetime     time.Duration
+	emailVerificationTokenLifetime time.Duration
+}
+
+// ValidationMode : decides which state AuthenticateByJWT consults besides
+// the signature and the claims of a token
+type ValidationMode int
+
+const (
+	// StatefulValidation : the user and the session of the token have to
+	// exist and the token must not be revoked. This is the default.
+	StatefulValidation ValidationMode = iota
+	// StatelessValidation : the token must not be revoked, neither the
+	// user store nor the session store are used. The returned principal
+	// only contains the ID and the name of the user from the claims and no
+	// session, so that resource servers only need the verification keys
+	// and the revocation list.
+	StatelessValidation
+	// StatelessValidationWithoutRevocation : like StatelessValidation, but
+	// also the revocation list is not used. Tokens stay valid until they
+	// expire, so the access token lifetime should be short. LogOut still
+	// ends the session and revokes its refresh tokens.
+	StatelessValidationWithoutRevocation
+)
+
+// SetAccessTokenLifetime : Set how long generated access tokens are valid
+func (a *AuthHandler) SetAccessTokenLifetime(lifetime time.Duration) {
+	a.configMutex.Lock()
+	defer a.configMutex.Unlock()
+
+	a.tokenConfig.accessTokenLifetime = lifetime
+}
+
+// SetIssuer : Set the issuer (iss) which is written into generated tokens
+// and required for tokens during authentication. An empty issuer is
+// neither written nor checked.
+func (a *AuthHandler) SetIssuer(issuer string) {
+	a.configMutex.Lock()
+	defer a.configMutex.Unlock()
+
+	a.tokenConfig.issuer = issuer
+}
+
+// SetAudience : Set the audience (aud) which is written into generated tokens.
+// During authentication tokens have to contain at least one of the audiences.
+func (a *AuthHandler) SetAudience(audience ...string) {
+	a.configMutex.Lock()
+	defer a.configMutex.Unlock()
+
+	a.tokenConfig.audience = append([]string(nil), audience...)
+}
+
+// SetClockSkew : Set the tolerance which is allowed when checking the
+// time based claims exp, nbf and iat of tokens
+func (a *AuthHandler) SetClockSkew(clockSkew time.Duration) {
+	a.configMutex.Lock()
+	defer a.configMutex.Unlock()
+
+	a.tokenConfig.clockSkew = clockSkew
+}
+
+// SetClock : Set the clock which is used for generating and validating tokens
+func (a *AuthHandler) SetClock(clock Clock) {
+	a.configMutex.Lock()
+	defer a.configMutex.Unlock()
+
+	a.tokenConfig.clock = clock
+	if a.tokenConfig.secretProvider != nil {
+		a.tokenConfig.keyRing.SetClock(clock)
+		a.secretReloadMutex.Lock()
+		a.lastSecretReload = clock.Now()
+		a.secretReloadMutex.Unlock()
+	}
+}
+
+// SetAllowedAlgorithms : Set the signing algorithms (alg header) which are
+// accepted during authentication. Tokens with any other algorithm are
+// rejected with ErrUnexpectedSigningMethod. The algorithm none and unknown
+// algorithms can not be allowed. Setting a signing key or a key ring
+// resets the allowed algorithms to the algorithms of its keys.
+func (a *AuthHandler) SetAllowedAlgorithms(algorithms ...string) (error error) {
+	if len(algorithms) == 0 {
+		return a.newError(ErrInvalidInput, "Error : At least one signing algorithm has to be allowed!")
+	}
+
+	for _, algorithm := range algorithms {
+		if strings.EqualFold(algorithm, "none") || jwt.GetSigningMethod(algorithm) == nil {
+			return a.newError(ErrInvalidInput, "Error : Signing algorithm '"+algorithm+"' can not be allowed!")
+		}
+	}
+
+	a.configMutex.Lock()
+	defer a.configMutex.Unlock()
+
+	a.tokenConfig.allowedAlgorithms = append([]string(nil), algorithms...)
+
+	return nil
+}
+
+// SetSigningKey : Set the only key with which access tokens are signed and
+// verified. Its thumbprint is used as key ID. Only the algorithm of the key
+// is allowed afterwards. A handler with a verification-only key can
+// authenticate tokens but can not log in users.
+func (a *AuthHandler) SetSigningKey(signingKey *SigningKey) (error error) {
+	if signingKey == nil {
+		return a.newError(ErrInvalidKey, "Error : Signing key must not be nil!")
+	}
+
+	keyRing := NewKeyRing()
+	if signingKey.CanSign() {
+		error = keyRing.ScheduleRotation(signingKey.Thumbprint(), signingKey, time.Time{}, 0)
+	} else {
+		error = keyRing.AddVerificationKey(signingKey.Thumbprint(), signingKey)
+	}
+	if error != nil {
+		return a.logError(error)
+	}
+
+	return a.SetKeyRing(keyRing)
+}
+
+// SetKeyRing : Set the key ring whose signing key signs access tokens and
+// whose keys verify access tokens by their kid header. Only the algorithms
+// of the keys in the ring are allowed afterwards. A SecretProvider which
+// was set before is not used anymore.
+func (a *AuthHandler) SetKeyRing(keyRing *KeyRing) (error error) {
+	if keyRing == nil {
+		return a.newError(ErrInvalidKey, "Error : Key ring must not be nil!")
+	}
+
+	a.configMutex.Lock()
+	defer a.configMutex.Unlock()
+
+	a.tokenConfig.keyRing = keyRing
+	a.tokenConfig.allowedAlgorithms = nil
+	a.tokenConfig.secretProvider = nil
+
+	return nil
+}
+
+// GetKeyRing : Get the key ring of the AuthHandler, e.g. to rotate keys or
+// to publish them. If a SecretProvider is used, the ring is managed by the
+// AuthHandler and contains the keys of the current and the previous secret.
+func (a *AuthHandler) GetKeyRing() *KeyRing {
+	return a.getTokenConfig().keyRing
+}
+
+// SetValidationMode : Set which state is consulted by AuthenticateByJWT
+func (a *AuthHandler) SetValidationMode(validationMode ValidationMode) {
+	a.configMutex.Lock()
+	defer a.configMutex.Unlock()
+
+	a.tokenConfig.validationMode = validationMode
+}
+
+// get a consistent copy of the token settings
+func (a *AuthHandler) getTokenConfig() tokenConfig {
+	a.configMutex.RLock()
+	defer a.configMutex.RUnlock()
+
+	return a.tokenConfig
+}
+
+// AuthenticateByJWT : Check if JWT is valid and belongs to a known user.
+// Signature, exp, nbf, iat, iss and aud are validated. On success the
+// authenticated user and the claims of the JWT are returned. What else is
+// checked depends on the ValidationMode.
+func (a *AuthHandler) AuthenticateByJWT(JWT string) (*Principal, error) {
+	config := a.getTokenConfig()
+	keyRing, keyError := a.getKeyRing(config)
+	if keyError != nil {
+		return nil, keyError
+	}
+
+	// try to parse JWT / check if JWT is in a valid format
+	// and all registered claims are valid
+	claims := new(Claims)
+	parseError := a.parseJWT(config, keyRing, JWT, claims)
+	if parseError == ErrUnknownKeyID {
+		return nil, a.newError(ErrUnknownKeyID, "Error : Authentication Failed. JWT AccessToken was signed with an unknown or retired key!")
+	} else if parseError == ErrUnexpectedSigningMethod {
+		return nil, a.newError(ErrUnexpectedSigningMethod, "Error : Authentication Failed. JWT AccessToken uses an unexpected signing method!")
+	} else if parseError == ErrTokenExpired {
+		return nil, a.newError(ErrTokenExpired, "Error : Authentication Failed. JWT AccessToken is expired!")
+	} else if parseError != nil || claims.Subject == "" || claims.ID == "" || claims.SessionID == "" {
+		return nil, a.newError(ErrInvalidToken, "Error : Authentication Failed. JWT AccessToken is not valid!")
+	}
+
+	// the token must not be on the revocation list
+	if config.validationMode != StatelessValidationWithoutRevocation {
+		if revocationError := a.checkRevocation(claims); revocationError != nil {
+			return nil, revocationError
+		}
+	}
+
+	// in stateless mode the valid token is accepted on its own
+	if config.validationMode != StatefulValidation {
+		user := &User{ID: claims.Subject, UserName: claims.UserName}
+		return &Principal{User: user, Claims: claims}, nil
+	}
+
+	// try to get the user of the claims. If the user is not
+	// existing there seems to be something wrong with the claims.
+	user, errorFindingUser := a.userStore.GetUserByID(claims.Subject)
+	if errorFindingUser != nil {
+		return nil, a.newError(ErrInvalidToken, "Error : Authentication Failed. JWT AccessToken is not valid!")
+	}
+	if statusError := a.checkUserStatus(user); statusError != nil {
+		return nil, statusError
+	}
+	if passwordError := a.checkPasswordChange(user, claims); passwordError != nil {
+		return nil, passwordError
+	}
+
+	// the session of the JWT must not be revoked or expired
+	now := config.clock.Now()
+	session, sessionError := a.getActiveSession(claims.SessionID, user.ID, now)
+	if sessionError != nil {
+		return nil, a.newError(ErrInvalidToken, "Error : Authentication Failed. Session of JWT AccessToken is not valid anymore!")
+	}
+	if touchError := a.touchSession(session, now); touchError != nil {
+		return nil, touchError
+	}
+
+	return &Principal{User: user, Session: session, Claims: claims}, nil
+}
+
+// parse the JWT into the claims and check its signature with the key
+// ring and all registered claims. Returns ErrUnknownKeyID,
+// ErrUnexpectedSigningMethod, ErrTokenExpired or ErrInvalidToken without
+// logging them, so that callers can add a message for their kind of token.
+func (a *AuthHandler) parseJWT(config tokenConfig, keyRing *KeyRing, JWT string, claims jwt.Claims) (error error) {
+	allowedAlgorithms := config.allowedAlgorithms
+	if allowedAlgorithms == nil {
+		allowedAlgorithms = keyRing.algorithms()
+	}
+
+	token, parseError := a.newJWTParser(config).ParseWithClaims(JWT, claims, verificationKeyFunc(keyRing, allowedAlgorithms))
+
+	switch {
+	case parseError == nil:
+		return nil
+	case errors.Is(parseError, errUnknownKeyID):
+		return ErrUnknownKeyID
+	case errors.Is(parseError, errUnexpectedSigningMethod), token != nil && !isAllowedAlgorithm(token, allowedAlgorithms):
+		return ErrUnexpectedSigningMethod
+	case errors.Is(parseError, jwt.ErrTokenExpired):
+		return ErrTokenExpired
+	default:
+		return ErrInvalidToken
+	}
+}
+
+// get the keyfunc which selects the key of the key ring for a token
+func verificationKeyFunc(keyRing *KeyRing, allowedAlgorithms []string) jwt.Keyfunc {
+	return func(token *jwt.Token) (interface{}, error) {
+		// only return the key if the token uses an allowed algorithm
+		// which is exactly the algorithm of the key, so that e.g. a
+		// public key can never be used as HMAC secret
+		signingKey := keyForToken(keyRing, token)
+		if signingKey == nil {
+			return nil, errUnknownKeyID
+		}
+		if !isAllowedAlgorithm(token, allowedAlgorithms) || !signingKey.matches(token) {
+			return nil, errUnexpectedSigningMethod
+		}
+		return signingKey.verifyKey, nil
+	}
+}
+
+// returned by the keyfunc if a token does not use an allowed algorithm
+// or if the key of the token is unknown
+var (
+	errUnexpectedSigningMethod = errors.New("unexpected signing method")
+	errUnknownKeyID            = errors.New("unknown key ID")
+)
+
+// get the key which verifies the token by its kid header. Tokens
+// without kid header are verified with the current signing key.
+func keyForToken(keyRing *KeyRing, token *jwt.Token) *SigningKey {
+	keyID, hasKeyID := token.Header["kid"].(string)
+	if !hasKeyID {
+		_, signingKey := keyRing.signingKey()
+		return signingKey
+	}
+
+	return keyRing.verificationKey(keyID)
+}
+
+// check if the alg header of the token is one of the allowed algorithms
+func isAllowedAlgorithm(token *jwt.Token, allowedAlgorithms []string) bool {
+	algorithm, _ := token.Header["alg"].(string)
+	for _, allowedAlgorithm := range allowedAlgorithms {
+		if algorithm == allowedAlgorithm && token.Method != nil && token.Method.Alg() == allowedAlgorithm {
+			return true
+		}
+	}
+
+	return false
+}
+
+// create a parser which validates all registered claims
+func (a *AuthHandler) newJWTParser(config tokenConfig) *jwt.Parser {
+	options := []jwt.ParserOption{
+		jwt.WithTimeFunc(config.clock.Now),
+		jwt.WithLeeway(config.clockSkew),
+		jwt.WithExpirationRequired(),
+		jwt.WithIssuedAt(),
+	}
+	if config.issuer != "" {
+		options = append(options, jwt.WithIssuer(config.issuer))
+	}
+	if len(config.audience) > 0 {
+		options = append(options, jwt.WithAudience(config.audience...))
+	}
+
+	return jwt.NewParser(options...)
+}
+
+// get the configured key ring, a ring of a SecretProvider
+// is updated before if its secret changed
+func (a *AuthHandler) getKeyRing(config tokenConfig) (keyRing *KeyRing, error error) {
+	if config.keyRing == nil {
+		return nil, a.newError(ErrNoSecret, "Error : No Secret or signing key for JWT generation set!")
+	}
+
+	if config.secretProvider != nil {
+		a.reloadSecret(config)
+	}
+
+	return config.keyRing, nil
+}
+
+// GenerateJWT : generate and sign an access token for the user which
+// belongs to the session
+func (a *AuthHandler) GenerateJWT(user *User, session *Session) (signedToken string, error error) {
+	signedToken, _, error = a.generateAccessToken(user, session)
+	return signedToken, error
+}
+
+// generate and sign an access token and return its claims as well
+func (a *AuthHandler) generateAccessToken(user *User, session *Session) (signedToken string, claims *Claims, error error) {
+	config := a.getTokenConfig()
+
+	// get key, if no key is set or no key can sign exit with error
+	keyRing, error := a.getKeyRing(config)
+	if error != nil {
+		return "", nil, error
+	}
+	keyID, signingKey := keyRing.signingKey()
+	if signingKey == nil {
+		return "", nil, a.newError(ErrNoSecret, "Error : No active key for JWT generation set!")
+	}
+
+	// create token
+	claims = &Claims{
+		UserName:         user.UserName,
+		SessionID:        session.ID,
+		RegisteredClaims: newRegisteredClaims(config, user, config.accessTokenLifetime),
+	}
+
+	// sign token
+	signedToken, error = a.signJWT(keyID, signingKey, claims)
+	if error != nil {
+		return "", nil, error
+	}
+
+	return signedToken, claims, nil
+}
+
+// create the registered claims of a new token for the user
+func newRegisteredClaims(config tokenConfig, user *User, lifetime time.Duration) jwt.RegisteredClaims {
+	now := config.clock.Now()
+	return jwt.RegisteredClaims{
+		Issuer:    config.issuer,
+		Subject:   user.ID,
+		Audience:  config.audience,
+		ExpiresAt: jwt.NewNumericDate(now.Add(lifetime)),
+		NotBefore: jwt.NewNumericDate(now),
+		IssuedAt:  jwt.NewNumericDate(now),
+		ID:        uuid.New().String(),
+	}
+}
+
+// sign the claims with the key and add the ID of the key as kid header
+func (a *AuthHandler) signJWT(keyID string, signingKey *SigningKey, claims jwt.Claims) (signedToken string, error error) {
+	token := jwt.NewWithClaims(signingKey.method, claims)
+	token.Header["kid"] = keyID
+
+	signedToken, signError := token.SignedString(signingKey.signKey)
+	if signError != nil {
+		return "", a.newError(ErrInternal, "Error : Unable to sign JWT : "+signError.Error())
+	}
+
+	return signedToken, nil
+}
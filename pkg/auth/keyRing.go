@@ -0,0 +1,231 @@
+package auth
+
+import (
+	"sort"
+	"sync"
+	"time"
+)
+
+// KeyRing : keys of an AuthHandler identified by their key ID (kid). One
+// key signs new tokens, all keys which are not retired verify tokens, so
+// that a key can be rotated without invalidating the tokens which were
+// signed with the previous key. A KeyRing is safe for concurrent use.
+type KeyRing struct {
+	mutex sync.RWMutex
+	clock Clock
+	keys  []*ringKey
+}
+
+// key of a KeyRing together with the time window in which it is used
+type ringKey struct {
+	keyID string
+	key   *SigningKey
+
+	// keys which are not signing only verify tokens
+	signing bool
+
+	// a signing key signs new tokens from this time on until
+	// another signing key is activated
+	activatesAt time.Time
+
+	// the key does not verify tokens from this time on,
+	// zero if the key is not retired yet
+	retiresAt time.Time
+}
+
+// NewKeyRing : Create a new empty KeyRing
+func NewKeyRing() *KeyRing {
+	return &KeyRing{clock: SystemClock{}}
+}
+
+// SetClock : Set the clock which decides which keys are active
+func (r *KeyRing) SetClock(clock Clock) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	r.clock = clock
+}
+
+// AddVerificationKey : Add a key which only verifies tokens, e.g. the
+// public key of another issuer or of a key which will be rotated in later
+func (r *KeyRing) AddVerificationKey(keyID string, key *SigningKey) (error error) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	if error = r.checkNewKey(keyID, key); error != nil {
+		return error
+	}
+	r.keys = append(r.keys, &ringKey{keyID: keyID, key: key})
+
+	return nil
+}
+
+// Rotate : Sign new tokens with the given key from now on. The previous
+// signing keys still verify tokens during the overlap, which should be at
+// least the lifetime of the access tokens, and are retired afterwards.
+func (r *KeyRing) Rotate(keyID string, key *SigningKey, overlap time.Duration) (error error) {
+	return r.ScheduleRotation(keyID, key, r.now(), overlap)
+}
+
+// ScheduleRotation : Sign new tokens with the given key from activatesAt
+// on. The key verifies tokens and is published immediately, so that
+// verifiers can pick it up before the first token is signed with it. The
+// previous signing keys are retired at activatesAt plus overlap.
+func (r *KeyRing) ScheduleRotation(keyID string, key *SigningKey, activatesAt time.Time, overlap time.Duration) (error error) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	if error = r.checkNewKey(keyID, key); error != nil {
+		return error
+	}
+	if !key.CanSign() {
+		return newError(ErrInvalidKey, "Error : Key '"+keyID+"' can only verify JWTs and can not be rotated in!")
+	}
+	if overlap < 0 {
+		return newError(ErrInvalidInput, "Error : Overlap of a key rotation must not be negative!")
+	}
+
+	for _, previousKey := range r.keys {
+		if previousKey.signing && previousKey.retiresAt.IsZero() && !previousKey.activatesAt.After(activatesAt) {
+			previousKey.retiresAt = activatesAt.Add(overlap)
+		}
+	}
+	r.keys = append(r.keys, &ringKey{keyID: keyID, key: key, signing: true, activatesAt: activatesAt})
+	r.prune(r.clock.Now())
+
+	return nil
+}
+
+// RemoveKey : Remove a key immediately, e.g. because it was compromised.
+// All tokens which were signed with it are not valid anymore.
+func (r *KeyRing) RemoveKey(keyID string) (error error) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	for i, key := range r.keys {
+		if key.keyID == keyID {
+			r.keys = append(r.keys[:i], r.keys[i+1:]...)
+			return nil
+		}
+	}
+
+	return newError(ErrInvalidKey, "Error : No key found for key ID '"+keyID+"'!")
+}
+
+// Prune : Remove all keys which are retired
+func (r *KeyRing) Prune() {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	r.prune(r.clock.Now())
+}
+
+// SigningKeyID : Get the ID of the key which currently signs new tokens.
+// An empty string is returned if no key is active.
+func (r *KeyRing) SigningKeyID() string {
+	keyID, _ := r.signingKey()
+	return keyID
+}
+
+// KeyIDs : Get the IDs of all keys which currently verify tokens
+func (r *KeyRing) KeyIDs() (keyIDs []string) {
+	for _, key := range r.verificationKeys() {
+		keyIDs = append(keyIDs, key.keyID)
+	}
+	return keyIDs
+}
+
+// get the key which currently signs new tokens, if several keys
+// are active the one which was activated last is used
+func (r *KeyRing) signingKey() (keyID string, signingKey *SigningKey) {
+	r.mutex.RLock()
+	defer r.mutex.RUnlock()
+
+	now := r.clock.Now()
+	var current *ringKey
+	for _, key := range r.keys {
+		if key.signing && isUsable(key, now) && !key.activatesAt.After(now) {
+			if current == nil || !key.activatesAt.Before(current.activatesAt) {
+				current = key
+			}
+		}
+	}
+	if current == nil {
+		return "", nil
+	}
+
+	return current.keyID, current.key
+}
+
+// get the key with the given ID if it currently verifies tokens
+func (r *KeyRing) verificationKey(keyID string) *SigningKey {
+	for _, key := range r.verificationKeys() {
+		if key.keyID == keyID {
+			return key.key
+		}
+	}
+	return nil
+}
+
+// get all keys which currently verify tokens sorted by their ID
+func (r *KeyRing) verificationKeys() (keys []*ringKey) {
+	r.mutex.RLock()
+	defer r.mutex.RUnlock()
+
+	now := r.clock.Now()
+	for _, key := range r.keys {
+		if isUsable(key, now) {
+			keys = append(keys, key)
+		}
+	}
+	sort.Slice(keys, func(i, j int) bool { return keys[i].keyID < keys[j].keyID })
+
+	return keys
+}
+
+// get the algorithms of all keys which currently verify tokens
+func (r *KeyRing) algorithms() (algorithms []string) {
+	for _, key := range r.verificationKeys() {
+		algorithms = append(algorithms, key.key.Algorithm())
+	}
+	return algorithms
+}
+
+// check if the key is not retired yet
+func isUsable(key *ringKey, now time.Time) bool {
+	return key.retiresAt.IsZero() || now.Before(key.retiresAt)
+}
+
+// remove all retired keys, the caller has to hold the lock
+func (r *KeyRing) prune(now time.Time) {
+	keys := r.keys[:0]
+	for _, key := range r.keys {
+		if isUsable(key, now) {
+			keys = append(keys, key)
+		}
+	}
+	r.keys = keys
+}
+
+// check that a new key is set and its ID is not used yet,
+// the caller has to hold the lock
+func (r *KeyRing) checkNewKey(keyID string, key *SigningKey) (error error) {
+	if keyID == "" || key == nil {
+		return newError(ErrInvalidKey, "Error : Please enter a valid key ID and key!")
+	}
+	for _, existingKey := range r.keys {
+		if existingKey.keyID == keyID {
+			return newError(ErrInvalidKey, "Error : Key ID '"+keyID+"' already used. Please choose a different key ID!")
+		}
+	}
+
+	return nil
+}
+
+// get the current time of the clock of the key ring
+func (r *KeyRing) now() time.Time {
+	r.mutex.RLock()
+	defer r.mutex.RUnlock()
+
+	return r.clock.Now()
+}
@@ -0,0 +1,164 @@
+package auth
+
+import (
+	"crypto/sha1"
+	"crypto/sha256"
+	"crypto/sha512"
+	"crypto/subtle"
+	"fmt"
+	"hash"
+	"strings"
+
+	"golang.org/x/crypto/pbkdf2"
+)
+
+// PBKDF2Hasher : PasswordHasher which uses PBKDF2 with HMAC-SHA1,
+// HMAC-SHA256 or HMAC-SHA512, mainly to verify hashes which were imported
+// from other systems. Hashes are written in the PHC string format, e.g.
+// $pbkdf2-sha256$i=600000$salt$hash
+type PBKDF2Hasher struct {
+	// HashFunction : sha1, sha256 or sha512
+	HashFunction string
+	Iterations   int
+	SaltLength   uint32
+	KeyLength    int
+}
+
+// NewPBKDF2Hasher : Create a PBKDF2 hasher with the parameters
+// recommended by OWASP: HMAC-SHA256 with 600000 iterations
+func NewPBKDF2Hasher() *PBKDF2Hasher {
+	return &PBKDF2Hasher{HashFunction: "sha256", Iterations: 600000, SaltLength: 16, KeyLength: 32}
+}
+
+// Hash : Hash the password with the parameters of the hasher
+func (h *PBKDF2Hasher) Hash(password string) (hashedPassword string, error error) {
+	hashFunction := pbkdf2HashFunction(h.HashFunction)
+	if hashFunction == nil || checkPBKDF2Parameters(h.Iterations, h.KeyLength) != nil {
+		return "", newError(ErrInternal, "Error : Invalid PBKDF2 parameters!")
+	}
+	salt, error := generateSalt(h.SaltLength)
+	if error != nil {
+		return "", error
+	}
+
+	key := pbkdf2.Key([]byte(password), salt, h.Iterations, h.KeyLength, hashFunction)
+	return encodePBKDF2Hash(h.HashFunction, h.Iterations, salt, key), nil
+}
+
+// Supports : Check if the hash is a PBKDF2 hash
+func (h *PBKDF2Hasher) Supports(hashedPassword string) bool {
+	return strings.HasPrefix(hashedPassword, "$pbkdf2-")
+}
+
+// Verify : Check if the password matches the PBKDF2 hash with the parameters of the hash
+func (h *PBKDF2Hasher) Verify(password string, hashedPassword string) (matches bool, error error) {
+	parameters, salt, key, error := parsePBKDF2Hash(hashedPassword)
+	if error != nil {
+		return false, error
+	}
+
+	otherKey := pbkdf2.Key([]byte(password), salt, parameters.Iterations, len(key), pbkdf2HashFunction(parameters.HashFunction))
+	return subtle.ConstantTimeCompare(key, otherKey) == 1, nil
+}
+
+// NeedsRehash : Check if the hash was created with other parameters
+func (h *PBKDF2Hasher) NeedsRehash(hashedPassword string) bool {
+	parameters, salt, key, error := parsePBKDF2Hash(hashedPassword)
+	return error != nil || parameters.HashFunction != h.HashFunction || parameters.Iterations != h.Iterations ||
+		uint32(len(salt)) != h.SaltLength || len(key) != h.KeyLength
+}
+
+// write a PBKDF2 hash in the PHC string format
+func encodePBKDF2Hash(hashFunction string, iterations int, salt []byte, key []byte) string {
+	return fmt.Sprintf("$pbkdf2-%s$i=%d$%s$%s", hashFunction, iterations, encodePHCBase64(salt), encodePHCBase64(key))
+}
+
+// split a PBKDF2 hash in the PHC string format into its parts
+func parsePBKDF2Hash(hashedPassword string) (parameters *PBKDF2Hasher, salt []byte, key []byte, error error) {
+	parts := strings.Split(hashedPassword, "$")
+	if len(parts) != 5 || !strings.HasPrefix(parts[1], "pbkdf2-") {
+		return nil, nil, nil, newError(ErrInternal, "Error : Malformed PBKDF2 hash!")
+	}
+
+	parameters = &PBKDF2Hasher{HashFunction: strings.TrimPrefix(parts[1], "pbkdf2-")}
+	_, parameterError := fmt.Sscanf(parts[2], "i=%d", &parameters.Iterations)
+	salt, saltError := decodePHCBase64(parts[3])
+	key, keyError := decodePHCBase64(parts[4])
+	if parameterError != nil || saltError != nil || keyError != nil {
+		return nil, nil, nil, newError(ErrInternal, "Error : Malformed PBKDF2 hash!")
+	}
+	if pbkdf2HashFunction(parameters.HashFunction) == nil {
+		return nil, nil, nil, newError(ErrInternal, "Error : Unsupported PBKDF2 hash function '"+parameters.HashFunction+"'!")
+	}
+	if error = checkPBKDF2Parameters(parameters.Iterations, len(key)); error != nil {
+		return nil, nil, nil, error
+	}
+
+	return parameters, salt, key, nil
+}
+
+// check that the iterations and the key length are within the limits
+func checkPBKDF2Parameters(iterations int, keyLength int) (error error) {
+	if iterations < 1 || iterations > maxPBKDF2Iterations || keyLength < 1 || keyLength > maxKeyLength {
+		return newError(ErrInternal, fmt.Sprintf("Error : Invalid PBKDF2 parameters i=%d!", iterations))
+	}
+
+	return nil
+}
+
+// get the hash function of PBKDF2 for its name, nil if it is not supported
+func pbkdf2HashFunction(name string) func() hash.Hash {
+	switch name {
+	case "sha1":
+		return sha1.New
+	case "sha256":
+		return sha256.New
+	case "sha512":
+		return sha512.New
+	default:
+		return nil
+	}
+}
+
+// SaltedSHA256Hasher : PasswordHasher which only verifies hashes which
+// were imported from legacy systems. The hash is SHA-256 over the salt
+// followed by the password and is written as $sha256-salted$salt$hash.
+// A single round of SHA-256 is far too fast for passwords, so new hashes
+// can not be created and every hash is replaced on the next login.
+type SaltedSHA256Hasher struct{}
+
+// Hash : Refuse to create a new salted SHA-256 hash
+func (h *SaltedSHA256Hasher) Hash(password string) (hashedPassword string, error error) {
+	return "", newError(ErrInternal, "Error : Salted SHA-256 is only supported to verify imported hashes!")
+}
+
+// Supports : Check if the hash is a salted SHA-256 hash
+func (h *SaltedSHA256Hasher) Supports(hashedPassword string) bool {
+	return strings.HasPrefix(hashedPassword, "$sha256-salted$")
+}
+
+// Verify : Check if the password matches the salted SHA-256 hash
+func (h *SaltedSHA256Hasher) Verify(password string, hashedPassword string) (matches bool, error error) {
+	parts := strings.Split(hashedPassword, "$")
+	if len(parts) != 4 || parts[1] != "sha256-salted" {
+		return false, newError(ErrInternal, "Error : Malformed salted SHA-256 hash!")
+	}
+	salt, saltError := decodePHCBase64(parts[2])
+	digest, digestError := decodePHCBase64(parts[3])
+	if saltError != nil || digestError != nil || len(digest) != sha256.Size {
+		return false, newError(ErrInternal, "Error : Malformed salted SHA-256 hash!")
+	}
+
+	otherDigest := sha256.Sum256(append(salt, password...))
+	return subtle.ConstantTimeCompare(digest, otherDigest[:]) == 1, nil
+}
+
+// NeedsRehash : Salted SHA-256 hashes always have to be replaced
+func (h *SaltedSHA256Hasher) NeedsRehash(hashedPassword string) bool {
+	return true
+}
+
+// write a salted SHA-256 hash in the format of the SaltedSHA256Hasher
+func encodeSaltedSHA256Hash(salt []byte, digest []byte) string {
+	return "$sha256-salted$" + encodePHCBase64(salt) + "$" + encodePHCBase64(digest)
+}
@@ -0,0 +1,130 @@
+package auth
+
+import (
+	"database/sql"
+	"embed"
+	"fmt"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// all schema migrations of the SQL stores. The file names
+// have to start with the version number of the migration
+// followed by an underscore, e.g. 0001_create_users.sql
+//
+//go:embed migrations/*.sql
+var migrationFiles embed.FS
+
+// Migration : single versioned change of the database schema
+type Migration struct {
+	Version int
+	Name    string
+	SQL     string
+}
+
+// Migrations : Get all embedded migrations sorted by version
+func Migrations() (migrations []Migration, error error) {
+	fileNames, error := migrationFiles.ReadDir("migrations")
+	if error != nil {
+		return nil, newError(ErrStore, "Error : Unable to read migrations : "+error.Error())
+	}
+
+	for _, fileName := range fileNames {
+		name := strings.TrimSuffix(fileName.Name(), ".sql")
+
+		// parse version from the prefix of the file name
+		versionString := strings.SplitN(name, "_", 2)[0]
+		version, parseError := strconv.Atoi(versionString)
+		if parseError != nil {
+			return nil, newError(ErrStore, "Error : Invalid migration file name '"+fileName.Name()+"' !")
+		}
+
+		content, readError := migrationFiles.ReadFile(path.Join("migrations", fileName.Name()))
+		if readError != nil {
+			return nil, newError(ErrStore, "Error : Unable to read migration '"+fileName.Name()+"' : "+readError.Error())
+		}
+
+		migrations = append(migrations, Migration{Version: version, Name: name, SQL: string(content)})
+	}
+
+	sort.Slice(migrations, func(i, j int) bool { return migrations[i].Version < migrations[j].Version })
+
+	return migrations, nil
+}
+
+// Migrate : Apply all embedded migrations to the given database which
+// were not applied yet. Every migration runs in its own transaction and
+// is recorded in the schema_migrations table.
+func Migrate(db *sql.DB) (error error) {
+	_, error = db.Exec(`CREATE TABLE IF NOT EXISTS schema_migrations (
+		version INTEGER PRIMARY KEY,
+		name    TEXT NOT NULL
+	)`)
+	if error != nil {
+		return newError(ErrStore, "Error : Unable to create schema_migrations table : "+error.Error())
+	}
+
+	migrations, error := Migrations()
+	if error != nil {
+		return error
+	}
+
+	for _, migration := range migrations {
+		error = applyMigration(db, migration)
+		if error != nil {
+			return error
+		}
+	}
+
+	return nil
+}
+
+// SchemaVersion : Get the version of the latest migration applied to the database
+func SchemaVersion(db *sql.DB) (version int, error error) {
+	var latestVersion sql.NullInt64
+
+	error = db.QueryRow("SELECT MAX(version) FROM schema_migrations").Scan(&latestVersion)
+	if error != nil {
+		return 0, newError(ErrStore, "Error : Unable to read schema version : "+error.Error())
+	}
+
+	return int(latestVersion.Int64), nil
+}
+
+// apply a single migration if it is not recorded as applied yet
+func applyMigration(db *sql.DB, migration Migration) (error error) {
+	tx, error := db.Begin()
+	if error != nil {
+		return newError(ErrStore, "Error : Unable to start transaction for migration '"+migration.Name+"' : "+error.Error())
+	}
+	defer tx.Rollback()
+
+	// skip migration if it was already applied
+	var count int
+	error = tx.QueryRow("SELECT COUNT(*) FROM schema_migrations WHERE version = ?", migration.Version).Scan(&count)
+	if error != nil {
+		return newError(ErrStore, "Error : Unable to check migration '"+migration.Name+"' : "+error.Error())
+	}
+	if count > 0 {
+		return nil
+	}
+
+	_, error = tx.Exec(migration.SQL)
+	if error != nil {
+		return newError(ErrStore, fmt.Sprintf("Error : Migration '%s' failed : %s", migration.Name, error.Error()))
+	}
+
+	_, error = tx.Exec("INSERT INTO schema_migrations (version, name) VALUES (?, ?)", migration.Version, migration.Name)
+	if error != nil {
+		return newError(ErrStore, "Error : Unable to record migration '"+migration.Name+"' : "+error.Error())
+	}
+
+	error = tx.Commit()
+	if error != nil {
+		return newError(ErrStore, "Error : Unable to commit migration '"+migration.Name+"' : "+error.Error())
+	}
+
+	return nil
+}
@@ -0,0 +1,8 @@
+CREATE TABLE users (
+	id              TEXT PRIMARY KEY,
+	user_name       TEXT NOT NULL,
+	hashed_password TEXT NOT NULL,
+	access_token    TEXT NOT NULL DEFAULT ''
+);
+
+CREATE UNIQUE INDEX users_user_name_idx ON users (user_name);
@@ -0,0 +1,4 @@
+ALTER TABLE users ADD COLUMN email TEXT NOT NULL DEFAULT '';
+ALTER TABLE users ADD COLUMN email_verified_at INTEGER NOT NULL DEFAULT 0;
+
+CREATE UNIQUE INDEX users_email_idx ON users (email) WHERE email <> '';
@@ -0,0 +1,15 @@
+CREATE TRIGGER users_email_like_user_name_insert BEFORE INSERT ON users
+WHEN EXISTS (SELECT 1 FROM users WHERE id <> NEW.id AND (
+	(NEW.email <> '' AND user_name LIKE '%@%' AND lower(user_name) = NEW.email) OR
+	(NEW.user_name LIKE '%@%' AND (email = lower(NEW.user_name) OR (user_name LIKE '%@%' AND lower(user_name) = lower(NEW.user_name))))))
+BEGIN
+	SELECT RAISE(ABORT, 'email address or user name conflicts with another user');
+END;
+
+CREATE TRIGGER users_email_like_user_name_update BEFORE UPDATE OF user_name, email ON users
+WHEN EXISTS (SELECT 1 FROM users WHERE id <> NEW.id AND (
+	(NEW.email <> '' AND user_name LIKE '%@%' AND lower(user_name) = NEW.email) OR
+	(NEW.user_name LIKE '%@%' AND (email = lower(NEW.user_name) OR (user_name LIKE '%@%' AND lower(user_name) = lower(NEW.user_name))))))
+BEGIN
+	SELECT RAISE(ABORT, 'email address or user name conflicts with another user');
+END;
@@ -0,0 +1,95 @@
+package auth
+
+import (
+	"encoding/json"
+	"os"
+	"sync"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// NotificationKind : reason why a user is notified
+type NotificationKind string
+
+const (
+	// NotificationPasswordReset : the user requested to reset the password,
+	// the token has to be passed to ResetPassword
+	NotificationPasswordReset NotificationKind = "password_reset"
+	// NotificationEmailVerification : the user has to verify the email
+	// address, the token has to be passed to VerifyEmail
+	NotificationEmailVerification NotificationKind = "email_verification"
+)
+
+// Notification : message with a one-time token for a user
+type Notification struct {
+	Kind      NotificationKind `json:"kind"`
+	UserID    string           `json:"user_id"`
+	UserName  string           `json:"user_name"`
+	Email     string           `json:"email,omitempty"`
+	Token     string           `json:"token"`
+	ExpiresAt time.Time        `json:"expires_at"`
+}
+
+// Notifier : delivers notifications to users, e.g. by email. Deliveries
+// should be queued instead of being sent while Notify is called, so that
+// the response time of RequestPasswordReset does not reveal which user
+// names or email addresses exist.
+type Notifier interface {
+	Notify(notification Notification) (error error)
+}
+
+// LogNotifier : Notifier for local testing which writes the notifications
+// including their tokens to a logger. Must not be used in production.
+type LogNotifier struct {
+	Logger log.FieldLogger
+}
+
+// Notify : Write the notification to the logger
+func (n *LogNotifier) Notify(notification Notification) (error error) {
+	n.Logger.WithFields(log.Fields{
+		"kind":       notification.Kind,
+		"user_id":    notification.UserID,
+		"user_name":  notification.UserName,
+		"email":      notification.Email,
+		"token":      notification.Token,
+		"expires_at": notification.ExpiresAt,
+	}).Info("Notification for user '" + notification.UserName + "'")
+
+	return nil
+}
+
+// FileNotifier : Notifier for local testing which appends every
+// notification as a line of JSON to a file. Must not be used in production.
+type FileNotifier struct {
+	Path  string
+	mutex sync.Mutex
+}
+
+// NewFileNotifier : Create a notifier which appends to the file at the path
+func NewFileNotifier(path string) *FileNotifier {
+	return &FileNotifier{Path: path}
+}
+
+// Notify : Append the notification to the file
+func (n *FileNotifier) Notify(notification Notification) (error error) {
+	line, error := json.Marshal(notification)
+	if error != nil {
+		return newError(ErrInternal, "Error : Unable to encode notification : "+error.Error())
+	}
+
+	n.mutex.Lock()
+	defer n.mutex.Unlock()
+
+	file, error := os.OpenFile(n.Path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0600)
+	if error != nil {
+		return newError(ErrInternal, "Error : Unable to open notification file : "+error.Error())
+	}
+	defer file.Close()
+
+	if _, error = file.Write(append(line, '\n')); error != nil {
+		return newError(ErrInternal, "Error : Unable to write notification : "+error.Error())
+	}
+
+	return nil
+}
@@ -0,0 +1,165 @@
+package auth
+
+import (
+	"sync"
+	"time"
+)
+
+// TokenPurpose : action for which a one-time token was issued
+type TokenPurpose string
+
+const (
+	// PurposePasswordReset : token which allows to set a new password
+	PurposePasswordReset TokenPurpose = "password_reset"
+	// PurposeEmailVerification : token which proves that the user
+	// received messages sent to the email address
+	PurposeEmailVerification TokenPurpose = "email_verification"
+)
+
+// OneTimeToken : stored state of an opaque token which is sent to a user,
+// e.g. to reset the password. Only the hash of the token is stored, so
+// that a leaked store does not contain usable tokens.
+type OneTimeToken struct {
+	ID        string
+	Purpose   TokenPurpose
+	UserID    string
+	TokenHash string
+	IssuedAt  time.Time
+	ExpiresAt time.Time
+	// Email : email address of the user when the token was issued, an
+	// email verification token only verifies this address
+	Email string
+
+	// zero as long as the token was not used
+	UsedAt time.Time
+}
+
+// create a copy of the token, so that stored tokens can
+// not be modified from outside of a OneTimeTokenStore
+func (t *OneTimeToken) copy() *OneTimeToken {
+	tokenCopy := *t
+	return &tokenCopy
+}
+
+// OneTimeTokenStore : persistence layer used by the AuthHandler to manage
+// one-time tokens. Errors have to wrap ErrInvalidToken for unknown tokens
+// and ErrStore for failures of the underlying storage.
+type OneTimeTokenStore interface {
+	// CreateOneTimeToken : Add a new token to the store
+	CreateOneTimeToken(token *OneTimeToken) (error error)
+	// GetOneTimeTokenByHash : Get token by the hash of the token
+	GetOneTimeTokenByHash(tokenHash string) (token *OneTimeToken, error error)
+	// MarkOneTimeTokenUsed : Set UsedAt of an unused token. This has to be
+	// atomic, if the token was already used ErrTokenUsed is returned, so
+	// that a token can only be used once.
+	MarkOneTimeTokenUsed(tokenID string, usedAt time.Time) (error error)
+	// DeleteOneTimeTokens : Remove all tokens of the user for the purpose
+	DeleteOneTimeTokens(userID string, purpose TokenPurpose) (error error)
+	// DeleteExpiredOneTimeTokens : Remove all tokens which expired before now
+	DeleteExpiredOneTimeTokens(now time.Time) (error error)
+}
+
+// InMemoryOneTimeTokenStore : default OneTimeTokenStore which keeps all
+// tokens in maps. The store is safe for concurrent use.
+type InMemoryOneTimeTokenStore struct {
+	mutex          sync.Mutex
+	tokensByID     map[string]*OneTimeToken
+	tokenIDsByHash map[string]string
+}
+
+// NewInMemoryOneTimeTokenStore : Create a new empty in-memory one-time token store
+func NewInMemoryOneTimeTokenStore() *InMemoryOneTimeTokenStore {
+	store := new(InMemoryOneTimeTokenStore)
+	store.tokensByID = make(map[string]*OneTimeToken)
+	store.tokenIDsByHash = make(map[string]string)
+
+	return store
+}
+
+// CreateOneTimeToken : Store a copy of the given token if ID and hash are not used yet
+func (s *InMemoryOneTimeTokenStore) CreateOneTimeToken(token *OneTimeToken) (error error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	_, tokenFound := s.tokensByID[token.ID]
+	_, hashFound := s.tokenIDsByHash[token.TokenHash]
+	if tokenFound || hashFound {
+		return newError(ErrInvalidInput, "Error : One-time token with ID '"+token.ID+"' already exists!")
+	}
+
+	s.tokensByID[token.ID] = token.copy()
+	s.tokenIDsByHash[token.TokenHash] = token.ID
+	return nil
+}
+
+// GetOneTimeTokenByHash : Get a copy of the token with the given hash
+func (s *InMemoryOneTimeTokenStore) GetOneTimeTokenByHash(tokenHash string) (token *OneTimeToken, error error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	tokenID, hashFound := s.tokenIDsByHash[tokenHash]
+	if !hashFound {
+		return nil, newError(ErrInvalidToken, "Error : One-time token not found!")
+	}
+
+	return s.tokensByID[tokenID].copy(), nil
+}
+
+// MarkOneTimeTokenUsed : Set UsedAt of the token if it was not used yet
+func (s *InMemoryOneTimeTokenStore) MarkOneTimeTokenUsed(tokenID string, usedAt time.Time) (error error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	storedToken, tokenFound := s.tokensByID[tokenID]
+	if !tokenFound {
+		return newError(ErrInvalidToken, "Error : One-time token not found!")
+	}
+	if !storedToken.UsedAt.IsZero() {
+		return newError(ErrTokenUsed, "Error : One-time token was already used!")
+	}
+
+	storedToken.UsedAt = usedAt
+	return nil
+}
+
+// DeleteOneTimeTokens : Remove all tokens of the user for the purpose
+func (s *InMemoryOneTimeTokenStore) DeleteOneTimeTokens(userID string, purpose TokenPurpose) (error error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	for tokenID, storedToken := range s.tokensByID {
+		if storedToken.UserID == userID && storedToken.Purpose == purpose {
+			s.delete(tokenID)
+		}
+	}
+
+	return nil
+}
+
+// DeleteExpiredOneTimeTokens : Remove all tokens which expired before now
+func (s *InMemoryOneTimeTokenStore) DeleteExpiredOneTimeTokens(now time.Time) (error error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	for tokenID, storedToken := range s.tokensByID {
+		if storedToken.ExpiresAt.Before(now) {
+			s.delete(tokenID)
+		}
+	}
+
+	return nil
+}
+
+// remove a token without locking the store
+func (s *InMemoryOneTimeTokenStore) delete(tokenID string) {
+	delete(s.tokenIDsByHash, s.tokensByID[tokenID].TokenHash)
+	delete(s.tokensByID, tokenID)
+}
+
+// Len : Get the number of stored tokens, e.g. to monitor the size of the store
+func (s *InMemoryOneTimeTokenStore) Len() int {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	return len(s.tokensByID)
+}
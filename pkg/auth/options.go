@@ -0,0 +1,289 @@
+package auth
+
+import (
+	"time"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// Option : setting of an AuthHandler which is applied by NewAuthHandler.
+// Options are applied in the given order.
+type Option func(authH *AuthHandler) (error error)
+
+// WithUserStore : Manage the users with the given UserStore instead of in memory
+func WithUserStore(userStore UserStore) Option {
+	return func(authH *AuthHandler) (error error) {
+		if userStore == nil {
+			return authH.newError(ErrInvalidInput, "Error : User store must not be nil!")
+		}
+		authH.userStore = userStore
+		return nil
+	}
+}
+
+// WithSecret : Sign and verify access tokens using HS256 with a fixed secret
+func WithSecret(secret []byte) Option {
+	return WithSecretProvider(NewStaticSecretProvider(secret))
+}
+
+// WithSecretProvider : Sign and verify access tokens using HS256 with the
+// secret of the provider, see SetSecretProvider
+func WithSecretProvider(secretProvider SecretProvider) Option {
+	return func(authH *AuthHandler) (error error) {
+		return authH.SetSecretProvider(secretProvider)
+	}
+}
+
+// WithSigningKey : Sign and verify access tokens with the key, see SetSigningKey
+func WithSigningKey(signingKey *SigningKey) Option {
+	return func(authH *AuthHandler) (error error) {
+		return authH.SetSigningKey(signingKey)
+	}
+}
+
+// WithKeyRing : Sign and verify access tokens with the keys of the ring, see SetKeyRing
+func WithKeyRing(keyRing *KeyRing) Option {
+	return func(authH *AuthHandler) (error error) {
+		return authH.SetKeyRing(keyRing)
+	}
+}
+
+// WithRefreshTokenStore : Keep the refresh tokens in the given store, see SetRefreshTokenStore
+func WithRefreshTokenStore(refreshTokenStore RefreshTokenStore) Option {
+	return func(authH *AuthHandler) (error error) {
+		if refreshTokenStore == nil {
+			return authH.newError(ErrInvalidInput, "Error : Refresh token store must not be nil!")
+		}
+		authH.SetRefreshTokenStore(refreshTokenStore)
+		return nil
+	}
+}
+
+// WithSessionStore : Keep the sessions in the given store, see SetSessionStore
+func WithSessionStore(sessionStore SessionStore) Option {
+	return func(authH *AuthHandler) (error error) {
+		if sessionStore == nil {
+			return authH.newError(ErrInvalidInput, "Error : Session store must not be nil!")
+		}
+		authH.SetSessionStore(sessionStore)
+		return nil
+	}
+}
+
+// WithRevocationStore : Keep the revocation list in the given store, see SetRevocationStore
+func WithRevocationStore(revocationStore RevocationStore) Option {
+	return func(authH *AuthHandler) (error error) {
+		if revocationStore == nil {
+			return authH.newError(ErrInvalidInput, "Error : Revocation store must not be nil!")
+		}
+		authH.SetRevocationStore(revocationStore)
+		return nil
+	}
+}
+
+// WithOneTimeTokenStore : Keep one-time tokens like password reset tokens in the given store
+func WithOneTimeTokenStore(oneTimeTokenStore OneTimeTokenStore) Option {
+	return func(authH *AuthHandler) (error error) {
+		if oneTimeTokenStore == nil {
+			return authH.newError(ErrInvalidInput, "Error : One-time token store must not be nil!")
+		}
+		authH.SetOneTimeTokenStore(oneTimeTokenStore)
+		return nil
+	}
+}
+
+// WithNotifier : Deliver password reset and email verification tokens with the given Notifier, see SetNotifier
+func WithNotifier(notifier Notifier) Option {
+	return func(authH *AuthHandler) (error error) {
+		if notifier == nil {
+			return authH.newError(ErrInvalidInput, "Error : Notifier must not be nil!")
+		}
+		authH.SetNotifier(notifier)
+		return nil
+	}
+}
+
+// WithAccessTokenLifetime : Set how long generated access tokens are valid
+func WithAccessTokenLifetime(lifetime time.Duration) Option {
+	return func(authH *AuthHandler) (error error) {
+		if lifetime <= 0 {
+			return authH.newError(ErrInvalidInput, "Error : Access token lifetime has to be positive!")
+		}
+		authH.SetAccessTokenLifetime(lifetime)
+		return nil
+	}
+}
+
+// WithRefreshTokenLifetime : Set how long refresh tokens and sessions are valid
+func WithRefreshTokenLifetime(lifetime time.Duration) Option {
+	return func(authH *AuthHandler) (error error) {
+		if lifetime <= 0 {
+			return authH.newError(ErrInvalidInput, "Error : Refresh token lifetime has to be positive!")
+		}
+		authH.SetRefreshTokenLifetime(lifetime)
+		return nil
+	}
+}
+
+// WithPasswordResetTokenLifetime : Set how long password reset tokens are valid
+func WithPasswordResetTokenLifetime(lifetime time.Duration) Option {
+	return func(authH *AuthHandler) (error error) {
+		if lifetime <= 0 {
+			return authH.newError(ErrInvalidInput, "Error : Password reset token lifetime has to be positive!")
+		}
+		authH.SetPasswordResetTokenLifetime(lifetime)
+		return nil
+	}
+}
+
+// WithEmailVerificationTokenLifetime : Set how long email verification tokens are valid
+func WithEmailVerificationTokenLifetime(lifetime time.Duration) Option {
+	return func(authH *AuthHandler) (error error) {
+		if lifetime <= 0 {
+			return authH.newError(ErrInvalidInput, "Error : Email verification token lifetime has to be positive!")
+		}
+		authH.SetEmailVerificationTokenLifetime(lifetime)
+		return nil
+	}
+}
+
+// WithIssuer : Set the issuer of generated tokens, see SetIssuer
+func WithIssuer(issuer string) Option {
+	return func(authH *AuthHandler) (error error) {
+		authH.SetIssuer(issuer)
+		return nil
+	}
+}
+
+// WithAudience : Set the audience of generated tokens, see SetAudience
+func WithAudience(audience ...string) Option {
+	return func(authH *AuthHandler) (error error) {
+		authH.SetAudience(audience...)
+		return nil
+	}
+}
+
+// WithClockSkew : Set the tolerance for the time based claims, see SetClockSkew
+func WithClockSkew(clockSkew time.Duration) Option {
+	return func(authH *AuthHandler) (error error) {
+		if clockSkew < 0 {
+			return authH.newError(ErrInvalidInput, "Error : Clock skew must not be negative!")
+		}
+		authH.SetClockSkew(clockSkew)
+		return nil
+	}
+}
+
+// WithClock : Set the clock which is used for generating and validating tokens
+func WithClock(clock Clock) Option {
+	return func(authH *AuthHandler) (error error) {
+		if clock == nil {
+			return authH.newError(ErrInvalidInput, "Error : Clock must not be nil!")
+		}
+		authH.SetClock(clock)
+		return nil
+	}
+}
+
+// WithAllowedAlgorithms : Set the accepted signing algorithms, see
+// SetAllowedAlgorithms. Has to be given after the key options, as setting
+// a key resets the allowed algorithms.
+func WithAllowedAlgorithms(algorithms ...string) Option {
+	return func(authH *AuthHandler) (error error) {
+		return authH.SetAllowedAlgorithms(algorithms...)
+	}
+}
+
+// WithValidationMode : Set which state is consulted by AuthenticateByJWT
+func WithValidationMode(validationMode ValidationMode) Option {
+	return func(authH *AuthHandler) (error error) {
+		authH.SetValidationMode(validationMode)
+		return nil
+	}
+}
+
+// WithLogger : Set the logger to which all returned errors are written,
+// nil disables logging. Should be the first option, so that errors of
+// the other options are written to it.
+func WithLogger(logger log.FieldLogger) Option {
+	return func(authH *AuthHandler) (error error) {
+		authH.SetLogger(logger)
+		return nil
+	}
+}
+
+// WithUserRule : Add a regex rule which every new user name has to match
+func WithUserRule(regex string) Option {
+	return func(authH *AuthHandler) (error error) {
+		return authH.AddUserRule(regex)
+	}
+}
+
+// WithPasswordRule : Add a regex rule which every new password has to match
+func WithPasswordRule(regex string) Option {
+	return func(authH *AuthHandler) (error error) {
+		return authH.AddPasswordRule(regex)
+	}
+}
+
+// WithCustomUserRule : Add an arbitrary rule which every new user name has to pass
+func WithCustomUserRule(rule Rule) Option {
+	return func(authH *AuthHandler) (error error) {
+		authH.AddCustomUserRule(rule)
+		return nil
+	}
+}
+
+// WithCustomPasswordRule : Add an arbitrary rule which every new password has to pass
+func WithCustomPasswordRule(rule Rule) Option {
+	return func(authH *AuthHandler) (error error) {
+		authH.AddCustomPasswordRule(rule)
+		return nil
+	}
+}
+
+// WithPasswordPolicy : Set the policy which is evaluated for every new password
+func WithPasswordPolicy(policy *PasswordPolicy) Option {
+	return func(authH *AuthHandler) (error error) {
+		authH.SetPasswordPolicy(policy)
+		return nil
+	}
+}
+
+// WithPasswordHasher : Set the algorithm with which new passwords are hashed, see SetPasswordHasher
+func WithPasswordHasher(passwordHasher PasswordHasher) Option {
+	return func(authH *AuthHandler) (error error) {
+		return authH.SetPasswordHasher(passwordHasher)
+	}
+}
+
+// WithBcryptCost : Hash new passwords with bcrypt and the given cost, see SetBcryptCost
+func WithBcryptCost(cost int) Option {
+	return func(authH *AuthHandler) (error error) {
+		return authH.SetBcryptCost(cost)
+	}
+}
+
+// WithEnumerationProtection : Hide which user names exist, see SetEnumerationProtection
+func WithEnumerationProtection() Option {
+	return func(authH *AuthHandler) (error error) {
+		authH.SetEnumerationProtection(true)
+		return nil
+	}
+}
+
+// WithEmailVerificationRequired : Refuse logins without verified email
+// address, see SetEmailVerificationRequired
+func WithEmailVerificationRequired() Option {
+	return func(authH *AuthHandler) (error error) {
+		authH.SetEmailVerificationRequired(true)
+		return nil
+	}
+}
+
+// WithRateLimiter : Limit the logins with the given RateLimiter, see SetRateLimiter
+func WithRateLimiter(rateLimiter *RateLimiter) Option {
+	return func(authH *AuthHandler) (error error) {
+		return authH.SetRateLimiter(rateLimiter)
+	}
+}
@@ -0,0 +1,110 @@
+package auth
+
+import (
+	"time"
+)
+
+// ChangePassword : Change the password of the user after checking the old
+// one. The new password has to comply to the rules and the password
+// policy. All sessions and access tokens of the user are revoked, the
+// returned result contains the tokens of a new session which replace the
+// tokens of the current one. Logins with the old password are counted by
+// the RateLimiter like other logins.
+func (a *AuthHandler) ChangePassword(userID string, oldPassword string, newPassword string) (result *LogInResult, error error) {
+	user, error := a.userStore.GetUserByID(userID)
+	if error != nil {
+		return nil, a.logError(error)
+	}
+
+	_, error = a.authenticateWithRateLimit(user.UserName, oldPassword, ClientInfo{})
+	if error != nil {
+		return nil, error
+	}
+	if newPassword == oldPassword {
+		return nil, a.newError(ErrInvalidInput, "Error : New password has to differ from the old password!")
+	}
+
+	error = a.replacePassword(user, newPassword, nil)
+	if error != nil {
+		return nil, error
+	}
+
+	return a.startSession(user, ClientInfo{})
+}
+
+// SetPassword : Set a new password for the user without knowing the old
+// one, e.g. by the support staff. The new password has to comply to the
+// rules and the password policy. All sessions and access tokens of the
+// user are revoked.
+func (a *AuthHandler) SetPassword(userID string, newPassword string) (error error) {
+	user, error := a.userStore.GetUserByID(userID)
+	if error != nil {
+		return a.logError(error)
+	}
+
+	return a.replacePassword(user, newPassword, nil)
+}
+
+// check the new password, store its hash and the time of the change and
+// revoke all tokens which were issued with the old password, including
+// outstanding password reset tokens. A given reset token is only used
+// after the new password was accepted, so that the user can try again
+// with another password.
+func (a *AuthHandler) replacePassword(user *User, newPassword string, resetToken *OneTimeToken) (error error) {
+	if len(newPassword) == 0 {
+		return a.newError(ErrInvalidInput, "Error : Please enter a valid password!")
+	}
+	error = a.checkNewPassword(user.UserName, newPassword)
+	if error != nil {
+		return error
+	}
+
+	hashedPassword, error := a.getPasswordHasher().Hash(newPassword)
+	if error != nil {
+		a.logError(error)
+		return a.newError(ErrInternal, "Error : Unable to hash password for user '"+user.UserName+"' !")
+	}
+
+	if resetToken != nil {
+		error = a.useOneTimeToken(resetToken)
+		if error != nil {
+			return error
+		}
+	}
+
+	_, _, error = a.updateUser(user.ID, changePassword(hashedPassword, a.getTokenConfig().clock.Now()))
+	if error != nil {
+		return error
+	}
+
+	error = a.oneTimeTokenStore.DeleteOneTimeTokens(user.ID, PurposePasswordReset)
+	if error != nil {
+		return a.logError(error)
+	}
+
+	return a.RevokeAllTokensForUser(user.ID)
+}
+
+// replace the hash of the password and store the time of the change
+func changePassword(hashedPassword string, changedAt time.Time) userUpdate {
+	return func(user *User) (error error) {
+		user.HashedPassword = hashedPassword
+		user.PasswordChangedAt = changedAt
+		return nil
+	}
+}
+
+// check that the access token was issued after the last password change.
+// iat only has a precision of seconds, tokens of the same second are only
+// revoked by their session like in RevokeAllTokensForUser.
+func (a *AuthHandler) checkPasswordChange(user *User, claims *Claims) (error error) {
+	if user.PasswordChangedAt.IsZero() || claims.IssuedAt == nil {
+		return nil
+	}
+
+	if claims.IssuedAt.Before(user.PasswordChangedAt.Truncate(time.Second)) {
+		return a.newError(ErrTokenRevoked, "Error : Authentication Failed. JWT AccessToken was issued before the password was changed!")
+	}
+
+	return nil
+}
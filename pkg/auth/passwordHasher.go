@@ -0,0 +1,299 @@
+package auth
+
+import (
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/base64"
+	"fmt"
+	"strings"
+
+	"golang.org/x/crypto/argon2"
+	"golang.org/x/crypto/bcrypt"
+	"golang.org/x/crypto/scrypt"
+)
+
+// PasswordHasher : algorithm with which the AuthHandler hashes passwords.
+// Hashes contain the algorithm, its parameters and the salt, so that they
+// can be verified after the parameters changed.
+type PasswordHasher interface {
+	// Hash : Hash the password with a new random salt
+	Hash(password string) (hashedPassword string, error error)
+	// Supports : Check if the hash was created with the algorithm of the hasher
+	Supports(hashedPassword string) bool
+	// Verify : Check if the password matches the hash. An error is only
+	// returned if the hash is malformed.
+	Verify(password string, hashedPassword string) (matches bool, error error)
+	// NeedsRehash : Check if the hash was created with other parameters
+	// than the current ones of the hasher
+	NeedsRehash(hashedPassword string) bool
+}
+
+// get a hasher of every built-in algorithm, which can verify all hashes
+// of its algorithm independent of their parameters
+func knownPasswordHashers() []PasswordHasher {
+	return []PasswordHasher{NewBcryptHasher(), NewArgon2idHasher(), NewScryptHasher(), NewPBKDF2Hasher(), &SaltedSHA256Hasher{}}
+}
+
+// BcryptHasher : PasswordHasher which uses bcrypt. Hashes are written in
+// the modular crypt format of bcrypt, e.g. $2a$10$...
+type BcryptHasher struct {
+	Cost int
+}
+
+// NewBcryptHasher : Create a bcrypt hasher with bcrypt.DefaultCost
+func NewBcryptHasher() *BcryptHasher {
+	return &BcryptHasher{Cost: bcrypt.DefaultCost}
+}
+
+// Hash : Hash the password with the cost of the hasher
+func (h *BcryptHasher) Hash(password string) (hashedPassword string, error error) {
+	hash, error := bcrypt.GenerateFromPassword([]byte(password), h.Cost)
+	if error != nil {
+		return "", newError(ErrInternal, "Error : Unable to hash password with bcrypt : "+error.Error())
+	}
+
+	return string(hash), nil
+}
+
+// Supports : Check if the hash is a bcrypt hash
+func (h *BcryptHasher) Supports(hashedPassword string) bool {
+	return strings.HasPrefix(hashedPassword, "$2a$") || strings.HasPrefix(hashedPassword, "$2b$") || strings.HasPrefix(hashedPassword, "$2y$")
+}
+
+// Verify : Check if the password matches the bcrypt hash
+func (h *BcryptHasher) Verify(password string, hashedPassword string) (matches bool, error error) {
+	error = bcrypt.CompareHashAndPassword([]byte(hashedPassword), []byte(password))
+	if error == bcrypt.ErrMismatchedHashAndPassword {
+		return false, nil
+	} else if error != nil {
+		return false, newError(ErrInternal, "Error : Malformed bcrypt hash : "+error.Error())
+	}
+
+	return true, nil
+}
+
+// NeedsRehash : Check if the hash was created with another cost
+func (h *BcryptHasher) NeedsRehash(hashedPassword string) bool {
+	cost, error := bcrypt.Cost([]byte(hashedPassword))
+	return error != nil || cost != h.Cost
+}
+
+// upper limits of the parameters of hashes which are verified. Hashes
+// are read from the UserStore and may be imported from other systems,
+// so that their parameters must not be able to exhaust the memory or the
+// CPU during a login. The limits are far above the recommended values.
+const (
+	// maxArgon2Memory : 1 GiB in KiB
+	maxArgon2Memory     = 1 << 20
+	maxArgon2Iterations = 64
+	// maxScryptMemory : 1 GiB, scrypt needs 128 * r * N bytes
+	maxScryptMemory      = 1 << 30
+	maxScryptParallelism = 16
+	maxPBKDF2Iterations  = 2000000
+	// maxKeyLength : length in bytes of the longest derived key
+	maxKeyLength = 128
+)
+
+// Argon2idHasher : PasswordHasher which uses Argon2id. Hashes are written
+// in the PHC string format, e.g. $argon2id$v=19$m=19456,t=2,p=1$salt$hash
+type Argon2idHasher struct {
+	// Memory : memory in KiB
+	Memory      uint32
+	Iterations  uint32
+	Parallelism uint8
+	SaltLength  uint32
+	KeyLength   uint32
+}
+
+// NewArgon2idHasher : Create an Argon2id hasher with the parameters
+// recommended by OWASP: 19 MiB memory, 2 iterations and 1 thread
+func NewArgon2idHasher() *Argon2idHasher {
+	return &Argon2idHasher{Memory: 19 * 1024, Iterations: 2, Parallelism: 1, SaltLength: 16, KeyLength: 32}
+}
+
+// Hash : Hash the password with the parameters of the hasher
+func (h *Argon2idHasher) Hash(password string) (hashedPassword string, error error) {
+	if error = checkArgon2idParameters(h.Memory, h.Iterations, h.Parallelism, h.KeyLength); error != nil {
+		return "", error
+	}
+	salt, error := generateSalt(h.SaltLength)
+	if error != nil {
+		return "", error
+	}
+
+	key := argon2.IDKey([]byte(password), salt, h.Iterations, h.Memory, h.Parallelism, h.KeyLength)
+	return fmt.Sprintf("$argon2id$v=%d$m=%d,t=%d,p=%d$%s$%s", argon2.Version, h.Memory, h.Iterations, h.Parallelism, encodePHCBase64(salt), encodePHCBase64(key)), nil
+}
+
+// Supports : Check if the hash is an Argon2id hash
+func (h *Argon2idHasher) Supports(hashedPassword string) bool {
+	return strings.HasPrefix(hashedPassword, "$argon2id$")
+}
+
+// Verify : Check if the password matches the Argon2id hash with the parameters of the hash
+func (h *Argon2idHasher) Verify(password string, hashedPassword string) (matches bool, error error) {
+	parameters, salt, key, error := parseArgon2idHash(hashedPassword)
+	if error != nil {
+		return false, error
+	}
+
+	otherKey := argon2.IDKey([]byte(password), salt, parameters.Iterations, parameters.Memory, parameters.Parallelism, uint32(len(key)))
+	return subtle.ConstantTimeCompare(key, otherKey) == 1, nil
+}
+
+// NeedsRehash : Check if the hash was created with other parameters
+func (h *Argon2idHasher) NeedsRehash(hashedPassword string) bool {
+	parameters, salt, key, error := parseArgon2idHash(hashedPassword)
+	return error != nil || parameters.Memory != h.Memory || parameters.Iterations != h.Iterations ||
+		parameters.Parallelism != h.Parallelism || uint32(len(salt)) != h.SaltLength || uint32(len(key)) != h.KeyLength
+}
+
+// split an Argon2id hash in the PHC string format into its parts
+func parseArgon2idHash(hashedPassword string) (parameters *Argon2idHasher, salt []byte, key []byte, error error) {
+	parts := strings.Split(hashedPassword, "$")
+	if len(parts) != 6 || parts[1] != "argon2id" {
+		return nil, nil, nil, newError(ErrInternal, "Error : Malformed Argon2id hash!")
+	}
+
+	var version int
+	parameters = new(Argon2idHasher)
+	_, versionError := fmt.Sscanf(parts[2], "v=%d", &version)
+	_, parameterError := fmt.Sscanf(parts[3], "m=%d,t=%d,p=%d", &parameters.Memory, &parameters.Iterations, &parameters.Parallelism)
+	salt, saltError := decodePHCBase64(parts[4])
+	key, keyError := decodePHCBase64(parts[5])
+	if versionError != nil || parameterError != nil || saltError != nil || keyError != nil || len(key) == 0 {
+		return nil, nil, nil, newError(ErrInternal, "Error : Malformed Argon2id hash!")
+	}
+	if version != argon2.Version {
+		return nil, nil, nil, newError(ErrInternal, "Error : Unsupported Argon2 version "+parts[2]+"!")
+	}
+	if error = checkArgon2idParameters(parameters.Memory, parameters.Iterations, parameters.Parallelism, uint32(len(key))); error != nil {
+		return nil, nil, nil, error
+	}
+
+	return parameters, salt, key, nil
+}
+
+// check that Argon2id can run with the parameters, i.e. that it does not
+// panic, and that they are within the limits
+func checkArgon2idParameters(memory uint32, iterations uint32, parallelism uint8, keyLength uint32) (error error) {
+	if iterations < 1 || iterations > maxArgon2Iterations || parallelism < 1 ||
+		memory < 8*uint32(parallelism) || memory > maxArgon2Memory || keyLength < 1 || keyLength > maxKeyLength {
+		return newError(ErrInternal, fmt.Sprintf("Error : Invalid Argon2id parameters m=%d,t=%d,p=%d!", memory, iterations, parallelism))
+	}
+
+	return nil
+}
+
+// ScryptHasher : PasswordHasher which uses scrypt. Hashes are written in
+// the PHC string format with N as power of two, e.g. $scrypt$ln=17,r=8,p=1$salt$hash
+type ScryptHasher struct {
+	// CostLog2 : binary logarithm of the CPU/memory cost N
+	CostLog2    uint8
+	BlockSize   int
+	Parallelism int
+	SaltLength  uint32
+	KeyLength   int
+}
+
+// NewScryptHasher : Create a scrypt hasher with the parameters
+// recommended by OWASP: N=2^17, r=8 and p=1
+func NewScryptHasher() *ScryptHasher {
+	return &ScryptHasher{CostLog2: 17, BlockSize: 8, Parallelism: 1, SaltLength: 16, KeyLength: 32}
+}
+
+// Hash : Hash the password with the parameters of the hasher
+func (h *ScryptHasher) Hash(password string) (hashedPassword string, error error) {
+	if error = checkScryptParameters(h.CostLog2, h.BlockSize, h.Parallelism, h.KeyLength); error != nil {
+		return "", error
+	}
+	salt, error := generateSalt(h.SaltLength)
+	if error != nil {
+		return "", error
+	}
+
+	key, error := scrypt.Key([]byte(password), salt, 1<<h.CostLog2, h.BlockSize, h.Parallelism, h.KeyLength)
+	if error != nil {
+		return "", newError(ErrInternal, "Error : Unable to hash password with scrypt : "+error.Error())
+	}
+
+	return fmt.Sprintf("$scrypt$ln=%d,r=%d,p=%d$%s$%s", h.CostLog2, h.BlockSize, h.Parallelism, encodePHCBase64(salt), encodePHCBase64(key)), nil
+}
+
+// Supports : Check if the hash is a scrypt hash
+func (h *ScryptHasher) Supports(hashedPassword string) bool {
+	return strings.HasPrefix(hashedPassword, "$scrypt$")
+}
+
+// Verify : Check if the password matches the scrypt hash with the parameters of the hash
+func (h *ScryptHasher) Verify(password string, hashedPassword string) (matches bool, error error) {
+	parameters, salt, key, error := parseScryptHash(hashedPassword)
+	if error != nil {
+		return false, error
+	}
+
+	otherKey, error := scrypt.Key([]byte(password), salt, 1<<parameters.CostLog2, parameters.BlockSize, parameters.Parallelism, len(key))
+	if error != nil {
+		return false, newError(ErrInternal, "Error : Malformed scrypt hash : "+error.Error())
+	}
+
+	return subtle.ConstantTimeCompare(key, otherKey) == 1, nil
+}
+
+// NeedsRehash : Check if the hash was created with other parameters
+func (h *ScryptHasher) NeedsRehash(hashedPassword string) bool {
+	parameters, salt, key, error := parseScryptHash(hashedPassword)
+	return error != nil || parameters.CostLog2 != h.CostLog2 || parameters.BlockSize != h.BlockSize ||
+		parameters.Parallelism != h.Parallelism || uint32(len(salt)) != h.SaltLength || len(key) != h.KeyLength
+}
+
+// split a scrypt hash in the PHC string format into its parts
+func parseScryptHash(hashedPassword string) (parameters *ScryptHasher, salt []byte, key []byte, error error) {
+	parts := strings.Split(hashedPassword, "$")
+	if len(parts) != 5 || parts[1] != "scrypt" {
+		return nil, nil, nil, newError(ErrInternal, "Error : Malformed scrypt hash!")
+	}
+
+	parameters = new(ScryptHasher)
+	_, parameterError := fmt.Sscanf(parts[2], "ln=%d,r=%d,p=%d", &parameters.CostLog2, &parameters.BlockSize, &parameters.Parallelism)
+	salt, saltError := decodePHCBase64(parts[3])
+	key, keyError := decodePHCBase64(parts[4])
+	if parameterError != nil || saltError != nil || keyError != nil {
+		return nil, nil, nil, newError(ErrInternal, "Error : Malformed scrypt hash!")
+	}
+	if error = checkScryptParameters(parameters.CostLog2, parameters.BlockSize, parameters.Parallelism, len(key)); error != nil {
+		return nil, nil, nil, error
+	}
+
+	return parameters, salt, key, nil
+}
+
+// check that scrypt accepts the parameters and that they are within the limits
+func checkScryptParameters(costLog2 uint8, blockSize int, parallelism int, keyLength int) (error error) {
+	if costLog2 < 1 || costLog2 >= 32 || blockSize < 1 || parallelism < 1 || parallelism > maxScryptParallelism ||
+		uint64(128)*uint64(blockSize)<<costLog2 > maxScryptMemory || keyLength < 1 || keyLength > maxKeyLength {
+		return newError(ErrInternal, fmt.Sprintf("Error : Invalid scrypt parameters ln=%d,r=%d,p=%d!", costLog2, blockSize, parallelism))
+	}
+
+	return nil
+}
+
+// generate a random salt of the given length
+func generateSalt(length uint32) (salt []byte, error error) {
+	salt = make([]byte, length)
+	if _, error = rand.Read(salt); error != nil {
+		return nil, newError(ErrInternal, "Error : Unable to generate salt : "+error.Error())
+	}
+
+	return salt, nil
+}
+
+// encode bytes with the unpadded standard base64 alphabet of the PHC string format
+func encodePHCBase64(data []byte) string {
+	return base64.RawStdEncoding.EncodeToString(data)
+}
+
+// decode bytes which are encoded with the unpadded standard base64 alphabet
+func decodePHCBase64(data string) ([]byte, error) {
+	return base64.RawStdEncoding.DecodeString(data)
+}
@@ -0,0 +1,262 @@
+package auth
+
+import (
+	"bufio"
+	"crypto/sha1"
+	"encoding/hex"
+	"os"
+	"regexp"
+	"strconv"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// PolicyViolation : description of a single password rule which was violated
+type PolicyViolation struct {
+	Rule    string
+	Message string
+}
+
+// PasswordPolicyError : returned if a password violates one or more rules
+// of a PasswordPolicy. It contains all violations, so that they can be
+// listed to the user at once.
+type PasswordPolicyError struct {
+	Violations []PolicyViolation
+}
+
+func (e *PasswordPolicyError) Error() string {
+	messages := make([]string, len(e.Violations))
+	for i, violation := range e.Violations {
+		messages[i] = violation.Message
+	}
+
+	return "Error : Password does not comply to the password policy : " + strings.Join(messages, "; ")
+}
+
+// Is : a PasswordPolicyError matches ErrPolicyViolation in errors.Is
+func (e *PasswordPolicyError) Is(target error) bool {
+	return target == ErrPolicyViolation
+}
+
+// PasswordRule : single rule of a PasswordPolicy. Validate returns nil
+// if the password complies to the rule.
+type PasswordRule interface {
+	Validate(userName string, password string) (violation *PolicyViolation)
+}
+
+// PasswordPolicy : set of password rules which all have to be passed
+type PasswordPolicy struct {
+	rules []PasswordRule
+}
+
+// NewPasswordPolicy : Create a new password policy from the given rules
+func NewPasswordPolicy(rules ...PasswordRule) *PasswordPolicy {
+	policy := new(PasswordPolicy)
+	policy.rules = append(policy.rules, rules...)
+
+	return policy
+}
+
+// AddRule : Add another rule to the policy
+func (p *PasswordPolicy) AddRule(rule PasswordRule) {
+	p.rules = append(p.rules, rule)
+}
+
+// Evaluate : Validate password against every rule and collect all violations
+func (p *PasswordPolicy) Evaluate(userName string, password string) (violations []PolicyViolation) {
+	for _, rule := range p.rules {
+		if violation := rule.Validate(userName, password); violation != nil {
+			violations = append(violations, *violation)
+		}
+	}
+
+	return violations
+}
+
+// Check : Check password against the policy. If any rule is violated a
+// *PasswordPolicyError with all violations is returned.
+func (p *PasswordPolicy) Check(userName string, password string) (error error) {
+	violations := p.Evaluate(userName, password)
+	if len(violations) > 0 {
+		error = &PasswordPolicyError{Violations: violations}
+	}
+
+	return error
+}
+
+// MinLengthRule : password needs at least Length characters
+type MinLengthRule struct {
+	Length int
+}
+
+func (r MinLengthRule) Validate(userName string, password string) *PolicyViolation {
+	if utf8.RuneCountInString(password) < r.Length {
+		return &PolicyViolation{Rule: "min_length", Message: "Password must be at least " + strconv.Itoa(r.Length) + " characters long"}
+	}
+	return nil
+}
+
+// MaxLengthRule : password must not have more than Length characters
+type MaxLengthRule struct {
+	Length int
+}
+
+func (r MaxLengthRule) Validate(userName string, password string) *PolicyViolation {
+	if utf8.RuneCountInString(password) > r.Length {
+		return &PolicyViolation{Rule: "max_length", Message: "Password must not be longer than " + strconv.Itoa(r.Length) + " characters"}
+	}
+	return nil
+}
+
+// CharacterClass : class of characters which can be required in passwords
+type CharacterClass int
+
+const (
+	Lowercase CharacterClass = iota
+	Uppercase
+	Digit
+	Special
+)
+
+func (c CharacterClass) String() string {
+	switch c {
+	case Lowercase:
+		return "lowercase letter"
+	case Uppercase:
+		return "uppercase letter"
+	case Digit:
+		return "digit"
+	default:
+		return "special character"
+	}
+}
+
+// check if a single character belongs to the class
+func (c CharacterClass) contains(character rune) bool {
+	switch c {
+	case Lowercase:
+		return unicode.IsLower(character)
+	case Uppercase:
+		return unicode.IsUpper(character)
+	case Digit:
+		return unicode.IsDigit(character)
+	default:
+		return !unicode.IsLetter(character) && !unicode.IsDigit(character) && !unicode.IsSpace(character)
+	}
+}
+
+// CharacterClassRule : password needs at least one character of every class
+type CharacterClassRule struct {
+	Classes []CharacterClass
+}
+
+func (r CharacterClassRule) Validate(userName string, password string) *PolicyViolation {
+	var missingClasses []string
+	for _, class := range r.Classes {
+		if strings.IndexFunc(password, class.contains) < 0 {
+			missingClasses = append(missingClasses, class.String())
+		}
+	}
+
+	if len(missingClasses) > 0 {
+		return &PolicyViolation{Rule: "character_classes", Message: "Password must contain at least one " + strings.Join(missingClasses, ", ")}
+	}
+	return nil
+}
+
+// MaxRepeatedCharactersRule : the same character must not appear more than
+// Count times in a row
+type MaxRepeatedCharactersRule struct {
+	Count int
+}
+
+func (r MaxRepeatedCharactersRule) Validate(userName string, password string) *PolicyViolation {
+	var previousCharacter rune
+	repetitions := 0
+	for _, character := range password {
+		if repetitions > 0 && character == previousCharacter {
+			repetitions++
+		} else {
+			repetitions = 1
+		}
+		previousCharacter = character
+
+		if repetitions > r.Count {
+			return &PolicyViolation{Rule: "max_repeated_characters", Message: "Password must not repeat the same character more than " + strconv.Itoa(r.Count) + " times in a row"}
+		}
+	}
+	return nil
+}
+
+// NoUserNameRule : password must not contain the user name (case insensitive)
+type NoUserNameRule struct{}
+
+func (r NoUserNameRule) Validate(userName string, password string) *PolicyViolation {
+	if userName != "" && strings.Contains(strings.ToLower(password), strings.ToLower(userName)) {
+		return &PolicyViolation{Rule: "no_username", Message: "Password must not contain the username"}
+	}
+	return nil
+}
+
+// matches lines of breached password lists, e.g. from haveibeenpwned.com,
+// which consist of a SHA-1 hash and an optional count
+var sha1LineRegex = regexp.MustCompile(`^[0-9A-Fa-f]{40}(:[0-9]+)?$`)
+
+// DictionaryRule : password must not be part of a list of common or
+// breached passwords
+type DictionaryRule struct {
+	words      map[string]bool
+	sha1Hashes map[string]bool
+}
+
+// NewDictionaryRule : Create a dictionary rule from a list of passwords.
+// Entries are compared case insensitive.
+func NewDictionaryRule(passwords ...string) *DictionaryRule {
+	rule := &DictionaryRule{words: make(map[string]bool), sha1Hashes: make(map[string]bool)}
+	for _, password := range passwords {
+		rule.words[strings.ToLower(password)] = true
+	}
+
+	return rule
+}
+
+// NewDictionaryRuleFromFile : Load a dictionary rule from a local file with
+// one entry per line. An entry is either a plain password or the SHA-1
+// hash of a breached password in the format HASH or HASH:COUNT.
+func NewDictionaryRuleFromFile(path string) (rule *DictionaryRule, error error) {
+	file, error := os.Open(path)
+	if error != nil {
+		return nil, newError(ErrInvalidRule, "Error : Unable to open password dictionary '"+path+"' : "+error.Error())
+	}
+	defer file.Close()
+
+	rule = NewDictionaryRule()
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" {
+			continue
+		}
+
+		if sha1LineRegex.MatchString(line) {
+			rule.sha1Hashes[strings.ToUpper(line[:40])] = true
+		} else {
+			rule.words[strings.ToLower(line)] = true
+		}
+	}
+
+	if error = scanner.Err(); error != nil {
+		return nil, newError(ErrInvalidRule, "Error : Unable to read password dictionary '"+path+"' : "+error.Error())
+	}
+
+	return rule, nil
+}
+
+func (r *DictionaryRule) Validate(userName string, password string) *PolicyViolation {
+	hash := sha1.Sum([]byte(password))
+	if r.words[strings.ToLower(password)] || r.sha1Hashes[strings.ToUpper(hex.EncodeToString(hash[:]))] {
+		return &PolicyViolation{Rule: "dictionary", Message: "Password is too common or was found in a list of breached passwords"}
+	}
+	return nil
+}
@@ -0,0 +1,182 @@
+package auth
+
+import (
+	"errors"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// DefaultPasswordResetTokenLifetime : lifetime of password reset tokens if nothing else is configured
+const DefaultPasswordResetTokenLifetime = 30 * time.Minute
+
+// oneTimeTokenPruneInterval : expired one-time tokens are removed at most
+// this often while new tokens are issued
+const oneTimeTokenPruneInterval = time.Minute
+
+// SetPasswordResetTokenLifetime : Set how long password reset tokens are valid
+func (a *AuthHandler) SetPasswordResetTokenLifetime(lifetime time.Duration) {
+	a.configMutex.Lock()
+	defer a.configMutex.Unlock()
+
+	a.tokenConfig.passwordResetTokenLifetime = lifetime
+}
+
+// SetNotifier : Set the Notifier which delivers password reset and email
+// verification tokens to the users, nil disables both. Has to be called before the
+// AuthHandler is used by several goroutines.
+func (a *AuthHandler) SetNotifier(notifier Notifier) {
+	a.notifier = notifier
+}
+
+// SetOneTimeTokenStore : Set the store in which the hashes of one-time
+// tokens like password reset tokens are kept. Has to be called before the
+// AuthHandler is used by several goroutines.
+func (a *AuthHandler) SetOneTimeTokenStore(oneTimeTokenStore OneTimeTokenStore) {
+	a.oneTimeTokenStore = oneTimeTokenStore
+}
+
+// GetOneTimeTokenStore : Get the OneTimeTokenStore which is used by the AuthHandler
+func (a *AuthHandler) GetOneTimeTokenStore() OneTimeTokenStore {
+	return a.oneTimeTokenStore
+}
+
+// RequestPasswordReset : Send a password reset token to the user with the
+// given user name or email address through the Notifier. Earlier reset
+// tokens of the user are replaced by the new one. Nothing is sent for
+// unknown and inactive users, but no error is returned either, so that
+// the result does not reveal which user names and email addresses exist.
+func (a *AuthHandler) RequestPasswordReset(identifier string) (error error) {
+	if a.notifier == nil {
+		return a.newError(ErrNotAllowed, "Error : Password reset is not possible without a notifier!")
+	}
+
+	user, error := a.getUserByIdentifier(identifier)
+	if errors.Is(error, ErrUserNotFound) || (error == nil && !user.IsActive()) {
+		return nil
+	} else if error != nil {
+		return a.logError(error)
+	}
+
+	lifetime := a.getTokenConfig().passwordResetTokenLifetime
+	token, storedToken, error := a.issueOneTimeToken(user, PurposePasswordReset, lifetime)
+	if error != nil {
+		return error
+	}
+
+	return a.logError(a.notifier.Notify(Notification{
+		Kind:      NotificationPasswordReset,
+		UserID:    user.ID,
+		UserName:  user.UserName,
+		Email:     user.Email,
+		Token:     token,
+		ExpiresAt: storedToken.ExpiresAt,
+	}))
+}
+
+// ResetPassword : Set a new password with a token of RequestPasswordReset.
+// The new password has to comply to the rules and the password policy.
+// The token can only be used once, all sessions and access tokens of the
+// user are revoked and a lockout by the RateLimiter is ended.
+func (a *AuthHandler) ResetPassword(token string, newPassword string) (error error) {
+	storedToken, error := a.getOneTimeToken(token, PurposePasswordReset)
+	if error != nil {
+		return error
+	}
+
+	user, error := a.userStore.GetUserByID(storedToken.UserID)
+	if errors.Is(error, ErrUserNotFound) {
+		return a.newError(ErrInvalidToken, "Error : Password reset token is not valid!")
+	} else if error != nil {
+		return a.logError(error)
+	}
+	if error = a.checkUserStatus(user); error != nil {
+		return error
+	}
+
+	if error = a.replacePassword(user, newPassword, storedToken); error != nil {
+		return error
+	}
+
+	if rateLimiter := a.GetRateLimiter(); rateLimiter != nil {
+		return a.logError(rateLimiter.unlock(rateLimitKey(user, "")))
+	}
+
+	return nil
+}
+
+// generate and store a one-time token for the user which replaces the
+// earlier tokens of the user for the same purpose
+func (a *AuthHandler) issueOneTimeToken(user *User, purpose TokenPurpose, lifetime time.Duration) (token string, storedToken *OneTimeToken, error error) {
+	now := a.getTokenConfig().clock.Now()
+	a.pruneOneTimeTokens(now)
+
+	token, error = generateOpaqueToken()
+	if error != nil {
+		return "", nil, a.logError(error)
+	}
+
+	error = a.oneTimeTokenStore.DeleteOneTimeTokens(user.ID, purpose)
+	if error != nil {
+		return "", nil, a.logError(error)
+	}
+
+	storedToken = &OneTimeToken{
+		ID:        uuid.New().String(),
+		Purpose:   purpose,
+		UserID:    user.ID,
+		TokenHash: hashOpaqueToken(token),
+		IssuedAt:  now,
+		ExpiresAt: now.Add(lifetime),
+		Email:     user.Email,
+	}
+	error = a.oneTimeTokenStore.CreateOneTimeToken(storedToken)
+	if error != nil {
+		return "", nil, a.logError(error)
+	}
+
+	return token, storedToken, nil
+}
+
+// get the stored one-time token, which has to be issued for the purpose,
+// must not be expired and must not be used yet
+func (a *AuthHandler) getOneTimeToken(token string, purpose TokenPurpose) (storedToken *OneTimeToken, error error) {
+	storedToken, error = a.oneTimeTokenStore.GetOneTimeTokenByHash(hashOpaqueToken(token))
+	if errors.Is(error, ErrInvalidToken) || (error == nil && storedToken.Purpose != purpose) {
+		return nil, a.newError(ErrInvalidToken, "Error : Token is not valid!")
+	} else if error != nil {
+		return nil, a.logError(error)
+	}
+
+	if !storedToken.UsedAt.IsZero() {
+		return nil, a.newError(ErrTokenUsed, "Error : Token was already used!")
+	}
+	if !a.getTokenConfig().clock.Now().Before(storedToken.ExpiresAt) {
+		return nil, a.newError(ErrTokenExpired, "Error : Token is expired!")
+	}
+
+	return storedToken, nil
+}
+
+// mark the one-time token as used, only one of several concurrent uses can succeed
+func (a *AuthHandler) useOneTimeToken(storedToken *OneTimeToken) (error error) {
+	error = a.oneTimeTokenStore.MarkOneTimeTokenUsed(storedToken.ID, a.getTokenConfig().clock.Now())
+	if errors.Is(error, ErrTokenUsed) {
+		return a.newError(ErrTokenUsed, "Error : Token was already used!")
+	}
+
+	return a.logError(error)
+}
+
+// remove expired one-time tokens if this was not done during the last prune interval
+func (a *AuthHandler) pruneOneTimeTokens(now time.Time) {
+	a.oneTimeTokenPruneMutex.Lock()
+	if now.Sub(a.lastOneTimeTokenPrune) < oneTimeTokenPruneInterval {
+		a.oneTimeTokenPruneMutex.Unlock()
+		return
+	}
+	a.lastOneTimeTokenPrune = now
+	a.oneTimeTokenPruneMutex.Unlock()
+
+	a.logError(a.oneTimeTokenStore.DeleteExpiredOneTimeTokens(now))
+}
@@ -0,0 +1,372 @@
+package auth
+
+import (
+	"errors"
+	"math"
+	"sync"
+	"time"
+)
+
+// counterPruneInterval : expired counters are removed at most this often
+// while users log in
+const counterPruneInterval = time.Minute
+
+// RateLimit : token bucket which holds up to Burst tokens and gains one
+// token every Interval. Every login attempt takes a token and is refused
+// if the bucket is empty. A zero Burst disables the limit.
+type RateLimit struct {
+	Burst    int
+	Interval time.Duration
+}
+
+// RateLimiter : brute-force protection of AuthenticateByPassword. Logins
+// are limited per account, whose user name and email address share the
+// limits, and per client IP, which is only known to LogInFromClient.
+// Failed logins delay the next attempt of the user name and the IP
+// exponentially and lock the user name after LockoutThreshold failures.
+// A successful login only forgets the failures of the account, so that a
+// client can not reset the backoff of its IP with an account of its own.
+// The limits apply to unknown user names as well, so that they do not
+// reveal which user names exist. The fields must not be changed after
+// the RateLimiter was passed to an AuthHandler.
+type RateLimiter struct {
+	UserNameLimit RateLimit
+	IPLimit       RateLimit
+	// BackoffBase : time to wait after the first failed login, doubled
+	// by every further failure up to BackoffMax. Zero disables the backoff.
+	BackoffBase time.Duration
+	BackoffMax  time.Duration
+	// LockoutThreshold : number of failed logins after which the user
+	// name is locked for LockoutDuration. Zero disables the lockout.
+	LockoutThreshold int
+	LockoutDuration  time.Duration
+	// FailureWindow : failed logins are forgotten this long after the
+	// last failure, a successful login forgets those of the account at once
+	FailureWindow time.Duration
+	// CounterStore : store of the counters, which can be shared by the
+	// AuthHandlers of several servers
+	CounterStore CounterStore
+
+	// time of the last removal of expired counters
+	lastPrune  time.Time
+	pruneMutex sync.Mutex
+}
+
+// NewRateLimiter : Create a RateLimiter with an in-memory CounterStore
+// which allows 10 logins per user name and 100 logins per IP at once and
+// one more per minute and per second. Failed logins delay the next one by
+// 1s, 2s, 4s, ... up to 30s and lock the user name for 15 minutes after
+// 10 failures.
+func NewRateLimiter() *RateLimiter {
+	return &RateLimiter{
+		UserNameLimit:    RateLimit{Burst: 10, Interval: time.Minute},
+		IPLimit:          RateLimit{Burst: 100, Interval: time.Second},
+		BackoffBase:      time.Second,
+		BackoffMax:       30 * time.Second,
+		LockoutThreshold: 10,
+		LockoutDuration:  15 * time.Minute,
+		FailureWindow:    15 * time.Minute,
+		CounterStore:     NewInMemoryCounterStore(),
+	}
+}
+
+// RateLimitError : returned if a login was refused by the RateLimiter. It
+// matches ErrRateLimited in errors.Is and additionally ErrAccountLocked if
+// the user name is locked.
+type RateLimitError struct {
+	// RetryAfter : time after which the next login can be tried
+	RetryAfter time.Duration
+	Locked     bool
+}
+
+func (e *RateLimitError) Error() string {
+	if e.Locked {
+		return "Error : Account is locked because of too many failed logins. Please try again later!"
+	}
+
+	return "Error : Too many login attempts. Please try again in " + e.RetryAfter.Round(time.Second).String() + "!"
+}
+
+// Is : a RateLimitError matches ErrRateLimited and, if locked, ErrAccountLocked
+func (e *RateLimitError) Is(target error) bool {
+	return target == ErrRateLimited || (e.Locked && target == ErrAccountLocked)
+}
+
+// check the settings of the rate limiter
+func (r *RateLimiter) validate() (error error) {
+	switch {
+	case r.CounterStore == nil:
+		return newError(ErrInvalidInput, "Error : Counter store of the rate limiter must not be nil!")
+	case r.UserNameLimit.Burst < 0 || r.IPLimit.Burst < 0:
+		return newError(ErrInvalidInput, "Error : Burst of a rate limit must not be negative!")
+	case (r.UserNameLimit.Burst > 0 && r.UserNameLimit.Interval <= 0) || (r.IPLimit.Burst > 0 && r.IPLimit.Interval <= 0):
+		return newError(ErrInvalidInput, "Error : Interval of a rate limit has to be positive!")
+	case r.BackoffBase < 0 || r.BackoffMax < 0 || r.FailureWindow < 0:
+		return newError(ErrInvalidInput, "Error : Backoff and failure window of the rate limiter must not be negative!")
+	case r.LockoutThreshold < 0 || (r.LockoutThreshold > 0 && r.LockoutDuration <= 0):
+		return newError(ErrInvalidInput, "Error : Lockout threshold must not be negative and lockout duration has to be positive!")
+	}
+
+	return nil
+}
+
+// keys of the counters of a user name and an IP
+func userNameCounterKey(userName string) string {
+	return "user:" + userName
+}
+
+func ipCounterKey(ip string) string {
+	return "ip:" + ip
+}
+
+// allow : Take a token of the user name and, if known, of the IP. Returns
+// a *RateLimitError if the login has to be refused.
+func (r *RateLimiter) allow(userName string, ip string, now time.Time) (error error) {
+	error = r.takeToken(userNameCounterKey(userName), r.UserNameLimit, now)
+	if error == nil && ip != "" {
+		error = r.takeToken(ipCounterKey(ip), r.IPLimit, now)
+	}
+
+	return error
+}
+
+// take a token of the counter unless it is locked, waits for its backoff
+// or its bucket is empty
+func (r *RateLimiter) takeToken(key string, limit RateLimit, now time.Time) (error error) {
+	var refusal *RateLimitError
+	_, error = r.CounterStore.UpdateCounter(key, func(counter Counter) Counter {
+		refusal = nil
+		counter = r.refill(counter, limit, now)
+
+		if now.Before(counter.LockedUntil) {
+			refusal = &RateLimitError{RetryAfter: counter.LockedUntil.Sub(now), Locked: true}
+		} else if wait := counter.LastFailure.Add(r.backoff(counter.Failures)).Sub(now); wait > 0 {
+			refusal = &RateLimitError{RetryAfter: wait}
+		} else if limit.Burst > 0 && counter.Tokens < 1 {
+			refusal = &RateLimitError{RetryAfter: time.Duration((1 - counter.Tokens) * float64(limit.Interval))}
+		} else if limit.Burst > 0 {
+			counter.Tokens--
+		}
+
+		counter.ExpiresAt = r.expiresAt(counter, limit)
+		return counter
+	})
+	if error != nil {
+		return error
+	}
+	if refusal != nil {
+		return refusal
+	}
+
+	return nil
+}
+
+// recordFailure : Count a failed login of the user name and the IP and
+// lock the user name if the lockout threshold is reached
+func (r *RateLimiter) recordFailure(userName string, ip string, now time.Time) (error error) {
+	error = r.updateFailures(userNameCounterKey(userName), r.UserNameLimit, true, now)
+	if error == nil && ip != "" {
+		error = r.updateFailures(ipCounterKey(ip), r.IPLimit, false, now)
+	}
+
+	return error
+}
+
+// recordSuccess : Forget the failed logins of the user name. The failures
+// of the IP are kept until the failure window is over.
+func (r *RateLimiter) recordSuccess(userName string, now time.Time) (error error) {
+	return r.resetFailures(userNameCounterKey(userName), r.UserNameLimit, now)
+}
+
+func (r *RateLimiter) updateFailures(key string, limit RateLimit, lockable bool, now time.Time) (error error) {
+	_, error = r.CounterStore.UpdateCounter(key, func(counter Counter) Counter {
+		counter = r.refill(counter, limit, now)
+		counter.Failures++
+		counter.LastFailure = now
+
+		// the backoff starts again after the lockout
+		if lockable && r.LockoutThreshold > 0 && counter.Failures >= r.LockoutThreshold {
+			counter.LockedUntil = now.Add(r.LockoutDuration)
+			counter.Failures = 0
+		}
+
+		counter.ExpiresAt = r.expiresAt(counter, limit)
+		return counter
+	})
+
+	return error
+}
+
+func (r *RateLimiter) resetFailures(key string, limit RateLimit, now time.Time) (error error) {
+	_, error = r.CounterStore.UpdateCounter(key, func(counter Counter) Counter {
+		counter = r.refill(counter, limit, now)
+		counter.Failures = 0
+		counter.LastFailure = time.Time{}
+
+		counter.ExpiresAt = r.expiresAt(counter, limit)
+		return counter
+	})
+
+	return error
+}
+
+// unlock : Forget the lockout, the failures and the used tokens of the user name
+func (r *RateLimiter) unlock(userName string) (error error) {
+	return r.CounterStore.DeleteCounter(userNameCounterKey(userName))
+}
+
+// replace an expired counter by a new one and add the tokens which
+// were gained since the last update to the bucket
+func (r *RateLimiter) refill(counter Counter, limit RateLimit, now time.Time) Counter {
+	if counter.ExpiresAt.Before(now) {
+		counter = Counter{}
+	}
+	if limit.Burst <= 0 {
+		return counter
+	}
+
+	if counter.UpdatedAt.IsZero() {
+		counter.Tokens = float64(limit.Burst)
+		counter.UpdatedAt = now
+	} else if now.After(counter.UpdatedAt) {
+		gained := float64(now.Sub(counter.UpdatedAt)) / float64(limit.Interval)
+		counter.Tokens = math.Min(float64(limit.Burst), counter.Tokens+gained)
+		counter.UpdatedAt = now
+	}
+
+	return counter
+}
+
+// get the time to wait after the given number of failed logins
+func (r *RateLimiter) backoff(failures int) time.Duration {
+	if failures == 0 || r.BackoffBase == 0 {
+		return 0
+	}
+
+	backoff := r.BackoffBase
+	for i := 1; i < failures && (r.BackoffMax == 0 || backoff < r.BackoffMax); i++ {
+		backoff *= 2
+	}
+	if r.BackoffMax > 0 && backoff > r.BackoffMax {
+		backoff = r.BackoffMax
+	}
+
+	return backoff
+}
+
+// get the time after which the counter is equal to a new one, i.e.
+// its bucket is full again and its failures and lockout are over
+func (r *RateLimiter) expiresAt(counter Counter, limit RateLimit) (expiresAt time.Time) {
+	expiresAt = counter.LockedUntil
+	if limit.Burst > 0 {
+		missingTokens := float64(limit.Burst) - counter.Tokens
+		if refilledAt := counter.UpdatedAt.Add(time.Duration(missingTokens * float64(limit.Interval))); refilledAt.After(expiresAt) {
+			expiresAt = refilledAt
+		}
+	}
+	if counter.Failures > 0 {
+		failureWindow := r.FailureWindow
+		if backoff := r.backoff(counter.Failures); backoff > failureWindow {
+			failureWindow = backoff
+		}
+		if forgottenAt := counter.LastFailure.Add(failureWindow); forgottenAt.After(expiresAt) {
+			expiresAt = forgottenAt
+		}
+	}
+
+	return expiresAt
+}
+
+// remove expired counters if this was not done during the last prune interval
+func (r *RateLimiter) prune(now time.Time) (error error) {
+	r.pruneMutex.Lock()
+	if now.Sub(r.lastPrune) < counterPruneInterval {
+		r.pruneMutex.Unlock()
+		return nil
+	}
+	r.lastPrune = now
+	r.pruneMutex.Unlock()
+
+	return r.CounterStore.PruneExpired(now)
+}
+
+// SetRateLimiter : Limit the logins with the given RateLimiter, nil
+// disables the brute-force protection. Disabled by default.
+func (a *AuthHandler) SetRateLimiter(rateLimiter *RateLimiter) (error error) {
+	if rateLimiter != nil {
+		if error = rateLimiter.validate(); error != nil {
+			return a.logError(error)
+		}
+	}
+
+	a.rulesMutex.Lock()
+	defer a.rulesMutex.Unlock()
+
+	a.rateLimiter = rateLimiter
+	return nil
+}
+
+// GetRateLimiter : Get the RateLimiter of the AuthHandler, nil if logins are not limited
+func (a *AuthHandler) GetRateLimiter() *RateLimiter {
+	a.rulesMutex.RLock()
+	defer a.rulesMutex.RUnlock()
+
+	return a.rateLimiter
+}
+
+// UnlockAccount : End the lockout of the account with the user name or
+// email address and forget its failed logins, e.g. after an administrator
+// verified the identity of the user. The limits of the IPs are kept.
+func (a *AuthHandler) UnlockAccount(userName string) (error error) {
+	rateLimiter := a.GetRateLimiter()
+	if rateLimiter == nil {
+		return nil
+	}
+
+	user, _ := a.getUserByIdentifier(userName)
+	return a.logError(rateLimiter.unlock(rateLimitKey(user, userName)))
+}
+
+// check the rate limits of the account and the client before the
+// password is verified and record the result of the verification afterwards
+func (a *AuthHandler) authenticateWithRateLimit(userName string, password string, client ClientInfo) (user *User, error error) {
+	// try to get user by user name or email address
+	user, _ = a.getUserByIdentifier(userName)
+
+	rateLimiter := a.GetRateLimiter()
+	if rateLimiter == nil {
+		return a.verifyCredentials(user, password)
+	}
+
+	now := a.getTokenConfig().clock.Now()
+	key := rateLimitKey(user, userName)
+	error = rateLimiter.allow(key, client.IP, now)
+	if error != nil {
+		return nil, a.logError(error)
+	}
+
+	user, error = a.verifyCredentials(user, password)
+	if errors.Is(error, ErrInvalidCredentials) {
+		a.logError(rateLimiter.recordFailure(key, client.IP, now))
+	} else if error == nil {
+		a.logError(rateLimiter.recordSuccess(key, now))
+	}
+	a.logError(rateLimiter.prune(now))
+
+	return user, error
+}
+
+// get the key under which the logins of the user are counted by the
+// RateLimiter, so that logins by user name and by email address share
+// the same limits. Logins of unknown users are counted by the identifier,
+// all spellings of an email address share one key.
+func rateLimitKey(user *User, identifier string) string {
+	if user != nil {
+		return "id:" + user.ID
+	}
+	if email, emailError := NormalizeEmail(identifier); emailError == nil {
+		return "name:" + email
+	}
+
+	return "name:" + identifier
+}
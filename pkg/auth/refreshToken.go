@@ -0,0 +1,156 @@
+package auth
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// DefaultRefreshTokenLifetime : lifetime of refresh tokens if nothing else is configured
+const DefaultRefreshTokenLifetime = 30 * 24 * time.Hour
+
+// number of random bytes of opaque tokens like refresh tokens
+const opaqueTokenBytes = 32
+
+// refreshTokenPruneInterval : expired refresh tokens are removed at most
+// this often while new refresh tokens are issued
+const refreshTokenPruneInterval = time.Minute
+
+// SetRefreshTokenLifetime : Set how long generated refresh tokens are valid
+func (a *AuthHandler) SetRefreshTokenLifetime(lifetime time.Duration) {
+	a.configMutex.Lock()
+	defer a.configMutex.Unlock()
+
+	a.tokenConfig.refreshTokenLifetime = lifetime
+}
+
+// SetRefreshTokenStore : Set the store in which the hashes of the refresh
+// tokens are kept. Has to be called before the AuthHandler is used by
+// several goroutines.
+func (a *AuthHandler) SetRefreshTokenStore(refreshTokenStore RefreshTokenStore) {
+	a.refreshTokenStore = refreshTokenStore
+}
+
+// GetRefreshTokenStore : Get the RefreshTokenStore which is used by the AuthHandler
+func (a *AuthHandler) GetRefreshTokenStore() RefreshTokenStore {
+	return a.refreshTokenStore
+}
+
+// Refresh : Exchange a refresh token for a new access token and a new
+// refresh token of the same session. Every refresh token can only be used
+// once. If a refresh token is used a second time, it was probably stolen,
+// so the whole family of refresh tokens of the session is revoked and
+// ErrRefreshTokenReused is returned.
+func (a *AuthHandler) Refresh(refreshToken string) (result *LogInResult, error error) {
+	now := a.getTokenConfig().clock.Now()
+
+	storedToken, error := a.refreshTokenStore.GetRefreshTokenByHash(hashOpaqueToken(refreshToken))
+	if error != nil {
+		if errors.Is(error, ErrInvalidToken) {
+			return nil, a.newError(ErrInvalidToken, "Error : Refresh failed. Refresh token is not valid!")
+		}
+		return nil, a.logError(error)
+	}
+
+	if !storedToken.RevokedAt.IsZero() {
+		return nil, a.newError(ErrInvalidToken, "Error : Refresh failed. Refresh token was revoked!")
+	}
+	if !now.Before(storedToken.ExpiresAt) {
+		return nil, a.newError(ErrTokenExpired, "Error : Refresh failed. Refresh token is expired!")
+	}
+
+	// use the token, only one of several concurrent refreshes can succeed
+	error = a.refreshTokenStore.MarkRefreshTokenUsed(storedToken.ID, now)
+	if errors.Is(error, ErrRefreshTokenReused) {
+		revokeError := a.refreshTokenStore.RevokeRefreshTokenFamily(storedToken.FamilyID, now)
+		if revokeError != nil {
+			return nil, a.logError(revokeError)
+		}
+		return nil, a.newError(ErrRefreshTokenReused, "Error : Refresh failed. Refresh token was already used, all refresh tokens of the login were revoked!")
+	} else if error != nil {
+		return nil, a.logError(error)
+	}
+
+	// the user might have been deleted or logged out in the meantime
+	user, error := a.userStore.GetUserByID(storedToken.UserID)
+	if errors.Is(error, ErrUserNotFound) {
+		return nil, a.newError(ErrInvalidToken, "Error : Refresh failed. Refresh token is not valid!")
+	} else if error != nil {
+		return nil, a.logError(error)
+	}
+	if error = a.checkUserStatus(user); error != nil {
+		return nil, error
+	}
+	a.pruneSessions(now)
+	session, error := a.getActiveSession(storedToken.FamilyID, user.ID, now)
+	if error != nil {
+		return nil, error
+	}
+
+	return a.issueTokens(user, session)
+}
+
+// generate and store a new refresh token of the family for the user and
+// return the opaque token
+func (a *AuthHandler) generateRefreshToken(user *User, familyID string) (refreshToken string, storedToken *RefreshToken, error error) {
+	config := a.getTokenConfig()
+
+	refreshToken, error = generateOpaqueToken()
+	if error != nil {
+		return "", nil, a.logError(error)
+	}
+
+	now := config.clock.Now()
+	a.pruneRefreshTokens(now)
+
+	storedToken = &RefreshToken{
+		ID:        uuid.New().String(),
+		FamilyID:  familyID,
+		UserID:    user.ID,
+		TokenHash: hashOpaqueToken(refreshToken),
+		IssuedAt:  now,
+		ExpiresAt: now.Add(config.refreshTokenLifetime),
+	}
+
+	error = a.logError(a.refreshTokenStore.CreateRefreshToken(storedToken))
+	if error != nil {
+		return "", nil, error
+	}
+
+	return refreshToken, storedToken, nil
+}
+
+// generate a random opaque token which is URL safe
+func generateOpaqueToken() (token string, error error) {
+	randomBytes := make([]byte, opaqueTokenBytes)
+	if _, error = rand.Read(randomBytes); error != nil {
+		return "", newError(ErrInternal, "Error : Unable to generate token : "+error.Error())
+	}
+
+	return base64.RawURLEncoding.EncodeToString(randomBytes), nil
+}
+
+// hash an opaque token like a refresh token for storing it. The tokens
+// are long random values, so a fast hash is sufficient.
+func hashOpaqueToken(token string) string {
+	hash := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(hash[:])
+}
+
+// remove expired refresh tokens if this was not done during the last prune interval
+func (a *AuthHandler) pruneRefreshTokens(now time.Time) {
+	a.refreshTokenPruneMutex.Lock()
+	if now.Sub(a.lastRefreshTokenPrune) < refreshTokenPruneInterval {
+		a.refreshTokenPruneMutex.Unlock()
+		return
+	}
+	a.lastRefreshTokenPrune = now
+	a.refreshTokenPruneMutex.Unlock()
+
+	a.logError(a.refreshTokenStore.DeleteExpiredRefreshTokens(now))
+}
@@ -0,0 +1,165 @@
+package auth
+
+import (
+	"sync"
+	"time"
+)
+
+// RefreshToken : stored state of an opaque refresh token. Only the hash of
+// the token is stored, so that a leaked store does not contain usable
+// tokens. All tokens which were rotated from the same login share a family.
+type RefreshToken struct {
+	ID        string
+	FamilyID  string
+	UserID    string
+	TokenHash string
+	IssuedAt  time.Time
+	ExpiresAt time.Time
+
+	// zero as long as the token was not exchanged for a new one
+	UsedAt time.Time
+
+	// zero as long as the family of the token was not revoked
+	RevokedAt time.Time
+}
+
+// create a copy of the refresh token, so that stored tokens can
+// not be modified from outside of a RefreshTokenStore
+func (t *RefreshToken) copy() *RefreshToken {
+	tokenCopy := *t
+	return &tokenCopy
+}
+
+// RefreshTokenStore : persistence layer used by the AuthHandler to manage
+// refresh tokens. Errors have to wrap ErrInvalidToken for unknown tokens
+// and ErrStore for failures of the underlying storage.
+type RefreshTokenStore interface {
+	// CreateRefreshToken : Add a new refresh token to the store
+	CreateRefreshToken(token *RefreshToken) (error error)
+	// GetRefreshTokenByHash : Get refresh token by the hash of the token
+	GetRefreshTokenByHash(tokenHash string) (token *RefreshToken, error error)
+	// MarkRefreshTokenUsed : Set UsedAt of an unused token. This has to be
+	// atomic, if the token was already used ErrRefreshTokenReused is
+	// returned, so that a token can only be exchanged once.
+	MarkRefreshTokenUsed(tokenID string, usedAt time.Time) (error error)
+	// RevokeRefreshTokenFamily : Set RevokedAt of all tokens of the family
+	RevokeRefreshTokenFamily(familyID string, revokedAt time.Time) (error error)
+	// DeleteExpiredRefreshTokens : Remove all tokens which expired before now
+	DeleteExpiredRefreshTokens(now time.Time) (error error)
+}
+
+// InMemoryRefreshTokenStore : default RefreshTokenStore which keeps all
+// refresh tokens in maps. All refresh tokens are lost when the process
+// terminates and are not shared with other processes, see
+// SQLRefreshTokenStore. The store is safe for concurrent use.
+type InMemoryRefreshTokenStore struct {
+	mutex            sync.RWMutex
+	tokensByID       map[string]*RefreshToken
+	tokenIDsByHash   map[string]string
+	tokenIDsByFamily map[string][]string
+}
+
+// NewInMemoryRefreshTokenStore : Create a new empty in-memory refresh token store
+func NewInMemoryRefreshTokenStore() *InMemoryRefreshTokenStore {
+	store := new(InMemoryRefreshTokenStore)
+	store.tokensByID = make(map[string]*RefreshToken)
+	store.tokenIDsByHash = make(map[string]string)
+	store.tokenIDsByFamily = make(map[string][]string)
+
+	return store
+}
+
+// CreateRefreshToken : Store a copy of the given refresh token if ID and hash are not used yet
+func (s *InMemoryRefreshTokenStore) CreateRefreshToken(token *RefreshToken) (error error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	_, tokenFound := s.tokensByID[token.ID]
+	_, hashFound := s.tokenIDsByHash[token.TokenHash]
+	if tokenFound || hashFound {
+		return newError(ErrInvalidInput, "Error : Refresh token with ID '"+token.ID+"' already exists!")
+	}
+
+	s.tokensByID[token.ID] = token.copy()
+	s.tokenIDsByHash[token.TokenHash] = token.ID
+	s.tokenIDsByFamily[token.FamilyID] = append(s.tokenIDsByFamily[token.FamilyID], token.ID)
+
+	return nil
+}
+
+// GetRefreshTokenByHash : Get a copy of the refresh token with the given hash
+func (s *InMemoryRefreshTokenStore) GetRefreshTokenByHash(tokenHash string) (token *RefreshToken, error error) {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+
+	tokenID, tokenFound := s.tokenIDsByHash[tokenHash]
+	if !tokenFound {
+		return nil, newError(ErrInvalidToken, "Error : No refresh token found!")
+	}
+
+	return s.tokensByID[tokenID].copy(), nil
+}
+
+// MarkRefreshTokenUsed : Mark the refresh token as used if it was not used yet
+func (s *InMemoryRefreshTokenStore) MarkRefreshTokenUsed(tokenID string, usedAt time.Time) (error error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	storedToken, tokenFound := s.tokensByID[tokenID]
+	if !tokenFound {
+		return newError(ErrInvalidToken, "Error : No refresh token found for ID : '"+tokenID+"' !")
+	}
+	if !storedToken.UsedAt.IsZero() {
+		return ErrRefreshTokenReused
+	}
+	storedToken.UsedAt = usedAt
+
+	return nil
+}
+
+// RevokeRefreshTokenFamily : Revoke all refresh tokens of the family
+func (s *InMemoryRefreshTokenStore) RevokeRefreshTokenFamily(familyID string, revokedAt time.Time) (error error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	for _, tokenID := range s.tokenIDsByFamily[familyID] {
+		if storedToken := s.tokensByID[tokenID]; storedToken.RevokedAt.IsZero() {
+			storedToken.RevokedAt = revokedAt
+		}
+	}
+
+	return nil
+}
+
+// DeleteExpiredRefreshTokens : Remove all refresh tokens which expired before now
+func (s *InMemoryRefreshTokenStore) DeleteExpiredRefreshTokens(now time.Time) (error error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	for tokenID, storedToken := range s.tokensByID {
+		if storedToken.ExpiresAt.Before(now) {
+			delete(s.tokensByID, tokenID)
+			delete(s.tokenIDsByHash, storedToken.TokenHash)
+			s.removeFromFamily(storedToken.FamilyID, tokenID)
+		}
+	}
+
+	return nil
+}
+
+// remove a token ID from its family, the caller has to hold the lock
+func (s *InMemoryRefreshTokenStore) removeFromFamily(familyID string, tokenID string) {
+	family := s.tokenIDsByFamily[familyID]
+	for i, familyTokenID := range family {
+		if familyTokenID == tokenID {
+			family = append(family[:i], family[i+1:]...)
+			break
+		}
+	}
+
+	if len(family) == 0 {
+		delete(s.tokenIDsByFamily, familyID)
+	} else {
+		s.tokenIDsByFamily[familyID] = family
+	}
+}
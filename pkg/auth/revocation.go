@@ -0,0 +1,104 @@
+package auth
+
+import (
+	"errors"
+	"time"
+)
+
+// revocationPruneInterval : expired entries of the revocation list are
+// removed at most this often while tokens are revoked
+const revocationPruneInterval = time.Minute
+
+// SetRevocationStore : Set the store which keeps the revocation list. Has
+// to be called before the AuthHandler is used by several goroutines.
+func (a *AuthHandler) SetRevocationStore(revocationStore RevocationStore) {
+	a.revocationStore = revocationStore
+}
+
+// GetRevocationStore : Get the RevocationStore which is used by the AuthHandler
+func (a *AuthHandler) GetRevocationStore() RevocationStore {
+	return a.revocationStore
+}
+
+// LogOut : Revoke the access token until it expires and end its session,
+// so that neither the access token nor the refresh tokens of the session
+// can be used anymore. The session is ended in every ValidationMode. With
+// StatelessValidationWithoutRevocation the access token itself is still
+// accepted by this AuthHandler until it expires, as the revocation list
+// is not consulted.
+func (a *AuthHandler) LogOut(JWT string) (error error) {
+	principal, error := a.AuthenticateByJWT(JWT)
+	if error != nil {
+		return error
+	}
+
+	config := a.getTokenConfig()
+	now := config.clock.Now()
+	error = a.revocationStore.RevokeToken(principal.Claims.ID, principal.Claims.ExpiresAt.Add(config.clockSkew))
+	if error != nil {
+		return a.logError(error)
+	}
+	a.pruneRevocations(now)
+
+	// in the stateless modes the session was not looked up and may
+	// already be gone, but its refresh tokens still have to be revoked
+	error = a.sessionStore.DeleteSession(principal.Claims.SessionID)
+	if error != nil && !errors.Is(error, ErrSessionNotFound) {
+		return a.logError(error)
+	}
+
+	return a.logError(a.refreshTokenStore.RevokeRefreshTokenFamily(principal.Claims.SessionID, now))
+}
+
+// RevokeAllTokensForUser : Revoke all access tokens which were issued to
+// the user up to now and end all sessions of the user. Tokens which are
+// issued afterwards are valid again. As iat only has a precision of
+// seconds, tokens issued in the same second are only revoked by their
+// session.
+func (a *AuthHandler) RevokeAllTokensForUser(userID string) (error error) {
+	config := a.getTokenConfig()
+	now := config.clock.Now()
+
+	// the cutoff is needed until the last token issued before it expired
+	expiresAt := now.Add(config.accessTokenLifetime + config.clockSkew)
+	error = a.revocationStore.RevokeUserTokens(userID, now.Truncate(time.Second), expiresAt)
+	if error != nil {
+		return a.logError(error)
+	}
+	a.pruneRevocations(now)
+
+	return a.RevokeAllSessions(userID)
+}
+
+// check if the token was revoked by its ID or by the cutoff of its user
+func (a *AuthHandler) checkRevocation(claims *Claims) (error error) {
+	revoked, error := a.revocationStore.IsTokenRevoked(claims.ID)
+	if error != nil {
+		return a.logError(error)
+	}
+
+	issuedBefore, error := a.revocationStore.GetUserRevocation(claims.Subject)
+	if error != nil {
+		return a.logError(error)
+	}
+
+	if revoked || (claims.IssuedAt != nil && claims.IssuedAt.Before(issuedBefore)) {
+		return a.newError(ErrTokenRevoked, "Error : Authentication Failed. JWT AccessToken was revoked!")
+	}
+
+	return nil
+}
+
+// remove expired entries from the revocation list if this was not done
+// during the last prune interval
+func (a *AuthHandler) pruneRevocations(now time.Time) {
+	a.revocationPruneMutex.Lock()
+	if now.Sub(a.lastRevocationPrune) < revocationPruneInterval {
+		a.revocationPruneMutex.Unlock()
+		return
+	}
+	a.lastRevocationPrune = now
+	a.revocationPruneMutex.Unlock()
+
+	a.logError(a.revocationStore.PruneExpired(now))
+}
@@ -0,0 +1,121 @@
+package auth
+
+import (
+	"sync"
+	"time"
+)
+
+// RevocationStore : persistence layer of the revocation list of the
+// AuthHandler. Tokens are revoked by their ID (jti), all tokens of a user
+// by a cutoff for their issue time (iat). Every entry is only needed until
+// the revoked tokens expire, expired entries are removed by PruneExpired.
+// Errors have to wrap ErrStore for failures of the underlying storage.
+type RevocationStore interface {
+	// RevokeToken : Add the token ID to the list until expiresAt
+	RevokeToken(tokenID string, expiresAt time.Time) (error error)
+	// IsTokenRevoked : Check if the token ID is on the list
+	IsTokenRevoked(tokenID string) (revoked bool, error error)
+	// RevokeUserTokens : Revoke all tokens of the user which were issued
+	// before issuedBefore. The entry is needed until expiresAt.
+	RevokeUserTokens(userID string, issuedBefore time.Time, expiresAt time.Time) (error error)
+	// GetUserRevocation : Get the cutoff of the user, zero if there is none
+	GetUserRevocation(userID string) (issuedBefore time.Time, error error)
+	// PruneExpired : Remove all entries which expired before now
+	PruneExpired(now time.Time) (error error)
+}
+
+// revocation entry of the in-memory revocation store
+type revocation struct {
+	issuedBefore time.Time
+	expiresAt    time.Time
+}
+
+// InMemoryRevocationStore : default RevocationStore which keeps the
+// revocation list in maps. The store is safe for concurrent use.
+type InMemoryRevocationStore struct {
+	mutex            sync.RWMutex
+	tokenRevocations map[string]time.Time
+	userRevocations  map[string]revocation
+}
+
+// NewInMemoryRevocationStore : Create a new empty in-memory revocation store
+func NewInMemoryRevocationStore() *InMemoryRevocationStore {
+	store := new(InMemoryRevocationStore)
+	store.tokenRevocations = make(map[string]time.Time)
+	store.userRevocations = make(map[string]revocation)
+
+	return store
+}
+
+// RevokeToken : Put the token ID on the list, a later expiry is kept
+func (s *InMemoryRevocationStore) RevokeToken(tokenID string, expiresAt time.Time) (error error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if expiresAt.After(s.tokenRevocations[tokenID]) {
+		s.tokenRevocations[tokenID] = expiresAt
+	}
+
+	return nil
+}
+
+// IsTokenRevoked : Check if the token ID is on the list
+func (s *InMemoryRevocationStore) IsTokenRevoked(tokenID string) (revoked bool, error error) {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+
+	_, revoked = s.tokenRevocations[tokenID]
+	return revoked, nil
+}
+
+// RevokeUserTokens : Set the cutoff of the user, a later cutoff and a later expiry are kept
+func (s *InMemoryRevocationStore) RevokeUserTokens(userID string, issuedBefore time.Time, expiresAt time.Time) (error error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	userRevocation := s.userRevocations[userID]
+	if issuedBefore.After(userRevocation.issuedBefore) {
+		userRevocation.issuedBefore = issuedBefore
+	}
+	if expiresAt.After(userRevocation.expiresAt) {
+		userRevocation.expiresAt = expiresAt
+	}
+	s.userRevocations[userID] = userRevocation
+
+	return nil
+}
+
+// GetUserRevocation : Get the cutoff of the user
+func (s *InMemoryRevocationStore) GetUserRevocation(userID string) (issuedBefore time.Time, error error) {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+
+	return s.userRevocations[userID].issuedBefore, nil
+}
+
+// PruneExpired : Remove all entries which expired before now
+func (s *InMemoryRevocationStore) PruneExpired(now time.Time) (error error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	for tokenID, expiresAt := range s.tokenRevocations {
+		if expiresAt.Before(now) {
+			delete(s.tokenRevocations, tokenID)
+		}
+	}
+	for userID, userRevocation := range s.userRevocations {
+		if userRevocation.expiresAt.Before(now) {
+			delete(s.userRevocations, userID)
+		}
+	}
+
+	return nil
+}
+
+// Len : Get the number of entries on the list, e.g. to monitor its size
+func (s *InMemoryRevocationStore) Len() int {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+
+	return len(s.tokenRevocations) + len(s.userRevocations)
+}
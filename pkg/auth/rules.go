@@ -0,0 +1,143 @@
+package auth
+
+import (
+	"regexp"
+	"strings"
+)
+
+// Rule : check which a user name or a password has to pass during sign up
+type Rule interface {
+	Check(value string) (error error)
+}
+
+// InvalidRuleError : returned if a rule can not be created, e.g. because
+// its regular expression is not valid for Go's RE2 syntax
+type InvalidRuleError struct {
+	NameOfRule string
+	Regex      string
+	Err        error
+}
+
+func (e *InvalidRuleError) Error() string {
+	return "Error : Cannot apply invalid regex rule for " + e.NameOfRule + " : " + e.Err.Error()
+}
+
+func (e *InvalidRuleError) Unwrap() error {
+	return e.Err
+}
+
+// Is : an InvalidRuleError matches ErrInvalidRule in errors.Is
+func (e *InvalidRuleError) Is(target error) bool {
+	return target == ErrInvalidRule
+}
+
+// RegexRule : rule which is passed if the value matches a regular expression
+type RegexRule struct {
+	nameOfRule string
+	regex      *regexp.Regexp
+}
+
+// NewRegexRule : Compile regex and create a new RegexRule. An *InvalidRuleError
+// is returned if regex is not a valid regular expression.
+func NewRegexRule(nameOfRule string, regex string) (rule *RegexRule, error error) {
+	compiledRegex, compileError := regexp.Compile(regex)
+	if compileError != nil {
+		return nil, &InvalidRuleError{NameOfRule: nameOfRule, Regex: regex, Err: compileError}
+	}
+
+	rule = new(RegexRule)
+	rule.nameOfRule = nameOfRule
+	rule.regex = compiledRegex
+
+	return rule, nil
+}
+
+// Check : Check if value matches the regular expression of the rule
+func (r *RegexRule) Check(value string) (error error) {
+	if !r.regex.MatchString(value) {
+		error = newError(ErrPolicyViolation, "Error : "+r.nameOfRule+" does not comply to rules. Please make sure it fits to the following regular expression : "+r.regex.String())
+	}
+
+	return error
+}
+
+// AllOfRegexRule : rule which is passed if the value matches every one of
+// several regular expressions. This can replace the lookahead assertions
+// (?=...) which are not supported by Go's regular expressions, e.g.
+// ^(?=.*[0-9])(?=.*[a-z]).{8,32}$ can be expressed as the rules
+// [0-9], [a-z] and ^.{8,32}$.
+type AllOfRegexRule struct {
+	nameOfRule string
+	regexes    []*regexp.Regexp
+}
+
+// NewAllOfRegexRule : Compile all regexes and create a new AllOfRegexRule.
+// An *InvalidRuleError is returned for the first invalid regular expression.
+func NewAllOfRegexRule(nameOfRule string, regexes ...string) (rule *AllOfRegexRule, error error) {
+	rule = new(AllOfRegexRule)
+	rule.nameOfRule = nameOfRule
+
+	for _, regex := range regexes {
+		regexRule, ruleError := NewRegexRule(nameOfRule, regex)
+		if ruleError != nil {
+			return nil, ruleError
+		}
+		rule.regexes = append(rule.regexes, regexRule.regex)
+	}
+
+	return rule, nil
+}
+
+// Check : Check if value matches all regular expressions of the rule
+func (r *AllOfRegexRule) Check(value string) (error error) {
+	successful := true
+	for _, regex := range r.regexes {
+		if !regex.MatchString(value) {
+			successful = false
+		}
+	}
+
+	if !successful {
+		regexStrings := make([]string, len(r.regexes))
+		for i, regex := range r.regexes {
+			regexStrings[i] = regex.String()
+		}
+		error = newError(ErrPolicyViolation, "Error : "+r.nameOfRule+" does not comply to rules. Please make sure it fits to all of the following regular expressions : "+strings.Join(regexStrings, ", "))
+	}
+
+	return error
+}
+
+// RuleFunc : rule which is implemented by an arbitrary function. The
+// description is used in the error message if the function returns false.
+type RuleFunc struct {
+	nameOfRule  string
+	description string
+	check       func(value string) bool
+}
+
+// NewRuleFunc : Create a new rule from a check function
+func NewRuleFunc(nameOfRule string, description string, check func(value string) bool) *RuleFunc {
+	return &RuleFunc{nameOfRule: nameOfRule, description: description, check: check}
+}
+
+// Check : Check value with the check function of the rule
+func (r *RuleFunc) Check(value string) (error error) {
+	if !r.check(value) {
+		error = newError(ErrPolicyViolation, "Error : "+r.nameOfRule+" does not comply to rules. "+r.description)
+	}
+
+	return error
+}
+
+// check value against all rules and stop at the first violated one
+func checkRules(rules []Rule, value string) (error error) {
+	for _, rule := range rules {
+		error = rule.Check(value)
+		if error != nil {
+			break
+		}
+	}
+
+	return error
+}
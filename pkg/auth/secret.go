@@ -0,0 +1,80 @@
+package auth
+
+import (
+	"time"
+)
+
+// secretReloadInterval : the SecretProvider is asked for a changed
+// secret at most this often while tokens are generated or validated
+const secretReloadInterval = 10 * time.Second
+
+// SetSecretProvider : Sign and verify access tokens using HS256 with the
+// secret of the provider. The secret is loaded and checked with
+// CheckSecretStrength immediately. Changes of the secret are picked up
+// while the AuthHandler is used: the key of the new secret signs new
+// tokens and the key of the old secret still verifies tokens until they
+// expired. If the provider fails or returns a weak secret later on, the
+// error is logged and the current secret is kept.
+func (a *AuthHandler) SetSecretProvider(secretProvider SecretProvider) (error error) {
+	if secretProvider == nil {
+		return a.newError(ErrNoSecret, "Error : Secret provider must not be nil!")
+	}
+
+	signingKey, error := a.loadSecret(secretProvider)
+	if error != nil {
+		return error
+	}
+
+	a.configMutex.Lock()
+	defer a.configMutex.Unlock()
+
+	keyRing := NewKeyRing()
+	keyRing.SetClock(a.tokenConfig.clock)
+	keyRing.ScheduleRotation(signingKey.Thumbprint(), signingKey, time.Time{}, 0)
+	a.tokenConfig.keyRing = keyRing
+	a.tokenConfig.allowedAlgorithms = nil
+	a.tokenConfig.secretProvider = secretProvider
+
+	a.secretReloadMutex.Lock()
+	a.lastSecretReload = a.tokenConfig.clock.Now()
+	a.secretReloadMutex.Unlock()
+
+	return nil
+}
+
+// get the secret from the provider and create a HS256 key from it
+func (a *AuthHandler) loadSecret(secretProvider SecretProvider) (signingKey *SigningKey, error error) {
+	secret, error := secretProvider.Secret()
+	if error != nil {
+		return nil, a.logError(error)
+	}
+
+	signingKey, error = NewHMACSigningKey("HS256", secret)
+	return signingKey, a.logError(error)
+}
+
+// rotate the key of the secret if the secret of the provider changed
+// and this was not checked during the last reload interval
+func (a *AuthHandler) reloadSecret(config tokenConfig) {
+	now := config.clock.Now()
+	a.secretReloadMutex.Lock()
+	if now.Sub(a.lastSecretReload) < secretReloadInterval {
+		a.secretReloadMutex.Unlock()
+		return
+	}
+	a.lastSecretReload = now
+	a.secretReloadMutex.Unlock()
+
+	signingKey, error := a.loadSecret(config.secretProvider)
+	if error != nil {
+		return
+	}
+	keyID := signingKey.Thumbprint()
+	if keyID == config.keyRing.SigningKeyID() {
+		return
+	}
+
+	// a secret which is used again may still be in the ring to verify tokens
+	config.keyRing.RemoveKey(keyID)
+	a.logError(config.keyRing.Rotate(keyID, signingKey, config.accessTokenLifetime+config.clockSkew))
+}
@@ -0,0 +1,145 @@
+package auth
+
+import (
+	"bytes"
+	"math"
+	"os"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// MinSecretLength : minimum number of bytes of an HMAC secret
+const MinSecretLength = 32
+
+// MinSecretEntropyBits : minimum estimated entropy of an HMAC secret
+const MinSecretEntropyBits = 128
+
+// SecretProvider : source of the HMAC secret with which the AuthHandler
+// signs and verifies access tokens using HS256. The secret is requested
+// again from time to time, if it changes the key of the AuthHandler is
+// rotated. Errors have to wrap ErrNoSecret.
+type SecretProvider interface {
+	// Secret : Get the current secret
+	Secret() (secret []byte, error error)
+}
+
+// StaticSecretProvider : SecretProvider which always returns the same secret
+type StaticSecretProvider struct {
+	secret []byte
+}
+
+// NewStaticSecretProvider : Create a SecretProvider for a fixed secret
+func NewStaticSecretProvider(secret []byte) *StaticSecretProvider {
+	return &StaticSecretProvider{secret: append([]byte(nil), secret...)}
+}
+
+// Secret : Get the fixed secret
+func (p *StaticSecretProvider) Secret() (secret []byte, error error) {
+	if len(p.secret) == 0 {
+		return nil, newError(ErrNoSecret, "Error : No Secret for JWT generation set!")
+	}
+
+	return append([]byte(nil), p.secret...), nil
+}
+
+// EnvSecretProvider : SecretProvider which reads the secret from an
+// environment variable of the host machine
+type EnvSecretProvider struct {
+	name string
+}
+
+// NewEnvSecretProvider : Create a SecretProvider for the environment variable with the given name
+func NewEnvSecretProvider(name string) *EnvSecretProvider {
+	return &EnvSecretProvider{name: name}
+}
+
+// Secret : Get the value of the environment variable
+func (p *EnvSecretProvider) Secret() (secret []byte, error error) {
+	value := os.Getenv(p.name)
+	if value == "" {
+		return nil, newError(ErrNoSecret, "Error : No Secret for JWT generation set in environment variable '"+p.name+"'!")
+	}
+
+	return []byte(value), nil
+}
+
+// FileSecretProvider : SecretProvider which reads the secret from a file,
+// e.g. a mounted Kubernetes or Docker secret. The file is read again when
+// its modification time or its size changes. Surrounding whitespace like a
+// trailing newline is not part of the secret. The provider is safe for
+// concurrent use.
+type FileSecretProvider struct {
+	path    string
+	mutex   sync.Mutex
+	secret  []byte
+	modTime time.Time
+	size    int64
+}
+
+// NewFileSecretProvider : Create a SecretProvider for the file at the given path
+func NewFileSecretProvider(path string) *FileSecretProvider {
+	return &FileSecretProvider{path: path}
+}
+
+// Secret : Get the content of the file, it is only read again if it changed
+func (p *FileSecretProvider) Secret() (secret []byte, error error) {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+
+	fileInfo, statError := os.Stat(p.path)
+	if statError != nil {
+		return nil, newError(ErrNoSecret, "Error : Unable to read Secret for JWT generation from file '"+p.path+"' : "+statError.Error())
+	}
+
+	if p.secret == nil || !fileInfo.ModTime().Equal(p.modTime) || fileInfo.Size() != p.size {
+		content, readError := os.ReadFile(p.path)
+		if readError != nil {
+			return nil, newError(ErrNoSecret, "Error : Unable to read Secret for JWT generation from file '"+p.path+"' : "+readError.Error())
+		}
+		content = bytes.TrimSpace(content)
+		if len(content) == 0 {
+			return nil, newError(ErrNoSecret, "Error : No Secret for JWT generation set in file '"+p.path+"'!")
+		}
+
+		p.secret = content
+		p.modTime = fileInfo.ModTime()
+		p.size = fileInfo.Size()
+	}
+
+	return append([]byte(nil), p.secret...), nil
+}
+
+// CheckSecretStrength : Check if the secret is long enough and if its
+// estimated entropy is high enough to sign tokens with HMAC. The entropy
+// is estimated from the frequency of the bytes of the secret, so random
+// secrets should be longer than the minimum, e.g. 32 random bytes encoded
+// as hex or base64.
+func CheckSecretStrength(secret []byte) (error error) {
+	if len(secret) < MinSecretLength {
+		return newError(ErrWeakSecret, "Error : Secret for JWT generation has to be at least "+strconv.Itoa(MinSecretLength)+" bytes long!")
+	}
+
+	if estimateEntropyBits(secret) < MinSecretEntropyBits {
+		return newError(ErrWeakSecret, "Error : Secret for JWT generation is too predictable. Please use a random secret!")
+	}
+
+	return nil
+}
+
+// estimate the entropy of the secret in bits as its length times the
+// Shannon entropy of its bytes
+func estimateEntropyBits(secret []byte) float64 {
+	counts := make(map[byte]int)
+	for _, b := range secret {
+		counts[b]++
+	}
+
+	entropyPerByte := 0.0
+	for _, count := range counts {
+		probability := float64(count) / float64(len(secret))
+		entropyPerByte -= probability * math.Log2(probability)
+	}
+
+	return entropyPerByte * float64(len(secret))
+}
@@ -0,0 +1,147 @@
+package auth
+
+import (
+	"errors"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// sessionTouchInterval : LastSeenAt of a session is only written to the
+// session store if it is older than this, so that not every request writes
+const sessionTouchInterval = time.Minute
+
+// sessionPruneInterval : expired sessions are removed at most this often
+// while users log in or refresh their tokens
+const sessionPruneInterval = time.Minute
+
+// ClientInfo : information about the client which logs in, stored with
+// the session so that users can recognize their sessions
+type ClientInfo struct {
+	UserAgent string
+	IP        string
+}
+
+// SetSessionStore : Set the store in which the sessions of the users are
+// kept. Has to be called before the AuthHandler is used by several
+// goroutines.
+func (a *AuthHandler) SetSessionStore(sessionStore SessionStore) {
+	a.sessionStore = sessionStore
+}
+
+// GetSessionStore : Get the SessionStore which is used by the AuthHandler
+func (a *AuthHandler) GetSessionStore() SessionStore {
+	return a.sessionStore
+}
+
+// ListSessions : Get all sessions of the user which are not expired
+func (a *AuthHandler) ListSessions(userID string) (sessions []*Session, error error) {
+	now := a.getTokenConfig().clock.Now()
+
+	storedSessions, error := a.sessionStore.ListSessionsByUserID(userID)
+	if error != nil {
+		return nil, a.logError(error)
+	}
+
+	sessions = make([]*Session, 0, len(storedSessions))
+	for _, session := range storedSessions {
+		if now.Before(session.ExpiresAt) {
+			sessions = append(sessions, session)
+		}
+	}
+
+	return sessions, nil
+}
+
+// RevokeSession : Log out a single session. Its access tokens and refresh
+// tokens are not valid anymore.
+func (a *AuthHandler) RevokeSession(sessionID string) (error error) {
+	error = a.sessionStore.DeleteSession(sessionID)
+	if error != nil {
+		return a.logError(error)
+	}
+
+	return a.logError(a.refreshTokenStore.RevokeRefreshTokenFamily(sessionID, a.getTokenConfig().clock.Now()))
+}
+
+// RevokeAllSessions : Log out all sessions of the user
+func (a *AuthHandler) RevokeAllSessions(userID string) (error error) {
+	sessions, error := a.sessionStore.ListSessionsByUserID(userID)
+	if error != nil {
+		return a.logError(error)
+	}
+
+	error = a.sessionStore.DeleteSessionsByUserID(userID)
+	if error != nil {
+		return a.logError(error)
+	}
+
+	now := a.getTokenConfig().clock.Now()
+	for _, session := range sessions {
+		error = a.refreshTokenStore.RevokeRefreshTokenFamily(session.ID, now)
+		if error != nil {
+			return a.logError(error)
+		}
+	}
+
+	return nil
+}
+
+// create and store a new session of the user on the client
+func (a *AuthHandler) createSession(user *User, client ClientInfo) (session *Session, error error) {
+	config := a.getTokenConfig()
+	now := config.clock.Now()
+	a.pruneSessions(now)
+
+	session = &Session{
+		ID:         uuid.New().String(),
+		UserID:     user.ID,
+		CreatedAt:  now,
+		LastSeenAt: now,
+		ExpiresAt:  now.Add(config.refreshTokenLifetime),
+		UserAgent:  client.UserAgent,
+		IP:         client.IP,
+	}
+
+	error = a.logError(a.sessionStore.CreateSession(session))
+	if error != nil {
+		return nil, error
+	}
+
+	return session, nil
+}
+
+// get the session of a user which has to exist and must not be expired
+func (a *AuthHandler) getActiveSession(sessionID string, userID string, now time.Time) (session *Session, error error) {
+	session, error = a.sessionStore.GetSession(sessionID)
+	if errors.Is(error, ErrSessionNotFound) || (error == nil && (session.UserID != userID || !now.Before(session.ExpiresAt))) {
+		return nil, a.newError(ErrInvalidToken, "Error : Session is not valid anymore!")
+	} else if error != nil {
+		return nil, a.logError(error)
+	}
+
+	return session, nil
+}
+
+// remember when the session was used the last time
+func (a *AuthHandler) touchSession(session *Session, now time.Time) (error error) {
+	if now.Sub(session.LastSeenAt) < sessionTouchInterval {
+		return nil
+	}
+
+	session.LastSeenAt = now
+	return a.logError(a.sessionStore.TouchSession(session.ID, now))
+}
+
+// remove expired sessions if this was not done during the last prune interval
+func (a *AuthHandler) pruneSessions(now time.Time) {
+	a.sessionPruneMutex.Lock()
+	if now.Sub(a.lastSessionPrune) < sessionPruneInterval {
+		a.sessionPruneMutex.Unlock()
+		return
+	}
+	a.lastSessionPrune = now
+	a.sessionPruneMutex.Unlock()
+
+	a.logError(a.sessionStore.DeleteExpiredSessions(now))
+}
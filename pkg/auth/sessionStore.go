@@ -0,0 +1,198 @@
+package auth
+
+import (
+	"sort"
+	"sync"
+	"time"
+)
+
+// Session : login of a user on one client. A user can have many sessions
+// at the same time, the access tokens of a session reference it by the
+// sid claim and its refresh tokens form a family with the ID of the session.
+type Session struct {
+	ID         string
+	UserID     string
+	CreatedAt  time.Time
+	LastSeenAt time.Time
+	ExpiresAt  time.Time
+	UserAgent  string
+	IP         string
+}
+
+// create a copy of the session, so that stored sessions can
+// not be modified from outside of a SessionStore
+func (s *Session) copy() *Session {
+	sessionCopy := *s
+	return &sessionCopy
+}
+
+// SessionStore : persistence layer used by the AuthHandler to manage
+// sessions. Errors have to wrap ErrSessionNotFound for unknown sessions
+// and ErrStore for failures of the underlying storage.
+type SessionStore interface {
+	// CreateSession : Add a new session to the store
+	CreateSession(session *Session) (error error)
+	// GetSession : Get session by its ID
+	GetSession(sessionID string) (session *Session, error error)
+	// UpdateSession : Overwrite an already existing session
+	UpdateSession(session *Session) (error error)
+	// TouchSession : Set LastSeenAt of the session if it is later than
+	// the stored value without changing any other field
+	TouchSession(sessionID string, lastSeenAt time.Time) (error error)
+	// ListSessionsByUserID : Get all sessions of the user
+	ListSessionsByUserID(userID string) (sessions []*Session, error error)
+	// DeleteSession : Remove session with the given ID from the store
+	DeleteSession(sessionID string) (error error)
+	// DeleteSessionsByUserID : Remove all sessions of the user
+	DeleteSessionsByUserID(userID string) (error error)
+	// DeleteExpiredSessions : Remove all sessions which expired before now
+	DeleteExpiredSessions(now time.Time) (error error)
+}
+
+// InMemorySessionStore : default SessionStore which keeps all sessions in
+// maps. All sessions are lost when the process terminates and are not
+// shared with other processes, see SQLSessionStore. The store is safe for
+// concurrent use.
+type InMemorySessionStore struct {
+	mutex            sync.RWMutex
+	sessionsByID     map[string]*Session
+	sessionIDsByUser map[string]map[string]bool
+}
+
+// NewInMemorySessionStore : Create a new empty in-memory session store
+func NewInMemorySessionStore() *InMemorySessionStore {
+	store := new(InMemorySessionStore)
+	store.sessionsByID = make(map[string]*Session)
+	store.sessionIDsByUser = make(map[string]map[string]bool)
+
+	return store
+}
+
+// CreateSession : Store a copy of the given session if its ID is not used yet
+func (s *InMemorySessionStore) CreateSession(session *Session) (error error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if _, sessionFound := s.sessionsByID[session.ID]; sessionFound {
+		return newError(ErrInvalidInput, "Error : Session with ID '"+session.ID+"' already exists!")
+	}
+
+	s.sessionsByID[session.ID] = session.copy()
+	if s.sessionIDsByUser[session.UserID] == nil {
+		s.sessionIDsByUser[session.UserID] = make(map[string]bool)
+	}
+	s.sessionIDsByUser[session.UserID][session.ID] = true
+
+	return nil
+}
+
+// GetSession : Get a copy of the session with the given ID
+func (s *InMemorySessionStore) GetSession(sessionID string) (session *Session, error error) {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+
+	storedSession, sessionFound := s.sessionsByID[sessionID]
+	if !sessionFound {
+		return nil, newError(ErrSessionNotFound, "Error : No session found for ID : '"+sessionID+"' !")
+	}
+
+	return storedSession.copy(), nil
+}
+
+// UpdateSession : Overwrite the stored session with a copy of the given session
+func (s *InMemorySessionStore) UpdateSession(session *Session) (error error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	storedSession, sessionFound := s.sessionsByID[session.ID]
+	if !sessionFound || storedSession.UserID != session.UserID {
+		return newError(ErrSessionNotFound, "Error : No session found for ID : '"+session.ID+"' !")
+	}
+	s.sessionsByID[session.ID] = session.copy()
+
+	return nil
+}
+
+// TouchSession : Move LastSeenAt of the session forward
+func (s *InMemorySessionStore) TouchSession(sessionID string, lastSeenAt time.Time) (error error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	storedSession, sessionFound := s.sessionsByID[sessionID]
+	if !sessionFound {
+		return newError(ErrSessionNotFound, "Error : No session found for ID : '"+sessionID+"' !")
+	}
+	if lastSeenAt.After(storedSession.LastSeenAt) {
+		storedSession.LastSeenAt = lastSeenAt
+	}
+
+	return nil
+}
+
+// ListSessionsByUserID : Get copies of all sessions of the user sorted by creation time
+func (s *InMemorySessionStore) ListSessionsByUserID(userID string) (sessions []*Session, error error) {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+
+	sessions = make([]*Session, 0, len(s.sessionIDsByUser[userID]))
+	for sessionID := range s.sessionIDsByUser[userID] {
+		sessions = append(sessions, s.sessionsByID[sessionID].copy())
+	}
+	sort.Slice(sessions, func(i, j int) bool {
+		if sessions[i].CreatedAt.Equal(sessions[j].CreatedAt) {
+			return sessions[i].ID < sessions[j].ID
+		}
+		return sessions[i].CreatedAt.Before(sessions[j].CreatedAt)
+	})
+
+	return sessions, nil
+}
+
+// DeleteSession : Remove the session with the given ID
+func (s *InMemorySessionStore) DeleteSession(sessionID string) (error error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	storedSession, sessionFound := s.sessionsByID[sessionID]
+	if !sessionFound {
+		return newError(ErrSessionNotFound, "Error : No session found for ID : '"+sessionID+"' !")
+	}
+	s.deleteSession(storedSession)
+
+	return nil
+}
+
+// DeleteSessionsByUserID : Remove all sessions of the user
+func (s *InMemorySessionStore) DeleteSessionsByUserID(userID string) (error error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	for sessionID := range s.sessionIDsByUser[userID] {
+		s.deleteSession(s.sessionsByID[sessionID])
+	}
+
+	return nil
+}
+
+// DeleteExpiredSessions : Remove all sessions which expired before now
+func (s *InMemorySessionStore) DeleteExpiredSessions(now time.Time) (error error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	for _, storedSession := range s.sessionsByID {
+		if storedSession.ExpiresAt.Before(now) {
+			s.deleteSession(storedSession)
+		}
+	}
+
+	return nil
+}
+
+// remove a session from all maps, the caller has to hold the lock
+func (s *InMemorySessionStore) deleteSession(session *Session) {
+	delete(s.sessionsByID, session.ID)
+	delete(s.sessionIDsByUser[session.UserID], session.ID)
+	if len(s.sessionIDsByUser[session.UserID]) == 0 {
+		delete(s.sessionIDsByUser, session.UserID)
+	}
+}
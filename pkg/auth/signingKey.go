@@ -0,0 +1,186 @@
+package auth
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"os"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// MinRSAKeyBits : minimal size of RSA keys which are accepted for signing
+// and verifying JWTs
+const MinRSAKeyBits = 2048
+
+// SigningKey : key and algorithm with which access tokens are signed and
+// verified. A SigningKey which was created from a public key can only
+// verify tokens, e.g. in a downstream service which must not hold the
+// private key.
+type SigningKey struct {
+	method    jwt.SigningMethod
+	signKey   interface{}
+	verifyKey interface{}
+}
+
+// NewHMACSigningKey : Create a SigningKey for one of the HMAC algorithms
+// HS256, HS384 or HS512. The secret is used for signing and verifying and
+// has to pass CheckSecretStrength.
+func NewHMACSigningKey(algorithm string, secret []byte) (signingKey *SigningKey, error error) {
+	method, isHMAC := jwt.GetSigningMethod(algorithm).(*jwt.SigningMethodHMAC)
+	if !isHMAC {
+		return nil, newError(ErrInvalidKey, "Error : '"+algorithm+"' is no HMAC signing algorithm!")
+	}
+	if error = CheckSecretStrength(secret); error != nil {
+		return nil, error
+	}
+
+	key := append([]byte(nil), secret...)
+	return &SigningKey{method: method, signKey: key, verifyKey: key}, nil
+}
+
+// NewSigningKeyFromPEM : Create a SigningKey from a PEM encoded private key.
+// RSA keys (RS256, RS384, RS512, PS256, PS384, PS512), ECDSA keys (ES256,
+// ES384, ES512) and Ed25519 keys (EdDSA) in PKCS#1, SEC 1 or PKCS#8 format
+// are supported. The public key is derived from the private key.
+func NewSigningKeyFromPEM(algorithm string, pemBytes []byte) (*SigningKey, error) {
+	method, methodError := asymmetricSigningMethod(algorithm)
+	if methodError != nil {
+		return nil, methodError
+	}
+
+	var privateKey crypto.Signer
+	var parseError error
+	switch method.(type) {
+	case *jwt.SigningMethodRSA, *jwt.SigningMethodRSAPSS:
+		privateKey, parseError = jwt.ParseRSAPrivateKeyFromPEM(pemBytes)
+	case *jwt.SigningMethodECDSA:
+		privateKey, parseError = jwt.ParseECPrivateKeyFromPEM(pemBytes)
+	case *jwt.SigningMethodEd25519:
+		var key crypto.PrivateKey
+		key, parseError = jwt.ParseEdPrivateKeyFromPEM(pemBytes)
+		privateKey, _ = key.(crypto.Signer)
+	}
+	if parseError != nil || privateKey == nil {
+		return nil, newError(ErrInvalidKey, "Error : Unable to parse private key for '"+algorithm+"'!")
+	}
+
+	signingKey := &SigningKey{method: method, signKey: privateKey, verifyKey: privateKey.Public()}
+	if sizeError := signingKey.checkKeySize(); sizeError != nil {
+		return nil, sizeError
+	}
+
+	return signingKey, nil
+}
+
+// NewVerificationKeyFromPEM : Create a SigningKey from a PEM encoded public
+// key (PKIX, PKCS#1 for RSA or a certificate) which can only verify tokens
+func NewVerificationKeyFromPEM(algorithm string, pemBytes []byte) (*SigningKey, error) {
+	method, methodError := asymmetricSigningMethod(algorithm)
+	if methodError != nil {
+		return nil, methodError
+	}
+
+	var publicKey crypto.PublicKey
+	var parseError error
+	switch method.(type) {
+	case *jwt.SigningMethodRSA, *jwt.SigningMethodRSAPSS:
+		publicKey, parseError = jwt.ParseRSAPublicKeyFromPEM(pemBytes)
+	case *jwt.SigningMethodECDSA:
+		publicKey, parseError = jwt.ParseECPublicKeyFromPEM(pemBytes)
+	case *jwt.SigningMethodEd25519:
+		publicKey, parseError = jwt.ParseEdPublicKeyFromPEM(pemBytes)
+	}
+	if parseError != nil || publicKey == nil {
+		return nil, newError(ErrInvalidKey, "Error : Unable to parse public key for '"+algorithm+"'!")
+	}
+
+	signingKey := &SigningKey{method: method, verifyKey: publicKey}
+	if sizeError := signingKey.checkKeySize(); sizeError != nil {
+		return nil, sizeError
+	}
+
+	return signingKey, nil
+}
+
+// LoadSigningKeyFromFile : Create a SigningKey from a PEM file which
+// contains a private key, see NewSigningKeyFromPEM
+func LoadSigningKeyFromFile(algorithm string, path string) (signingKey *SigningKey, error error) {
+	pemBytes, readError := os.ReadFile(path)
+	if readError != nil {
+		return nil, newError(ErrInvalidKey, "Error : Unable to read private key file '"+path+"' : "+readError.Error())
+	}
+
+	return NewSigningKeyFromPEM(algorithm, pemBytes)
+}
+
+// LoadVerificationKeyFromFile : Create a SigningKey from a PEM file which
+// contains a public key, see NewVerificationKeyFromPEM
+func LoadVerificationKeyFromFile(algorithm string, path string) (signingKey *SigningKey, error error) {
+	pemBytes, readError := os.ReadFile(path)
+	if readError != nil {
+		return nil, newError(ErrInvalidKey, "Error : Unable to read public key file '"+path+"' : "+readError.Error())
+	}
+
+	return NewVerificationKeyFromPEM(algorithm, pemBytes)
+}
+
+// Algorithm : Get the signing algorithm (alg header) of the key
+func (k *SigningKey) Algorithm() string {
+	return k.method.Alg()
+}
+
+// CanSign : Check if the key can sign tokens or only verify them
+func (k *SigningKey) CanSign() bool {
+	return k.signKey != nil
+}
+
+// PublicKey : Get the public key of an asymmetric key. For HMAC keys nil is
+// returned, so that the secret is never handed out.
+func (k *SigningKey) PublicKey() crypto.PublicKey {
+	if _, isHMAC := k.method.(*jwt.SigningMethodHMAC); isHMAC {
+		return nil
+	}
+	return k.verifyKey
+}
+
+// check if the method of a token is exactly the method of the key,
+// so that e.g. a public key can never be used as HMAC secret
+func (k *SigningKey) matches(token *jwt.Token) bool {
+	return token.Method != nil && token.Method.Alg() == k.method.Alg()
+}
+
+// get the signing method of an asymmetric algorithm
+func asymmetricSigningMethod(algorithm string) (method jwt.SigningMethod, error error) {
+	method = jwt.GetSigningMethod(algorithm)
+	switch method.(type) {
+	case *jwt.SigningMethodRSA, *jwt.SigningMethodRSAPSS, *jwt.SigningMethodECDSA, *jwt.SigningMethodEd25519:
+		return method, nil
+	default:
+		return nil, newError(ErrInvalidKey, "Error : '"+algorithm+"' is no supported asymmetric signing algorithm!")
+	}
+}
+
+// check that RSA keys are large enough and ECDSA keys use the curve of
+// the algorithm, e.g. P-256 for ES256
+func (k *SigningKey) checkKeySize() (error error) {
+	switch publicKey := k.verifyKey.(type) {
+	case *rsa.PublicKey:
+		if publicKey.N.BitLen() < MinRSAKeyBits {
+			return newError(ErrInvalidKey, "Error : RSA keys must have at least 2048 bits!")
+		}
+	case *ecdsa.PublicKey:
+		curves := map[string]elliptic.Curve{"ES256": elliptic.P256(), "ES384": elliptic.P384(), "ES512": elliptic.P521()}
+		if publicKey.Curve != curves[k.Algorithm()] {
+			return newError(ErrInvalidKey, "Error : Curve of ECDSA key does not fit to '"+k.Algorithm()+"'!")
+		}
+	case ed25519.PublicKey:
+		if len(publicKey) != ed25519.PublicKeySize {
+			return newError(ErrInvalidKey, "Error : Invalid Ed25519 key!")
+		}
+	}
+
+	return nil
+}
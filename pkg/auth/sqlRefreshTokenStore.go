@@ -0,0 +1,130 @@
+package auth
+
+import (
+	"database/sql"
+	"time"
+)
+
+// columns of the refresh_tokens table in the order in which they are scanned
+const refreshTokenColumns = "id, family_id, user_id, token_hash, issued_at, expires_at, used_at, revoked_at"
+
+// SQLRefreshTokenStore : RefreshTokenStore which persists the hashes of
+// refresh tokens with database/sql, so that refresh tokens survive
+// restarts and can be shared by several processes. The queries are
+// written for SQLite, the schema is created and upgraded with the
+// embedded migrations (see Migrate).
+type SQLRefreshTokenStore struct {
+	db *sql.DB
+}
+
+// NewSQLRefreshTokenStore : Create a new SQLRefreshTokenStore and migrate
+// the database schema to the latest version
+func NewSQLRefreshTokenStore(db *sql.DB) (store *SQLRefreshTokenStore, error error) {
+	error = Migrate(db)
+	if error != nil {
+		return nil, error
+	}
+
+	store = new(SQLRefreshTokenStore)
+	store.db = db
+
+	return store, nil
+}
+
+// CreateRefreshToken : Insert a new refresh token if ID and hash are not used yet
+func (s *SQLRefreshTokenStore) CreateRefreshToken(token *RefreshToken) (error error) {
+	_, error = s.db.Exec("INSERT INTO refresh_tokens ("+refreshTokenColumns+") VALUES (?, ?, ?, ?, ?, ?, ?, ?)",
+		token.ID, token.FamilyID, token.UserID, token.TokenHash, timeToColumn(token.IssuedAt),
+		timeToColumn(token.ExpiresAt), timeToColumn(token.UsedAt), timeToColumn(token.RevokedAt))
+	if error != nil {
+		var count int
+		s.db.QueryRow("SELECT COUNT(*) FROM refresh_tokens WHERE id = ? OR token_hash = ?", token.ID, token.TokenHash).Scan(&count)
+		if count > 0 {
+			return newError(ErrInvalidInput, "Error : Refresh token with ID '"+token.ID+"' already exists!")
+		}
+		return newError(ErrStore, "Error : Unable to write refresh token '"+token.ID+"' : "+error.Error())
+	}
+
+	return nil
+}
+
+// GetRefreshTokenByHash : Get refresh token by the hash of the token
+func (s *SQLRefreshTokenStore) GetRefreshTokenByHash(tokenHash string) (token *RefreshToken, error error) {
+	row := s.db.QueryRow("SELECT "+refreshTokenColumns+" FROM refresh_tokens WHERE token_hash = ?", tokenHash)
+	token, error = scanRefreshToken(row)
+	if error == sql.ErrNoRows {
+		error = newError(ErrInvalidToken, "Error : No refresh token found!")
+	}
+
+	return token, error
+}
+
+// MarkRefreshTokenUsed : Mark the refresh token as used if it was not
+// used yet. The check is part of the update statement, so that a token
+// can only be exchanged once even by concurrent requests.
+func (s *SQLRefreshTokenStore) MarkRefreshTokenUsed(tokenID string, usedAt time.Time) (error error) {
+	result, error := s.db.Exec("UPDATE refresh_tokens SET used_at = ? WHERE id = ? AND used_at = 0", timeToColumn(usedAt), tokenID)
+	if error != nil {
+		return newError(ErrStore, "Error : Unable to write refresh token '"+tokenID+"' : "+error.Error())
+	}
+
+	affectedRows, error := result.RowsAffected()
+	if error != nil {
+		return newError(ErrStore, "Error : Unable to check affected refresh tokens : "+error.Error())
+	}
+	if affectedRows > 0 {
+		return nil
+	}
+
+	// no row was hit if the token is unknown or was already used
+	var count int
+	error = s.db.QueryRow("SELECT COUNT(*) FROM refresh_tokens WHERE id = ?", tokenID).Scan(&count)
+	if error != nil {
+		return newError(ErrStore, "Error : Unable to read refresh token '"+tokenID+"' : "+error.Error())
+	}
+	if count == 0 {
+		return newError(ErrInvalidToken, "Error : No refresh token found for ID : '"+tokenID+"' !")
+	}
+
+	return ErrRefreshTokenReused
+}
+
+// RevokeRefreshTokenFamily : Revoke all refresh tokens of the family which were not revoked yet
+func (s *SQLRefreshTokenStore) RevokeRefreshTokenFamily(familyID string, revokedAt time.Time) (error error) {
+	_, error = s.db.Exec("UPDATE refresh_tokens SET revoked_at = ? WHERE family_id = ? AND revoked_at = 0", timeToColumn(revokedAt), familyID)
+	if error != nil {
+		return newError(ErrStore, "Error : Unable to revoke refresh token family '"+familyID+"' : "+error.Error())
+	}
+
+	return nil
+}
+
+// DeleteExpiredRefreshTokens : Remove all refresh tokens which expired before now
+func (s *SQLRefreshTokenStore) DeleteExpiredRefreshTokens(now time.Time) (error error) {
+	_, error = s.db.Exec("DELETE FROM refresh_tokens WHERE expires_at < ?", timeToColumn(now))
+	if error != nil {
+		return newError(ErrStore, "Error : Unable to delete expired refresh tokens : "+error.Error())
+	}
+
+	return nil
+}
+
+// scan a single refresh token in the order of refreshTokenColumns.
+// sql.ErrNoRows is returned unchanged, so that callers can build a
+// fitting message.
+func scanRefreshToken(row rowScanner) (token *RefreshToken, error error) {
+	token = new(RefreshToken)
+	var issuedAt, expiresAt, usedAt, revokedAt int64
+	error = row.Scan(&token.ID, &token.FamilyID, &token.UserID, &token.TokenHash, &issuedAt, &expiresAt, &usedAt, &revokedAt)
+	if error == sql.ErrNoRows {
+		return nil, error
+	} else if error != nil {
+		return nil, newError(ErrStore, "Error : Unable to read refresh token : "+error.Error())
+	}
+	token.IssuedAt = timeFromColumn(issuedAt)
+	token.ExpiresAt = timeFromColumn(expiresAt)
+	token.UsedAt = timeFromColumn(usedAt)
+	token.RevokedAt = timeFromColumn(revokedAt)
+
+	return token, nil
+}
@@ -0,0 +1,166 @@
+package auth
+
+import (
+	"database/sql"
+	"time"
+)
+
+// columns of the sessions table in the order in which they are scanned
+const sessionColumns = "id, user_id, created_at, last_seen_at, expires_at, user_agent, ip"
+
+// SQLSessionStore : SessionStore which persists sessions with
+// database/sql, so that sessions survive restarts and can be shared by
+// several processes. The queries are written for SQLite, the schema is
+// created and upgraded with the embedded migrations (see Migrate).
+type SQLSessionStore struct {
+	db *sql.DB
+}
+
+// NewSQLSessionStore : Create a new SQLSessionStore and migrate the
+// database schema to the latest version
+func NewSQLSessionStore(db *sql.DB) (store *SQLSessionStore, error error) {
+	error = Migrate(db)
+	if error != nil {
+		return nil, error
+	}
+
+	store = new(SQLSessionStore)
+	store.db = db
+
+	return store, nil
+}
+
+// CreateSession : Insert a new session if its ID is not used yet
+func (s *SQLSessionStore) CreateSession(session *Session) (error error) {
+	_, error = s.db.Exec("INSERT INTO sessions ("+sessionColumns+") VALUES (?, ?, ?, ?, ?, ?, ?)",
+		session.ID, session.UserID, timeToColumn(session.CreatedAt), timeToColumn(session.LastSeenAt),
+		timeToColumn(session.ExpiresAt), session.UserAgent, session.IP)
+	if error != nil {
+		if _, lookUpError := s.GetSession(session.ID); lookUpError == nil {
+			return newError(ErrInvalidInput, "Error : Session with ID '"+session.ID+"' already exists!")
+		}
+		return newError(ErrStore, "Error : Unable to write session '"+session.ID+"' : "+error.Error())
+	}
+
+	return nil
+}
+
+// GetSession : Get session by its ID
+func (s *SQLSessionStore) GetSession(sessionID string) (session *Session, error error) {
+	row := s.db.QueryRow("SELECT "+sessionColumns+" FROM sessions WHERE id = ?", sessionID)
+	session, error = scanSession(row)
+	if error == sql.ErrNoRows {
+		error = newError(ErrSessionNotFound, "Error : No session found for ID : '"+sessionID+"' !")
+	}
+
+	return session, error
+}
+
+// UpdateSession : Overwrite all columns of an already existing session of the same user
+func (s *SQLSessionStore) UpdateSession(session *Session) (error error) {
+	result, error := s.db.Exec("UPDATE sessions SET created_at = ?, last_seen_at = ?, expires_at = ?, user_agent = ?, ip = ? WHERE id = ? AND user_id = ?",
+		timeToColumn(session.CreatedAt), timeToColumn(session.LastSeenAt), timeToColumn(session.ExpiresAt),
+		session.UserAgent, session.IP, session.ID, session.UserID)
+	if error != nil {
+		return newError(ErrStore, "Error : Unable to write session '"+session.ID+"' : "+error.Error())
+	}
+
+	return checkSessionAffected(result, session.ID)
+}
+
+// TouchSession : Move LastSeenAt of the session forward. The comparison
+// is part of the update statement, so that concurrent requests can not
+// move it back.
+func (s *SQLSessionStore) TouchSession(sessionID string, lastSeenAt time.Time) (error error) {
+	result, error := s.db.Exec("UPDATE sessions SET last_seen_at = MAX(last_seen_at, ?) WHERE id = ?", timeToColumn(lastSeenAt), sessionID)
+	if error != nil {
+		return newError(ErrStore, "Error : Unable to write session '"+sessionID+"' : "+error.Error())
+	}
+
+	return checkSessionAffected(result, sessionID)
+}
+
+// ListSessionsByUserID : Get all sessions of the user sorted by creation time
+func (s *SQLSessionStore) ListSessionsByUserID(userID string) (sessions []*Session, error error) {
+	rows, error := s.db.Query("SELECT "+sessionColumns+" FROM sessions WHERE user_id = ? ORDER BY created_at, id", userID)
+	if error != nil {
+		return nil, newError(ErrStore, "Error : Unable to list sessions : "+error.Error())
+	}
+	defer rows.Close()
+
+	sessions = []*Session{}
+	for rows.Next() {
+		session, scanError := scanSession(rows)
+		if scanError != nil {
+			return nil, scanError
+		}
+		sessions = append(sessions, session)
+	}
+
+	if error = rows.Err(); error != nil {
+		return nil, newError(ErrStore, "Error : Unable to list sessions : "+error.Error())
+	}
+
+	return sessions, nil
+}
+
+// DeleteSession : Remove the session with the given ID
+func (s *SQLSessionStore) DeleteSession(sessionID string) (error error) {
+	result, error := s.db.Exec("DELETE FROM sessions WHERE id = ?", sessionID)
+	if error != nil {
+		return newError(ErrStore, "Error : Unable to delete session '"+sessionID+"' : "+error.Error())
+	}
+
+	return checkSessionAffected(result, sessionID)
+}
+
+// DeleteSessionsByUserID : Remove all sessions of the user
+func (s *SQLSessionStore) DeleteSessionsByUserID(userID string) (error error) {
+	_, error = s.db.Exec("DELETE FROM sessions WHERE user_id = ?", userID)
+	if error != nil {
+		return newError(ErrStore, "Error : Unable to delete sessions of user '"+userID+"' : "+error.Error())
+	}
+
+	return nil
+}
+
+// DeleteExpiredSessions : Remove all sessions which expired before now
+func (s *SQLSessionStore) DeleteExpiredSessions(now time.Time) (error error) {
+	_, error = s.db.Exec("DELETE FROM sessions WHERE expires_at < ?", timeToColumn(now))
+	if error != nil {
+		return newError(ErrStore, "Error : Unable to delete expired sessions : "+error.Error())
+	}
+
+	return nil
+}
+
+// scan a single session in the order of sessionColumns. sql.ErrNoRows is
+// returned unchanged, so that callers can build a fitting message.
+func scanSession(row rowScanner) (session *Session, error error) {
+	session = new(Session)
+	var createdAt, lastSeenAt, expiresAt int64
+	error = row.Scan(&session.ID, &session.UserID, &createdAt, &lastSeenAt, &expiresAt, &session.UserAgent, &session.IP)
+	if error == sql.ErrNoRows {
+		return nil, error
+	} else if error != nil {
+		return nil, newError(ErrStore, "Error : Unable to read session : "+error.Error())
+	}
+	session.CreatedAt = timeFromColumn(createdAt)
+	session.LastSeenAt = timeFromColumn(lastSeenAt)
+	session.ExpiresAt = timeFromColumn(expiresAt)
+
+	return session, nil
+}
+
+// make sure that an update or delete statement really hit a session
+func checkSessionAffected(result sql.Result, sessionID string) (error error) {
+	affectedRows, error := result.RowsAffected()
+	if error != nil {
+		return newError(ErrStore, "Error : Unable to check affected sessions : "+error.Error())
+	}
+	if affectedRows == 0 {
+		return newError(ErrSessionNotFound, "Error : No session found for ID : '"+sessionID+"' !")
+	}
+
+	return nil
+}
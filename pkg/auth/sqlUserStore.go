@@ -0,0 +1,289 @@
+package auth
+
+import (
+	"database/sql"
+	"errors"
+	"time"
+)
+
+// columns of the users table in the order in which they are scanned
+const userColumns = "id, user_name, hashed_password, status, password_changed_at, email, email_verified_at"
+
+// SQLUserStore : UserStore which persists users with database/sql.
+// The queries are written for SQLite, the schema is created and
+// upgraded with the embedded migrations (see Migrate).
+type SQLUserStore struct {
+	db *sql.DB
+}
+
+// NewSQLUserStore : Create a new SQLUserStore and migrate the database
+// schema to the latest version
+func NewSQLUserStore(db *sql.DB) (store *SQLUserStore, error error) {
+	error = Migrate(db)
+	if error != nil {
+		return nil, error
+	}
+
+	store = new(SQLUserStore)
+	store.db = db
+
+	return store, nil
+}
+
+// GetUserByID : Get user by its ID
+func (s *SQLUserStore) GetUserByID(userID string) (user *User, error error) {
+	row := s.db.QueryRow("SELECT "+userColumns+" FROM users WHERE id = ?", userID)
+	user, error = scanUser(row)
+	if error == sql.ErrNoRows {
+		error = newError(ErrUserNotFound, "Error : No user found for ID : '"+userID+"' !")
+	}
+
+	return user, error
+}
+
+// GetUserByUserName : Get user by its user name
+func (s *SQLUserStore) GetUserByUserName(userName string) (user *User, error error) {
+	row := s.db.QueryRow("SELECT "+userColumns+" FROM users WHERE user_name = ?", userName)
+	user, error = scanUser(row)
+	if error == sql.ErrNoRows {
+		error = newError(ErrUserNotFound, "Error : No user found for UserName : '"+userName+"' !")
+	}
+
+	return user, error
+}
+
+// GetUserByEmail : Get user by its email address
+func (s *SQLUserStore) GetUserByEmail(email string) (user *User, error error) {
+	row := s.db.QueryRow("SELECT "+userColumns+" FROM users WHERE email = ? AND email <> ''", email)
+	user, error = scanUser(row)
+	if error == sql.ErrNoRows {
+		error = newError(ErrUserNotFound, "Error : No user found for email : '"+email+"' !")
+	}
+
+	return user, error
+}
+
+// CreateUser : Insert a new user. Checking the user name and email address
+// and inserting the user happens in one transaction and the unique indexes
+// on user_name and email guarantee that concurrent sign ups can not
+// register the same user name or email address.
+func (s *SQLUserStore) CreateUser(user *User) (error error) {
+	tx, error := s.db.Begin()
+	if error != nil {
+		return newError(ErrStore, "Error : Unable to start transaction : "+error.Error())
+	}
+	defer tx.Rollback()
+
+	userNameTaken, error := userNameExists(tx, user.UserName)
+	if error != nil {
+		return error
+	}
+	if userNameTaken {
+		return newError(ErrUsernameTaken, "Error : Username '"+user.UserName+"' already used. Please choose a different Username!")
+	}
+
+	emailTaken, error := emailExists(tx, user.Email)
+	if error != nil {
+		return error
+	}
+	if emailTaken {
+		return newError(ErrEmailTaken, "Error : Email '"+user.Email+"' already used. Please choose a different email address!")
+	}
+
+	_, error = tx.Exec("INSERT INTO users ("+userColumns+") VALUES (?, ?, ?, ?, ?, ?, ?)",
+		user.ID, user.UserName, user.HashedPassword, user.Status, timeToColumn(user.PasswordChangedAt),
+		user.Email, timeToColumn(user.EmailVerifiedAt))
+	if error == nil {
+		error = tx.Commit()
+	}
+
+	// roll back before looking up the conflicting user, so that
+	// the lookup does not wait for the failed transaction
+	if error != nil {
+		tx.Rollback()
+		return s.mapConstraintError(user, error)
+	}
+
+	return nil
+}
+
+// UpdateUser : Overwrite all columns of an already existing user
+func (s *SQLUserStore) UpdateUser(user *User) (error error) {
+	result, error := s.db.Exec("UPDATE users SET user_name = ?, hashed_password = ?, status = ?, password_changed_at = ?, email = ?, email_verified_at = ? WHERE id = ?",
+		user.UserName, user.HashedPassword, user.Status, timeToColumn(user.PasswordChangedAt),
+		user.Email, timeToColumn(user.EmailVerifiedAt), user.ID)
+	if error != nil {
+		return s.mapConstraintError(user, error)
+	}
+
+	return checkUserAffected(result, user.ID)
+}
+
+// CompareAndUpdateUser : Overwrite all columns of the user if all of them
+// still have the values of oldUser. The comparison is part of the update
+// statement, so that no concurrent update can get in between.
+func (s *SQLUserStore) CompareAndUpdateUser(oldUser *User, user *User) (error error) {
+	result, error := s.db.Exec("UPDATE users SET user_name = ?, hashed_password = ?, status = ?, password_changed_at = ?, email = ?, email_verified_at = ? "+
+		"WHERE id = ? AND user_name = ? AND hashed_password = ? AND status = ? AND password_changed_at = ? AND email = ? AND email_verified_at = ?",
+		user.UserName, user.HashedPassword, user.Status, timeToColumn(user.PasswordChangedAt),
+		user.Email, timeToColumn(user.EmailVerifiedAt), user.ID,
+		oldUser.UserName, oldUser.HashedPassword, oldUser.Status, timeToColumn(oldUser.PasswordChangedAt),
+		oldUser.Email, timeToColumn(oldUser.EmailVerifiedAt))
+	if error != nil {
+		return s.mapConstraintError(user, error)
+	}
+
+	// no row was hit if the user is unknown or was changed
+	error = checkUserAffected(result, user.ID)
+	if errors.Is(error, ErrUserNotFound) {
+		if _, lookUpError := s.GetUserByID(user.ID); lookUpError == nil {
+			error = newError(ErrConflict, "Error : User '"+user.ID+"' was changed concurrently!")
+		}
+	}
+
+	return error
+}
+
+// DeleteUser : Remove the user with the given ID
+func (s *SQLUserStore) DeleteUser(userID string) (error error) {
+	result, error := s.db.Exec("DELETE FROM users WHERE id = ?", userID)
+	if error != nil {
+		return newError(ErrStore, "Error : Unable to delete user '"+userID+"' : "+error.Error())
+	}
+
+	return checkUserAffected(result, userID)
+}
+
+// ListUsers : Get all users sorted by user name
+func (s *SQLUserStore) ListUsers() (users []*User, error error) {
+	rows, error := s.db.Query("SELECT " + userColumns + " FROM users ORDER BY user_name")
+	if error != nil {
+		return nil, newError(ErrStore, "Error : Unable to list users : "+error.Error())
+	}
+	defer rows.Close()
+
+	users = []*User{}
+	for rows.Next() {
+		user, scanError := scanUser(rows)
+		if scanError != nil {
+			return nil, scanError
+		}
+		users = append(users, user)
+	}
+
+	if error = rows.Err(); error != nil {
+		return nil, newError(ErrStore, "Error : Unable to list users : "+error.Error())
+	}
+
+	return users, nil
+}
+
+// rowScanner : common interface of sql.Row and sql.Rows
+type rowScanner interface {
+	Scan(dest ...interface{}) error
+}
+
+// scan a single user in the order of userColumns. sql.ErrNoRows is
+// returned unchanged, so that callers can build a fitting message.
+func scanUser(row rowScanner) (user *User, error error) {
+	user = new(User)
+	var passwordChangedAt, emailVerifiedAt int64
+	error = row.Scan(&user.ID, &user.UserName, &user.HashedPassword, &user.Status, &passwordChangedAt,
+		&user.Email, &emailVerifiedAt)
+	if error == sql.ErrNoRows {
+		return nil, error
+	} else if error != nil {
+		return nil, newError(ErrStore, "Error : Unable to read user : "+error.Error())
+	}
+	user.PasswordChangedAt = timeFromColumn(passwordChangedAt)
+	user.EmailVerifiedAt = timeFromColumn(emailVerifiedAt)
+
+	return user, nil
+}
+
+// times are stored as unix nanoseconds, the zero time as 0
+func timeToColumn(value time.Time) int64 {
+	if value.IsZero() {
+		return 0
+	}
+
+	return value.UnixNano()
+}
+
+func timeFromColumn(value int64) time.Time {
+	if value == 0 {
+		return time.Time{}
+	}
+
+	return time.Unix(0, value).UTC()
+}
+
+// check inside of a transaction if a user name is already used
+func userNameExists(tx *sql.Tx, userName string) (exists bool, error error) {
+	var count int
+	error = tx.QueryRow("SELECT COUNT(*) FROM users WHERE user_name = ?", userName).Scan(&count)
+	if error != nil {
+		return false, newError(ErrStore, "Error : Unable to check user name : "+error.Error())
+	}
+
+	return count > 0, nil
+}
+
+// check inside of a transaction if an email address is already used,
+// users without email address never conflict
+func emailExists(tx *sql.Tx, email string) (exists bool, error error) {
+	if email == "" {
+		return false, nil
+	}
+
+	var count int
+	error = tx.QueryRow("SELECT COUNT(*) FROM users WHERE email = ?", email).Scan(&count)
+	if error != nil {
+		return false, newError(ErrStore, "Error : Unable to check email : "+error.Error())
+	}
+
+	return count > 0, nil
+}
+
+// make sure that an update or delete statement really hit a user
+func checkUserAffected(result sql.Result, userID string) (error error) {
+	affectedRows, error := result.RowsAffected()
+	if error != nil {
+		return newError(ErrStore, "Error : Unable to check affected users : "+error.Error())
+	}
+	if affectedRows == 0 {
+		return newError(ErrUserNotFound, "Error : No user found for ID : '"+userID+"' !")
+	}
+
+	return nil
+}
+
+// translate a failed write into the user name or email error of the
+// UserStore interface if the unique index on user_name or email or the
+// triggers which keep email addresses and user names with an '@' apart
+// were violated
+func (s *SQLUserStore) mapConstraintError(user *User, writeError error) (error error) {
+	existingUser, _ := s.GetUserByUserName(user.UserName)
+	if existingUser != nil && existingUser.ID != user.ID {
+		return newError(ErrUsernameTaken, "Error : Username '"+user.UserName+"' already used. Please choose a different Username!")
+	}
+	if userName := emailLikeUserName(user.UserName); userName != "" && s.countOtherUsers(user.ID, "email = ? OR (user_name LIKE '%@%' AND lower(user_name) = ?)", userName, userName) > 0 {
+		return newError(ErrUsernameTaken, "Error : Username '"+user.UserName+"' already used. Please choose a different Username!")
+	}
+	existingUser, _ = s.GetUserByEmail(user.Email)
+	if (existingUser != nil && existingUser.ID != user.ID) ||
+		(user.Email != "" && s.countOtherUsers(user.ID, "user_name LIKE '%@%' AND lower(user_name) = ?", user.Email) > 0) {
+		return newError(ErrEmailTaken, "Error : Email '"+user.Email+"' already used. Please choose a different email address!")
+	}
+
+	return newError(ErrStore, "Error : Unable to write user '"+user.UserName+"' : "+writeError.Error())
+}
+
+// count the users other than the given one which match the condition,
+// failed queries count as no match
+func (s *SQLUserStore) countOtherUsers(userID string, condition string, arguments ...interface{}) (count int) {
+	arguments = append([]interface{}{userID}, arguments...)
+	s.db.QueryRow("SELECT COUNT(*) FROM users WHERE id <> ? AND ("+condition+")", arguments...).Scan(&count)
+
+	return count
+}
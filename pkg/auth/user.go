@@ -1,8 +1,39 @@
 package auth
 
+import "time"
+
 type User struct {
 	ID             string
 	UserName       string
 	HashedPassword string
-	AccessToken    string
+	Status         UserStatus
+	// PasswordChangedAt : time of the last password change, access tokens
+	// which were issued before are refused. Zero if it was never changed.
+	PasswordChangedAt time.Time
+	// Email : normalized email address of the user, see NormalizeEmail.
+	// Empty if the user has no email address.
+	Email string
+	// EmailVerifiedAt : time at which the user verified the email address
+	// with VerifyEmail. Zero as long as it is not verified.
+	EmailVerifiedAt time.Time
+}
+
+// IsEmailVerified : Check if the user has verified its email address
+func (u *User) IsEmailVerified() bool {
+	return u.Email != "" && !u.EmailVerifiedAt.IsZero()
+}
+
+// create a copy of the user, so that stored users can
+// not be modified from outside of a UserStore
+func (u *User) copy() *User {
+	userCopy := *u
+	return &userCopy
+}
+
+// check if both structs describe the same state of the user, times are
+// compared with Equal as their location may differ
+func (u *User) equals(other *User) bool {
+	return u.ID == other.ID && u.UserName == other.UserName && u.HashedPassword == other.HashedPassword &&
+		u.Status == other.Status && u.PasswordChangedAt.Equal(other.PasswordChangedAt) &&
+		u.Email == other.Email && u.EmailVerifiedAt.Equal(other.EmailVerifiedAt)
 }
@@ -0,0 +1,178 @@
+package auth
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"strconv"
+
+	"github.com/google/uuid"
+	"golang.org/x/crypto/bcrypt"
+)
+
+// formats of the password hashes of imported users
+const (
+	// HashFormatSHA256Salted : Hash is the hex encoded SHA-256 over the
+	// salt followed by the password
+	HashFormatSHA256Salted = "sha256-salted"
+	// HashFormatPBKDF2SHA1, HashFormatPBKDF2SHA256, HashFormatPBKDF2SHA512 :
+	// Hash is the hex encoded key derived by PBKDF2 from the password and the
+	// salt with the given number of iterations
+	HashFormatPBKDF2SHA1   = "pbkdf2-sha1"
+	HashFormatPBKDF2SHA256 = "pbkdf2-sha256"
+	HashFormatPBKDF2SHA512 = "pbkdf2-sha512"
+	// HashFormatPHC : Hash is already in a format of one of the built-in
+	// PasswordHashers, e.g. a bcrypt or an Argon2id hash
+	HashFormatPHC = "phc"
+)
+
+// ImportedUser : user with a pre-hashed password from another system.
+// The salt is used as it is, i.e. the bytes of the string are the salt.
+type ImportedUser struct {
+	// ID : ID of the user, a new ID is generated if it is empty
+	ID         string `json:"id"`
+	UserName   string `json:"user_name"`
+	Format     string `json:"format"`
+	Hash       string `json:"hash"`
+	Salt       string `json:"salt"`
+	Iterations int    `json:"iterations"`
+	// Status : status of the user, active if it is empty. E.g. accounts
+	// which were not activated in the other system yet are imported as
+	// StatusPending and can log in after ActivateUser.
+	Status UserStatus `json:"status"`
+}
+
+// ImportProblem : reason why a user was not imported
+type ImportProblem struct {
+	UserName string `json:"user_name"`
+	Reason   string `json:"reason"`
+}
+
+// ImportReport : result of ImportUsers
+type ImportReport struct {
+	// Imported : user names of the imported users
+	Imported []string `json:"imported"`
+	// Skipped : users which were invalid, e.g. because of a malformed hash
+	Skipped []ImportProblem `json:"skipped"`
+	// Conflicting : user names which were already used or whose ID was already used
+	Conflicting []string `json:"conflicting"`
+}
+
+// ImportUsers : Add users with pre-hashed passwords to the UserStore. The
+// rules for user names are not checked, as existing accounts should be
+// kept. The passwords are verified with their legacy hash on the first
+// login by AuthenticateByPassword and are rehashed with the current
+// PasswordHasher afterwards. Invalid and conflicting users are listed in
+// the report, an error is only returned if the UserStore fails. The users
+// which were imported up to then are listed in the report in that case.
+func ImportUsers(userStore UserStore, importedUsers []ImportedUser) (report *ImportReport, error error) {
+	report = &ImportReport{Imported: []string{}, Skipped: []ImportProblem{}, Conflicting: []string{}}
+
+	for _, importedUser := range importedUsers {
+		user, conversionError := importedUser.toUser()
+		if conversionError != nil {
+			report.Skipped = append(report.Skipped, ImportProblem{UserName: importedUser.UserName, Reason: conversionError.Error()})
+			continue
+		}
+
+		// the ID of the user must not be used yet
+		_, lookUpError := userStore.GetUserByID(user.ID)
+		if lookUpError == nil {
+			report.Conflicting = append(report.Conflicting, user.UserName)
+			continue
+		} else if !errors.Is(lookUpError, ErrUserNotFound) {
+			return report, lookUpError
+		}
+
+		error = userStore.CreateUser(user)
+		if errors.Is(error, ErrUsernameTaken) {
+			report.Conflicting = append(report.Conflicting, user.UserName)
+		} else if error != nil {
+			return report, error
+		} else {
+			report.Imported = append(report.Imported, user.UserName)
+		}
+	}
+
+	return report, nil
+}
+
+// convert the imported user into a user whose hash can be
+// verified by one of the built-in PasswordHashers
+func (u *ImportedUser) toUser() (user *User, error error) {
+	if u.UserName == "" {
+		return nil, newError(ErrInvalidInput, "Error : User name is missing!")
+	}
+
+	user = &User{ID: u.ID, UserName: u.UserName, Status: u.Status}
+	if _, knownStatus := userStatusTransitions[user.Status]; user.Status != "" && !knownStatus {
+		return nil, newError(ErrInvalidInput, "Error : Unknown user status '"+string(user.Status)+"'!")
+	} else if user.Status == "" {
+		user.Status = StatusActive
+	}
+	if user.ID == "" {
+		user.ID = uuid.New().String()
+	}
+
+	switch u.Format {
+	case HashFormatSHA256Salted:
+		digest, decodeError := hex.DecodeString(u.Hash)
+		if decodeError != nil || len(digest) != sha256.Size {
+			return nil, newError(ErrInvalidInput, "Error : Hash has to be a hex encoded SHA-256 digest!")
+		}
+		user.HashedPassword = encodeSaltedSHA256Hash([]byte(u.Salt), digest)
+	case HashFormatPBKDF2SHA1, HashFormatPBKDF2SHA256, HashFormatPBKDF2SHA512:
+		key, decodeError := hex.DecodeString(u.Hash)
+		if decodeError != nil || len(key) == 0 {
+			return nil, newError(ErrInvalidInput, "Error : Hash has to be a hex encoded PBKDF2 key!")
+		}
+		if u.Iterations <= 0 {
+			return nil, newError(ErrInvalidInput, "Error : Iterations have to be positive but are "+strconv.Itoa(u.Iterations)+"!")
+		}
+		user.HashedPassword = encodePBKDF2Hash(u.Format[len("pbkdf2-"):], u.Iterations, []byte(u.Salt), key)
+	case HashFormatPHC:
+		user.HashedPassword = u.Hash
+	default:
+		return nil, newError(ErrInvalidInput, "Error : Unknown hash format '"+u.Format+"'!")
+	}
+
+	error = checkPasswordHash(user.HashedPassword)
+	if error != nil {
+		return nil, error
+	}
+
+	return user, nil
+}
+
+// check that the hash can be verified by one of the built-in hashers
+// without computing it, which would be too slow for a bulk import
+func checkPasswordHash(hashedPassword string) error {
+	var parseError error
+	switch {
+	case NewBcryptHasher().Supports(hashedPassword):
+		_, parseError = bcrypt.Cost([]byte(hashedPassword))
+	case NewArgon2idHasher().Supports(hashedPassword):
+		_, _, _, parseError = parseArgon2idHash(hashedPassword)
+	case NewScryptHasher().Supports(hashedPassword):
+		_, _, _, parseError = parseScryptHash(hashedPassword)
+	case NewPBKDF2Hasher().Supports(hashedPassword):
+		_, _, _, parseError = parsePBKDF2Hash(hashedPassword)
+	case (&SaltedSHA256Hasher{}).Supports(hashedPassword):
+		_, parseError = (&SaltedSHA256Hasher{}).Verify("", hashedPassword)
+	default:
+		return newError(ErrInvalidInput, "Error : Hash of an unsupported algorithm!")
+	}
+
+	if parseError != nil {
+		return newError(ErrInvalidInput, "Error : Malformed password hash!")
+	}
+
+	return nil
+}
+
+// ImportUsers : Import users with pre-hashed passwords into the UserStore
+// of the AuthHandler, see the function ImportUsers
+func (a *AuthHandler) ImportUsers(importedUsers []ImportedUser) (report *ImportReport, error error) {
+	report, error = ImportUsers(a.userStore, importedUsers)
+	return report, a.logError(error)
+}
@@ -0,0 +1,146 @@
+package auth
+
+// UserStatus : state in the lifecycle of an account. Only active users
+// can log in, refresh their tokens and authenticate by JWT.
+type UserStatus string
+
+const (
+	// StatusActive : the user can log in. Users without status are active
+	// as well, e.g. users which were created before the status existed.
+	StatusActive UserStatus = "active"
+	// StatusPending : the account was created but is not activated yet.
+	// The AuthHandler never creates pending users itself, they are
+	// imported with this status by ImportUsers and activated with
+	// ActivateUser.
+	StatusPending UserStatus = "pending"
+	// StatusLocked : the account was locked by an administrator, e.g.
+	// because it may be compromised. This is independent of the temporary
+	// lockout by the RateLimiter.
+	StatusLocked UserStatus = "locked"
+	// StatusDisabled : the account was disabled by an administrator
+	StatusDisabled UserStatus = "disabled"
+	// StatusDeleted : the account was deleted but is kept, so that it can
+	// be restored and its user name is not taken by somebody else
+	StatusDeleted UserStatus = "deleted"
+)
+
+// allowed transitions between the states
+var userStatusTransitions = map[UserStatus][]UserStatus{
+	StatusActive:   {StatusLocked, StatusDisabled, StatusDeleted},
+	StatusPending:  {StatusActive, StatusDisabled, StatusDeleted},
+	StatusLocked:   {StatusActive, StatusDisabled, StatusDeleted},
+	StatusDisabled: {StatusActive, StatusDeleted},
+	StatusDeleted:  {StatusActive},
+}
+
+// IsActive : Check if the user is allowed to log in
+func (u *User) IsActive() bool {
+	return u.Status == "" || u.Status == StatusActive
+}
+
+// get the status of the user, users without status are active
+func (u *User) status() UserStatus {
+	if u.Status == "" {
+		return StatusActive
+	}
+
+	return u.Status
+}
+
+// CanTransitionTo : Check if an account in this state can be changed to the other state
+func (s UserStatus) CanTransitionTo(status UserStatus) bool {
+	for _, allowedStatus := range userStatusTransitions[s] {
+		if allowedStatus == status {
+			return true
+		}
+	}
+
+	return false
+}
+
+// AccountStatusError : returned if a user which is not active tries to
+// log in or to use a token. It matches ErrAccountInactive and the error
+// of its status, e.g. ErrAccountDisabled, in errors.Is. Locked users match
+// ErrAccountLocked like users which are locked out by the RateLimiter.
+type AccountStatusError struct {
+	Status UserStatus
+}
+
+func (e *AccountStatusError) Error() string {
+	return "Error : Account is " + string(e.Status) + "!"
+}
+
+// Is : an AccountStatusError matches ErrAccountInactive and the error of its status
+func (e *AccountStatusError) Is(target error) bool {
+	return target == ErrAccountInactive || target == accountStatusErrors[e.Status]
+}
+
+// errors of the states in which users can not log in
+var accountStatusErrors = map[UserStatus]error{
+	StatusPending:  ErrAccountPending,
+	StatusLocked:   ErrAccountLocked,
+	StatusDisabled: ErrAccountDisabled,
+	StatusDeleted:  ErrAccountDeleted,
+}
+
+// check that the user is active
+func (a *AuthHandler) checkUserStatus(user *User) (error error) {
+	if user.IsActive() {
+		return nil
+	}
+
+	return a.logError(&AccountStatusError{Status: user.status()})
+}
+
+// SetUserStatus : Change the status of the user, e.g. to disable the
+// account. Only the transitions of CanTransitionTo are allowed. When a
+// user stops being active, all of its tokens and sessions are revoked.
+func (a *AuthHandler) SetUserStatus(userID string, status UserStatus) (error error) {
+	if _, knownStatus := userStatusTransitions[status]; !knownStatus {
+		return a.newError(ErrInvalidInput, "Error : Unknown user status '"+string(status)+"'!")
+	}
+
+	oldUser, user, error := a.updateUser(userID, a.changeStatus(status))
+	if error != nil {
+		return error
+	}
+
+	if oldUser.IsActive() && !user.IsActive() {
+		return a.RevokeAllTokensForUser(userID)
+	}
+
+	return nil
+}
+
+// change the status of the user if the transition is allowed
+func (a *AuthHandler) changeStatus(status UserStatus) userUpdate {
+	return func(user *User) (error error) {
+		if !user.status().CanTransitionTo(status) {
+			return a.newError(ErrInvalidStatusTransition, "Error : User '"+user.UserName+"' can not be changed from "+string(user.status())+" to "+string(status)+"!")
+		}
+
+		user.Status = status
+		return nil
+	}
+}
+
+// ActivateUser : Activate a pending, locked, disabled or deleted user
+func (a *AuthHandler) ActivateUser(userID string) (error error) {
+	return a.SetUserStatus(userID, StatusActive)
+}
+
+// LockUser : Lock the account of the user until it is activated again
+func (a *AuthHandler) LockUser(userID string) (error error) {
+	return a.SetUserStatus(userID, StatusLocked)
+}
+
+// DisableUser : Disable the account of the user until it is activated again
+func (a *AuthHandler) DisableUser(userID string) (error error) {
+	return a.SetUserStatus(userID, StatusDisabled)
+}
+
+// SoftDeleteUser : Mark the user as deleted without removing it from the
+// UserStore, so that it can be restored by ActivateUser
+func (a *AuthHandler) SoftDeleteUser(userID string) (error error) {
+	return a.SetUserStatus(userID, StatusDeleted)
+}
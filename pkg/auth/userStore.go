@@ -0,0 +1,246 @@
+package auth
+
+import (
+	"sort"
+	"strings"
+	"sync"
+)
+
+// UserStore : persistence layer used by the AuthHandler to manage users.
+// Implementations have to make sure that user names and non-empty email
+// addresses are unique, that no email address equals the lower-cased
+// user name of another user, that user names with an '@' are unique
+// independent of their case and that stored users can not be modified by
+// changing the structs which were handed in or returned. Email addresses
+// are normalized by the AuthHandler and compared as they are. Errors have
+// to wrap ErrUserNotFound for unknown users, ErrUsernameTaken for
+// duplicate user names, ErrEmailTaken for duplicate email addresses,
+// ErrConflict for users which were changed concurrently by
+// CompareAndUpdateUser and ErrStore for failures of the underlying storage.
+type UserStore interface {
+	// GetUserByID : Get user by its ID
+	GetUserByID(userID string) (user *User, error error)
+	// GetUserByUserName : Get user by its user name
+	GetUserByUserName(userName string) (user *User, error error)
+	// GetUserByEmail : Get user by its email address
+	GetUserByEmail(email string) (user *User, error error)
+	// CreateUser : Add a new user to the store
+	CreateUser(user *User) (error error)
+	// UpdateUser : Overwrite an already existing user
+	UpdateUser(user *User) (error error)
+	// CompareAndUpdateUser : Atomically overwrite the user only if the
+	// stored user still equals oldUser, otherwise return ErrConflict. Used
+	// by the AuthHandler, so that concurrent changes of the same user do
+	// not overwrite each other.
+	CompareAndUpdateUser(oldUser *User, user *User) (error error)
+	// DeleteUser : Remove user with the given ID from the store
+	DeleteUser(userID string) (error error)
+	// ListUsers : Get all users of the store
+	ListUsers() (users []*User, error error)
+}
+
+// InMemoryUserStore : default UserStore which keeps all users in maps.
+// All users are lost when the process terminates. The store is safe
+// for concurrent use.
+type InMemoryUserStore struct {
+	mutex             sync.RWMutex
+	usersByID         map[string]*User
+	userIDsByUserName map[string]string
+	userIDsByEmail    map[string]string
+	// lower-cased user names which contain an '@', so that
+	// they can not be taken as email address by other users
+	userIDsByEmailLikeUserName map[string]string
+}
+
+// NewInMemoryUserStore : Create a new empty in-memory user store
+func NewInMemoryUserStore() *InMemoryUserStore {
+	store := new(InMemoryUserStore)
+	store.usersByID = make(map[string]*User)
+	store.userIDsByUserName = make(map[string]string)
+	store.userIDsByEmail = make(map[string]string)
+	store.userIDsByEmailLikeUserName = make(map[string]string)
+
+	return store
+}
+
+// GetUserByID : Get a copy of the user with the given ID
+func (s *InMemoryUserStore) GetUserByID(userID string) (user *User, error error) {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+
+	return s.getUserByID(userID)
+}
+
+// get user by ID without locking the store
+func (s *InMemoryUserStore) getUserByID(userID string) (user *User, error error) {
+	if storedUser, userFound := s.usersByID[userID]; userFound {
+		user = storedUser.copy()
+	} else {
+		error = newError(ErrUserNotFound, "Error : No user found for ID : '"+userID+"' !")
+	}
+
+	return user, error
+}
+
+// GetUserByUserName : Get a copy of the user with the given user name
+func (s *InMemoryUserStore) GetUserByUserName(userName string) (user *User, error error) {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+
+	if userID, userIDFound := s.userIDsByUserName[userName]; userIDFound {
+		user, error = s.getUserByID(userID)
+	} else {
+		error = newError(ErrUserNotFound, "Error : No user found for UserName : '"+userName+"' !")
+	}
+
+	return user, error
+}
+
+// GetUserByEmail : Get a copy of the user with the given email address
+func (s *InMemoryUserStore) GetUserByEmail(email string) (user *User, error error) {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+
+	if userID, userIDFound := s.userIDsByEmail[email]; userIDFound && email != "" {
+		user, error = s.getUserByID(userID)
+	} else {
+		error = newError(ErrUserNotFound, "Error : No user found for email : '"+email+"' !")
+	}
+
+	return user, error
+}
+
+// CreateUser : Store a copy of the given user if ID, user name and email address are not used yet
+func (s *InMemoryUserStore) CreateUser(user *User) (error error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if _, userIDFound := s.userIDsByUserName[user.UserName]; userIDFound || s.userNameUsedByOtherUser(user) {
+		error = newError(ErrUsernameTaken, "Error : Username '"+user.UserName+"' already used. Please choose a different Username!")
+	} else if s.emailUsedByOtherUser(user) {
+		error = newError(ErrEmailTaken, "Error : Email '"+user.Email+"' already used. Please choose a different email address!")
+	} else if _, userFound := s.usersByID[user.ID]; userFound {
+		error = newError(ErrInvalidInput, "Error : User with ID '"+user.ID+"' already exists!")
+	} else {
+		s.indexUser(user)
+	}
+
+	return error
+}
+
+// UpdateUser : Overwrite the stored user with a copy of the given user
+func (s *InMemoryUserStore) UpdateUser(user *User) (error error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	return s.updateUser(user)
+}
+
+// CompareAndUpdateUser : Overwrite the stored user with a copy of the
+// given user if the stored user was not changed since oldUser was read
+func (s *InMemoryUserStore) CompareAndUpdateUser(oldUser *User, user *User) (error error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if storedUser, userFound := s.usersByID[user.ID]; userFound && !storedUser.equals(oldUser) {
+		return newError(ErrConflict, "Error : User '"+user.ID+"' was changed concurrently!")
+	}
+
+	return s.updateUser(user)
+}
+
+// overwrite the stored user without locking the store
+func (s *InMemoryUserStore) updateUser(user *User) (error error) {
+	storedUser, userFound := s.usersByID[user.ID]
+
+	// the user has to exist and a changed user name or email address
+	// must not be used by any other user
+	if !userFound {
+		error = newError(ErrUserNotFound, "Error : No user found for ID : '"+user.ID+"' !")
+	} else if userID, userIDFound := s.userIDsByUserName[user.UserName]; (userIDFound && userID != user.ID) || s.userNameUsedByOtherUser(user) {
+		error = newError(ErrUsernameTaken, "Error : Username '"+user.UserName+"' already used. Please choose a different Username!")
+	} else if s.emailUsedByOtherUser(user) {
+		error = newError(ErrEmailTaken, "Error : Email '"+user.Email+"' already used. Please choose a different email address!")
+	} else {
+		s.unindexUser(storedUser)
+		s.indexUser(user)
+	}
+
+	return error
+}
+
+// DeleteUser : Remove the user with the given ID
+func (s *InMemoryUserStore) DeleteUser(userID string) (error error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if storedUser, userFound := s.usersByID[userID]; userFound {
+		s.unindexUser(storedUser)
+	} else {
+		error = newError(ErrUserNotFound, "Error : No user found for ID : '"+userID+"' !")
+	}
+
+	return error
+}
+
+// ListUsers : Get copies of all stored users sorted by user name
+func (s *InMemoryUserStore) ListUsers() (users []*User, error error) {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+
+	users = make([]*User, 0, len(s.usersByID))
+	for _, storedUser := range s.usersByID {
+		users = append(users, storedUser.copy())
+	}
+	sort.Slice(users, func(i, j int) bool { return users[i].UserName < users[j].UserName })
+
+	return users, error
+}
+
+// get the lower-cased user name if it contains an '@' and could be
+// confused with an email address, otherwise an empty string
+func emailLikeUserName(userName string) string {
+	if !strings.Contains(userName, "@") {
+		return ""
+	}
+
+	return strings.ToLower(userName)
+}
+
+// check without locking the store if the email address of the user
+// belongs to another user, either as email address or as user name.
+// Users without email address never conflict.
+func (s *InMemoryUserStore) emailUsedByOtherUser(user *User) bool {
+	emailUserID, emailFound := s.userIDsByEmail[user.Email]
+	userNameUserID, userNameFound := s.userIDsByEmailLikeUserName[user.Email]
+	return user.Email != "" && ((emailFound && emailUserID != user.ID) || (userNameFound && userNameUserID != user.ID))
+}
+
+// check without locking the store if a user name with an '@' is used by
+// another user as email address or in another spelling as user name
+func (s *InMemoryUserStore) userNameUsedByOtherUser(user *User) bool {
+	userName := emailLikeUserName(user.UserName)
+	emailUserID, emailFound := s.userIDsByEmail[userName]
+	userNameUserID, userNameFound := s.userIDsByEmailLikeUserName[userName]
+	return userName != "" && ((emailFound && emailUserID != user.ID) || (userNameFound && userNameUserID != user.ID))
+}
+
+// store a copy of the user and add it to the indexes without locking the store
+func (s *InMemoryUserStore) indexUser(user *User) {
+	s.usersByID[user.ID] = user.copy()
+	s.userIDsByUserName[user.UserName] = user.ID
+	if user.Email != "" {
+		s.userIDsByEmail[user.Email] = user.ID
+	}
+	if userName := emailLikeUserName(user.UserName); userName != "" {
+		s.userIDsByEmailLikeUserName[userName] = user.ID
+	}
+}
+
+// remove the stored user and its index entries without locking the store
+func (s *InMemoryUserStore) unindexUser(storedUser *User) {
+	delete(s.usersByID, storedUser.ID)
+	delete(s.userIDsByUserName, storedUser.UserName)
+	delete(s.userIDsByEmail, storedUser.Email)
+	delete(s.userIDsByEmailLikeUserName, emailLikeUserName(storedUser.UserName))
+}
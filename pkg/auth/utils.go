@@ -1,14 +1,21 @@
 package auth
 
 import (
-	"errors"
-
 	log "github.com/sirupsen/logrus"
 )
 
-// create and return a new error object and while
-// doing this writing the error message to the log
-func LogNewError(errorMessage string) error {
-	log.Error(errorMessage)
-	return errors.New(errorMessage)
+// create and return a new error object of the given kind
+// (one of the sentinel errors) with a human readable message
+func newError(kind error, errorMessage string) error {
+	return &Error{Kind: kind, Message: errorMessage}
+}
+
+// write the error message to the log if a logger is set and
+// the error is not nil. The error is returned unchanged, so that
+// this can be used directly in return statements.
+func logError(logger log.FieldLogger, error error) error {
+	if logger != nil && error != nil {
+		logger.Error(error.Error())
+	}
+	return error
 }
@@ -1,44 +1,164 @@
 package main
 
-//"testing"
+import (
+	"errors"
+	"testing"
 
-//"github.com/mezorian/go-auth-example/pkg/auth"
-//"github.com/stretchr/testify/assert"
-
-// TODO : from here --> continue with User and password rule
+	"github.com/mezorian/go-auth-example/pkg/auth"
+	"github.com/stretchr/testify/assert"
+)
 
 // Test Event for correct attribute setting
 
-// func TestCheckingOfUserRule(t *testing.T) {
-//
-// 	regex := "^[a-zA-Z]{5,20}$"
-// 	errorUser := "Error : Username does not comply to rules. Please make sure it fits to the following regular expression : " + regex
-//
-// 	var authH auth.AuthHandler
-// 	authH.AddUserRule(regex)
-//
-// 	testCaseValues := []struct {
-// 		username string
-// 		success  bool
-// 		error    string
-// 	}{
-// 		{"peter", true, ""},
-// 		{"Peter", true, ""},
-// 		{"PETER", true, ""},
-// 		{"PeTer", true, ""},
-// 		{"special$$", false, errorUser},
-// 		{"bl ank", false, errorUser},
-// 		{"toooooooooooomanycharacters", false, errorUser},
-// 		{"numbers2", false, errorUser},
-// 	}
-//
-// 	for _, testCaseValue := range testCaseValues {
-// 		success, error := authH.CheckUserNameRule(testCaseValue.username)
-// 		assert.Equal(t, success, testCaseValue.success)
-// 		assert.Equal(t, error, testCaseValue.error)
-// 	}
-//
-// }
-
-//regexPassword := "^(?=.*[0-9])(?=.*[a-z])(?=.*[A-Z])(?=.*[*.!@$%^&(){}[]:;<>,.?/~_+-=|\\]).{8,32}$"
-// TODO : to here --> continue with User and password rule
+func TestCheckingOfUserRule(t *testing.T) {
+
+	regex := "^[a-zA-Z]{5,20}$"
+	errorUser := "Error : Username does not comply to rules. Please make sure it fits to the following regular expression : " + regex
+
+	authH := newAuthHandler(t)
+	error := authH.AddUserRule(regex)
+	assert.Equal(t, nil, error)
+
+	testCaseValues := []struct {
+		username string
+		success  bool
+		error    string
+	}{
+		{"peter", true, ""},
+		{"Peter", true, ""},
+		{"PETER", true, ""},
+		{"PeTer", true, ""},
+		{"special$$", false, errorUser},
+		{"bl ank", false, errorUser},
+		{"toooooooooooomanycharacters", false, errorUser},
+		{"numbers2", false, errorUser},
+	}
+
+	for _, testCaseValue := range testCaseValues {
+		error := authH.CheckUserNameRule(testCaseValue.username)
+		if testCaseValue.success {
+			assert.Equal(t, nil, error)
+		} else {
+			assert.Equal(t, testCaseValue.error, error.Error())
+		}
+	}
+
+}
+
+func TestAddingInvalidRegexRuleReturnsTypedError(t *testing.T) {
+	// lookahead assertions are not supported by Go's regular expressions
+	regexPassword := "^(?=.*[0-9])(?=.*[a-z])(?=.*[A-Z])(?=.*[*.!@$%^&(){}[]:;<>,.?/~_+-=|\\]).{8,32}$"
+
+	testCaseValues := []struct {
+		regex      string
+		nameOfRule string
+		addRule    func(authH *auth.AuthHandler, regex string) error
+	}{
+		{regexPassword, "Password", (*auth.AuthHandler).AddPasswordRule},
+		{"[a-z", "Username", (*auth.AuthHandler).AddUserRule},
+		{"(peter", "Username", (*auth.AuthHandler).AddUserRule},
+	}
+
+	for _, testCaseValue := range testCaseValues {
+		authH := newAuthHandler(t)
+		error := testCaseValue.addRule(authH, testCaseValue.regex)
+
+		var invalidRuleError *auth.InvalidRuleError
+		assert.Equal(t, true, errors.As(error, &invalidRuleError))
+		assert.Equal(t, testCaseValue.nameOfRule, invalidRuleError.NameOfRule)
+		assert.Equal(t, testCaseValue.regex, invalidRuleError.Regex)
+	}
+}
+
+func TestCheckingOfPasswordRuleWithoutLookahead(t *testing.T) {
+	// equivalent of ^(?=.*[0-9])(?=.*[a-z])(?=.*[A-Z])(?=.*[special]).{8,32}$
+	rule, error := auth.NewAllOfRegexRule("Password",
+		"[0-9]",
+		"[a-z]",
+		"[A-Z]",
+		`[*.!@$%^&(){}\[\]:;<>,?/~_+\-=|\\]`,
+		"^.{8,32}$",
+	)
+	assert.Equal(t, nil, error)
+
+	authH := newAuthHandler(t)
+	authH.AddCustomPasswordRule(rule)
+
+	testCaseValues := []struct {
+		password string
+		success  bool
+	}{
+		{"superS3cret!", true},
+		{"Pa55word_", true},
+		{"nouppercase1!", false},
+		{"NOLOWERCASE1!", false},
+		{"NoDigits!!", false},
+		{"NoSpecial123", false},
+		{"Sh0rt!", false},
+		{"Toooooooooooooooooooooooooooolong1!", false},
+	}
+
+	for _, testCaseValue := range testCaseValues {
+		error := authH.CheckPasswordRule(testCaseValue.password)
+		assert.Equal(t, testCaseValue.success, error == nil, testCaseValue.password)
+	}
+}
+
+func TestCheckingOfCustomRuleFunction(t *testing.T) {
+	authH := newAuthHandler(t)
+	authH.AddCustomUserRule(auth.NewRuleFunc("Username", "Please do not use 'admin' as username.", func(value string) bool {
+		return value != "admin"
+	}))
+
+	error := authH.CheckUserNameRule("peter")
+	assert.Equal(t, nil, error)
+
+	error = authH.CheckUserNameRule("admin")
+	assert.Equal(t, "Error : Username does not comply to rules. Please do not use 'admin' as username.", error.Error())
+}
+
+func TestSignUpChecksUserAndPasswordRules(t *testing.T) {
+	userRegex := "^[a-z]{5,20}$"
+	passwordRegex := "^.{8,}$"
+	errorUser := "Error : Username does not comply to rules. Please make sure it fits to the following regular expression : " + userRegex
+	errorPassword := "Error : Password does not comply to rules. Please make sure it fits to the following regular expression : " + passwordRegex
+
+	testCaseValues := []struct {
+		username string
+		password string
+		success  bool
+		error    string
+	}{
+		{"peter", "supersecret", true, ""},
+		{"Peter", "supersecret", false, errorUser},
+		{"anna", "supersecret", false, errorUser},
+		{"peter", "secret", false, errorPassword},
+	}
+
+	for _, testCaseValue := range testCaseValues {
+		authH := newAuthHandler(t)
+		authH.AddUserRule(userRegex)
+		authH.AddPasswordRule(passwordRegex)
+
+		user, error := authH.SignUp(testCaseValue.username, testCaseValue.password)
+		if testCaseValue.success {
+			assert.Equal(t, nil, error)
+			assert.Equal(t, testCaseValue.username, user.UserName)
+		} else {
+			assert.Equal(t, testCaseValue.error, error.Error())
+		}
+	}
+}
+
+func TestCheckRegexRuleWithAdHocRegex(t *testing.T) {
+	authH := newAuthHandler(t)
+
+	error := authH.CheckRegexRule("^[0-9]+$", "12345", "Pin")
+	assert.Equal(t, nil, error)
+
+	error = authH.CheckRegexRule("^[0-9]+$", "12a45", "Pin")
+	assert.Equal(t, "Error : Pin does not comply to rules. Please make sure it fits to the following regular expression : ^[0-9]+$", error.Error())
+
+	error = authH.CheckRegexRule("[0-9", "12345", "Pin")
+	assert.Equal(t, "Error : Cannot apply invalid regex rule for Pin : error parsing regexp: missing closing ]: `[0-9`", error.Error())
+}
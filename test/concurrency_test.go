@@ -0,0 +1,199 @@
+package main
+
+import (
+	"strconv"
+	"sync"
+	"testing"
+
+	"github.com/mezorian/go-auth-example/pkg/auth"
+	"github.com/sirupsen/logrus/hooks/test"
+	"github.com/stretchr/testify/assert"
+)
+
+// These tests are meant to be run with the race detector enabled
+// (go test -race ./...) to find unsynchronized access to shared state.
+
+func TestConcurrentSignUpLogInAndAuthenticationOfDifferentUsers(t *testing.T) {
+	numberOfUsers := 20
+	authH := newAuthHandler(t)
+
+	var waitGroup sync.WaitGroup
+	for i := 0; i < numberOfUsers; i++ {
+		waitGroup.Add(1)
+		go func(userName string) {
+			defer waitGroup.Done()
+
+			_, error := authH.SignUp(userName, "password")
+			assert.Equal(t, nil, error)
+
+			result, error := authH.LogIn(userName, "password")
+			assert.Equal(t, nil, error)
+
+			_, error = authH.AuthenticateByJWT(result.AccessToken)
+			assert.Equal(t, nil, error)
+		}("user" + strconv.Itoa(i))
+	}
+	waitGroup.Wait()
+
+	users, error := authH.GetUserStore().ListUsers()
+	assert.Equal(t, nil, error)
+	assert.Equal(t, numberOfUsers, len(users))
+}
+
+func TestConcurrentSignUpOfSameUserNameOnlySucceedsOnce(t *testing.T) {
+	testCaseValues := []string{"admin", "peter", "anna"}
+
+	for _, userName := range testCaseValues {
+		authH := newAuthHandler(t)
+
+		var waitGroup sync.WaitGroup
+		var successfulSignUps int
+		var successfulSignUpsMutex sync.Mutex
+		for i := 0; i < 10; i++ {
+			waitGroup.Add(1)
+			go func() {
+				defer waitGroup.Done()
+				_, error := authH.SignUp(userName, "password")
+				if error == nil {
+					successfulSignUpsMutex.Lock()
+					successfulSignUps++
+					successfulSignUpsMutex.Unlock()
+				} else {
+					assert.ErrorIs(t, error, auth.ErrUsernameTaken)
+				}
+			}()
+		}
+		waitGroup.Wait()
+
+		assert.Equal(t, 1, successfulSignUps)
+	}
+}
+
+func TestConcurrentLogInsAndAuthenticationsOfSameUser(t *testing.T) {
+	authH := newAuthHandler(t)
+	_, error := authH.SignUp("peter", "supersecret")
+	assert.Equal(t, nil, error)
+
+	// log in and authenticate from many goroutines at once
+	var waitGroup sync.WaitGroup
+	accessTokens := make(chan string, 10)
+	for i := 0; i < 10; i++ {
+		waitGroup.Add(1)
+		go func() {
+			defer waitGroup.Done()
+			result, error := authH.LogIn("peter", "supersecret")
+			assert.Equal(t, nil, error)
+			_, error = authH.AuthenticateByJWT(result.AccessToken)
+			assert.Equal(t, nil, error)
+			accessTokens <- result.AccessToken
+		}()
+	}
+	waitGroup.Wait()
+	close(accessTokens)
+
+	// every login created its own session whose token stays valid
+	user, _ := authH.GetUserByUserName("peter")
+	sessions, error := authH.ListSessions(user.ID)
+	assert.Equal(t, nil, error)
+	assert.Equal(t, 10, len(sessions))
+	for accessToken := range accessTokens {
+		_, error = authH.AuthenticateByJWT(accessToken)
+		assert.Equal(t, nil, error)
+	}
+}
+
+// hasher which blocks the first verification until it is released and
+// always asks for a new hash, so that every login rehashes the password
+type blockingHasher struct {
+	*auth.Argon2idHasher
+	verifying chan struct{}
+	release   chan struct{}
+	once      sync.Once
+}
+
+func newBlockingHasher() *blockingHasher {
+	return &blockingHasher{Argon2idHasher: newFastArgon2idHasher(), verifying: make(chan struct{}), release: make(chan struct{})}
+}
+
+func (h *blockingHasher) Verify(password string, hashedPassword string) (bool, error) {
+	h.once.Do(func() {
+		close(h.verifying)
+		<-h.release
+	})
+	return h.Argon2idHasher.Verify(password, hashedPassword)
+}
+
+func (h *blockingHasher) NeedsRehash(hashedPassword string) bool {
+	return true
+}
+
+func TestLoggerCanBeChangedWhileInUse(t *testing.T) {
+	authH := newAuthHandler(t)
+	logger, _ := test.NewNullLogger()
+
+	var waitGroup sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		waitGroup.Add(2)
+		go func() {
+			defer waitGroup.Done()
+			authH.SetLogger(logger)
+		}()
+		go func() {
+			defer waitGroup.Done()
+			_, error := authH.LogIn("unknown", "password")
+			assert.ErrorIs(t, error, auth.ErrInvalidCredentials)
+		}()
+	}
+	waitGroup.Wait()
+}
+
+func TestRehashDuringLogInDoesNotOverwriteConcurrentChanges(t *testing.T) {
+	testCaseValues := []struct {
+		name   string
+		change func(authH *auth.AuthHandler, userID string) error
+		check  func(t *testing.T, authH *auth.AuthHandler, user *auth.User)
+	}{
+		{
+			"disable user",
+			func(authH *auth.AuthHandler, userID string) error { return authH.DisableUser(userID) },
+			func(t *testing.T, authH *auth.AuthHandler, user *auth.User) {
+				assert.Equal(t, auth.StatusDisabled, user.Status)
+				_, error := authH.LogIn("peter", "supersecret")
+				assert.ErrorIs(t, error, auth.ErrAccountDisabled)
+			},
+		},
+		{
+			"set password",
+			func(authH *auth.AuthHandler, userID string) error { return authH.SetPassword(userID, "evenmoresecret") },
+			func(t *testing.T, authH *auth.AuthHandler, user *auth.User) {
+				_, error := authH.LogIn("peter", "supersecret")
+				assert.ErrorIs(t, error, auth.ErrInvalidCredentials)
+				_, error = authH.LogIn("peter", "evenmoresecret")
+				assert.Equal(t, nil, error)
+			},
+		},
+	}
+
+	for _, testCaseValue := range testCaseValues {
+		hasher := newBlockingHasher()
+		authH := newAuthHandler(t, auth.WithPasswordHasher(hasher))
+		user, error := authH.SignUp("peter", "supersecret")
+		assert.Equal(t, nil, error)
+
+		// change the user while the login verifies the password
+		loggedIn := make(chan struct{})
+		go func() {
+			defer close(loggedIn)
+			authH.LogIn("peter", "supersecret")
+		}()
+		<-hasher.verifying
+		assert.Equal(t, nil, testCaseValue.change(authH, user.ID), testCaseValue.name)
+		close(hasher.release)
+		<-loggedIn
+
+		// the rehash of the login did not undo the change
+		user, error = authH.GetUserStore().GetUserByID(user.ID)
+		assert.Equal(t, nil, error)
+		testCaseValue.check(t, authH, user)
+	}
+}
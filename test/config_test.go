@@ -0,0 +1,227 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/mezorian/go-auth-example/pkg/auth"
+	"github.com/stretchr/testify/assert"
+	"golang.org/x/crypto/bcrypt"
+)
+
+// the same configuration in every supported format
+var testConfigs = map[string]string{
+	"yaml": `
+# tokens
+access_token_lifetime: 10m
+refresh_token_lifetime: 24h
+issuer: https://auth.example.com
+audience: [api.example.com]
+clock_skew: 30s
+validation_mode: stateful
+secret_env: TEST_CONFIG_SECRET
+user_name_rules:
+  - '^[a-z]{3,16}$'
+password_policy:
+  min_length: 10
+  character_classes: [lowercase, digit]
+  no_username: true
+bcrypt_cost: 4
+enumeration_protection: true
+`,
+	"json": `{
+	"access_token_lifetime": "10m",
+	"refresh_token_lifetime": "24h",
+	"issuer": "https://auth.example.com",
+	"audience": ["api.example.com"],
+	"clock_skew": "30s",
+	"validation_mode": "stateful",
+	"secret_env": "TEST_CONFIG_SECRET",
+	"user_name_rules": ["^[a-z]{3,16}$"],
+	"password_policy": {"min_length": 10, "character_classes": ["lowercase", "digit"], "no_username": true},
+	"bcrypt_cost": 4,
+	"enumeration_protection": true
+}`,
+	"toml": `
+# tokens
+access_token_lifetime = "10m"
+refresh_token_lifetime = "24h"
+issuer = "https://auth.example.com" # iss claim
+audience = ["api.example.com"]
+clock_skew = "30s"
+validation_mode = "stateful"
+secret_env = "TEST_CONFIG_SECRET"
+user_name_rules = [
+	'^[a-z]{3,16}$',
+]
+bcrypt_cost = 4
+enumeration_protection = true
+
+[password_policy]
+min_length = 10
+character_classes = ["lowercase", "digit"]
+no_username = true
+`,
+}
+
+func TestConfigCanBeParsedFromEveryFormat(t *testing.T) {
+	var configs []*auth.Config
+	for format, data := range testConfigs {
+		config, error := auth.ParseConfig([]byte(data), format)
+		assert.Equal(t, nil, error, format)
+		configs = append(configs, config)
+	}
+
+	assert.Equal(t, auth.Duration(10*time.Minute), configs[0].AccessTokenLifetime)
+	assert.Equal(t, "https://auth.example.com", configs[0].Issuer)
+	assert.Equal(t, []string{"^[a-z]{3,16}$"}, configs[0].UserNameRules)
+	assert.Equal(t, 10, configs[0].PasswordPolicy.MinLength)
+	for _, config := range configs[1:] {
+		assert.Equal(t, configs[0], config)
+	}
+}
+
+func TestConfigSupportsFullTOMLSyntax(t *testing.T) {
+	config, error := auth.ParseConfig([]byte(`
+issuer = """
+https://auth.example.com"""
+audience = [
+	"api.example.com", # trailing comma and comment
+]
+password_policy = { min_length = 12, character_classes = ["digit"] }
+rate_limiter.lockout_threshold = 5
+`), "toml")
+	assert.Equal(t, nil, error)
+	assert.Equal(t, "https://auth.example.com", config.Issuer)
+	assert.Equal(t, []string{"api.example.com"}, config.Audience)
+	assert.Equal(t, 12, config.PasswordPolicy.MinLength)
+	assert.Equal(t, 5, config.RateLimiter.LockoutThreshold)
+}
+
+func TestAuthHandlerCanBeCreatedFromConfigFile(t *testing.T) {
+	t.Setenv("TEST_CONFIG_SECRET", string(testSecret))
+	clock := newFakeClock()
+	path := filepath.Join(t.TempDir(), "auth.toml")
+	os.WriteFile(path, []byte(testConfigs["toml"]), 0600)
+
+	config, error := auth.LoadConfig(path)
+	assert.Equal(t, nil, error)
+	authH, error := auth.NewAuthHandler(auth.WithConfig(config), auth.WithClock(clock))
+	assert.Equal(t, nil, error)
+
+	// rules and password policy of the configuration are checked
+	_, error = authH.SignUp("Peter", "password123")
+	assert.ErrorIs(t, error, auth.ErrPolicyViolation)
+	_, error = authH.SignUp("peter", "password")
+	assert.ErrorIs(t, error, auth.ErrPolicyViolation)
+	user, error := authH.SignUp("peter", "password123")
+	assert.Equal(t, nil, error)
+	cost, _ := bcrypt.Cost([]byte(user.HashedPassword))
+	assert.Equal(t, 4, cost)
+	assert.ErrorIs(t, authH.CheckIfUserNameIsFree("peter"), auth.ErrEnumerationProtected)
+
+	// and the token settings are used
+	result, error := authH.LogIn("peter", "password123")
+	assert.Equal(t, nil, error)
+	assert.Equal(t, clock.Now().Add(10*time.Minute), result.AccessTokenExpiresAt)
+	assert.Equal(t, clock.Now().Add(24*time.Hour), result.RefreshTokenExpiresAt)
+	principal, error := authH.AuthenticateByJWT(result.AccessToken)
+	assert.Equal(t, nil, error)
+	assert.Equal(t, "https://auth.example.com", principal.Claims.Issuer)
+	assert.Equal(t, "api.example.com", principal.Claims.Audience[0])
+}
+
+func TestInvalidConfigIsRejected(t *testing.T) {
+	testCaseValues := []struct {
+		description string
+		format      string
+		data        string
+	}{
+		{"unknown key", "yaml", "acces_token_lifetime: 10m"},
+		{"invalid duration", "json", `{"access_token_lifetime": "10 minutes"}`},
+		{"duration without unit", "json", `{"access_token_lifetime": 600}`},
+		{"invalid yaml", "yaml", "issuer: [unclosed"},
+		{"invalid toml", "toml", "issuer https://auth.example.com"},
+		{"duplicate toml key", "toml", "issuer = 'a'\nissuer = 'b'"},
+		{"unclosed toml array", "toml", "audience = ['a',"},
+		{"unsupported toml syntax", "toml", "[[audience]]\nname = 'a'"},
+		{"malformed yaml", "yaml", "0: [:!00 \xef"},
+		{"unknown format", "ini", "issuer=a"},
+	}
+
+	for _, testCaseValue := range testCaseValues {
+		config, error := auth.ParseConfig([]byte(testCaseValue.data), testCaseValue.format)
+		assert.Equal(t, (*auth.Config)(nil), config, testCaseValue.description)
+		assert.ErrorIs(t, error, auth.ErrInvalidConfig, testCaseValue.description)
+	}
+
+	_, error := auth.LoadConfig(filepath.Join(t.TempDir(), "missing.yaml"))
+	assert.ErrorIs(t, error, auth.ErrInvalidConfig)
+
+	// settings which can only be checked when they are applied
+	invalidConfigs := []*auth.Config{
+		{SecretEnv: "TEST_CONFIG_SECRET", SecretFile: "/run/secrets/jwt"},
+		{ValidationMode: "sometimes"},
+		{PasswordPolicy: &auth.PasswordPolicyConfig{CharacterClasses: []string{"emoji"}}},
+		{SigningKey: &auth.KeyConfig{Algorithm: "ES256"}},
+	}
+	for _, invalidConfig := range invalidConfigs {
+		_, error := auth.NewAuthHandler(auth.WithSecret(testSecret), auth.WithConfig(invalidConfig))
+		assert.ErrorIs(t, error, auth.ErrInvalidConfig)
+	}
+}
+
+func TestEveryTunableCanBeSetByOption(t *testing.T) {
+	clock := newFakeClock()
+	userStore := auth.NewInMemoryUserStore()
+	sessionStore := auth.NewInMemorySessionStore()
+	signingKey := newSigningKey(t, "ES256")
+
+	authH, error := auth.NewAuthHandler(
+		auth.WithLogger(nil),
+		auth.WithUserStore(userStore),
+		auth.WithSessionStore(sessionStore),
+		auth.WithRefreshTokenStore(auth.NewInMemoryRefreshTokenStore()),
+		auth.WithRevocationStore(auth.NewInMemoryRevocationStore()),
+		auth.WithSigningKey(signingKey),
+		auth.WithAccessTokenLifetime(time.Minute),
+		auth.WithRefreshTokenLifetime(time.Hour),
+		auth.WithIssuer("https://auth.example.com"),
+		auth.WithAudience("api.example.com"),
+		auth.WithClockSkew(time.Second),
+		auth.WithClock(clock),
+		auth.WithUserRule("^[a-z]+$"),
+		auth.WithPasswordRule("^.{8,}$"),
+		auth.WithPasswordPolicy(auth.NewPasswordPolicy(auth.NoUserNameRule{})),
+		auth.WithBcryptCost(bcrypt.MinCost),
+	)
+	assert.Equal(t, nil, error)
+	assert.Equal(t, userStore, authH.GetUserStore())
+	assert.Equal(t, sessionStore, authH.GetSessionStore())
+	assert.Equal(t, []string{signingKey.Thumbprint()}, authH.GetKeyRing().KeyIDs())
+
+	_, error = authH.SignUp("peter", "short")
+	assert.ErrorIs(t, error, auth.ErrPolicyViolation)
+	result := logInNewUserWithResult(t, authH, "peter")
+	assert.Equal(t, clock.Now().Add(time.Minute), result.AccessTokenExpiresAt)
+	assert.Equal(t, clock.Now().Add(time.Hour), result.Session.ExpiresAt)
+
+	// invalid values are reported when the AuthHandler is created
+	invalidOptions := []auth.Option{
+		auth.WithAccessTokenLifetime(0),
+		auth.WithRefreshTokenLifetime(-time.Hour),
+		auth.WithClockSkew(-time.Second),
+		auth.WithBcryptCost(bcrypt.MaxCost + 1),
+		auth.WithUserRule("[unclosed"),
+		auth.WithUserStore(nil),
+		auth.WithClock(nil),
+		auth.WithAllowedAlgorithms("none"),
+	}
+	for _, invalidOption := range invalidOptions {
+		authH, error := auth.NewAuthHandler(auth.WithSecret(testSecret), invalidOption)
+		assert.Equal(t, (*auth.AuthHandler)(nil), authH)
+		assert.NotEqual(t, nil, error)
+	}
+}
@@ -0,0 +1,349 @@
+package main
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/mezorian/go-auth-example/pkg/auth"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNormalizeEmail(t *testing.T) {
+	testCaseValues := []struct {
+		email      string
+		normalized string
+		kind       error
+	}{
+		{"peter@example.com", "peter@example.com", nil},
+		{"  Peter.Pan@Example.COM ", "peter.pan@example.com", nil},
+		{"peter+news@example.com", "peter+news@example.com", nil},
+		{"", "", auth.ErrInvalidEmail},
+		{"peter", "", auth.ErrInvalidEmail},
+		{"peter@", "", auth.ErrInvalidEmail},
+		{"Peter <peter@example.com>", "", auth.ErrInvalidEmail},
+		{"peter@example.com, anna@example.com", "", auth.ErrInvalidEmail},
+	}
+
+	for _, testCaseValue := range testCaseValues {
+		normalized, error := auth.NormalizeEmail(testCaseValue.email)
+		assert.Equal(t, testCaseValue.normalized, normalized, testCaseValue.email)
+		if testCaseValue.kind == nil {
+			assert.Equal(t, nil, error, testCaseValue.email)
+		} else {
+			assert.ErrorIs(t, error, testCaseValue.kind, testCaseValue.email)
+			assert.ErrorIs(t, error, auth.ErrInvalidInput, testCaseValue.email)
+		}
+	}
+}
+
+func TestSignUpWithEmailSendsVerificationToken(t *testing.T) {
+	clock := newFakeClock()
+	notifier := &capturingNotifier{}
+	authH := newAuthHandler(t, auth.WithClock(clock), auth.WithNotifier(notifier))
+
+	user, error := authH.SignUpWithEmail("peter", " Peter@Example.com", "supersecret")
+	assert.Equal(t, nil, error)
+	assert.Equal(t, "peter@example.com", user.Email)
+	assert.Equal(t, false, user.IsEmailVerified())
+
+	notification := notifier.last(t)
+	assert.Equal(t, auth.NotificationEmailVerification, notification.Kind)
+	assert.Equal(t, user.ID, notification.UserID)
+	assert.Equal(t, "peter@example.com", notification.Email)
+	assert.Equal(t, clock.Now().Add(auth.DefaultEmailVerificationTokenLifetime), notification.ExpiresAt)
+
+	clock.Advance(time.Minute)
+	verifiedUser, error := authH.VerifyEmail(notification.Token)
+	assert.Equal(t, nil, error)
+	assert.Equal(t, user.ID, verifiedUser.ID)
+	assert.Equal(t, clock.Now(), verifiedUser.EmailVerifiedAt)
+
+	storedUser, _ := authH.GetUserByEmail("PETER@example.com")
+	assert.Equal(t, true, storedUser.IsEmailVerified())
+
+	// the token is refused after the address was verified
+	_, error = authH.VerifyEmail(notification.Token)
+	assert.ErrorIs(t, error, auth.ErrTokenUsed)
+}
+
+func TestSignUpWithEmailChecksEmail(t *testing.T) {
+	authH := newAuthHandler(t)
+	authH.SignUpWithEmail("peter", "peter@example.com", "supersecret")
+
+	testCaseValues := []struct {
+		userName string
+		email    string
+		kind     error
+	}{
+		{"anna", "PETER@example.com", auth.ErrEmailTaken},
+		{"anna", "not an address", auth.ErrInvalidEmail},
+		{"anna", "", auth.ErrInvalidEmail},
+		{"peter", "anna@example.com", auth.ErrUsernameTaken},
+	}
+
+	for _, testCaseValue := range testCaseValues {
+		user, error := authH.SignUpWithEmail(testCaseValue.userName, testCaseValue.email, "supersecret")
+		assert.Equal(t, (*auth.User)(nil), user)
+		assert.ErrorIs(t, error, testCaseValue.kind, testCaseValue)
+	}
+	assert.Equal(t, 409, auth.HTTPStatus(auth.ErrEmailTaken))
+
+	// with enumeration protection a taken address is not revealed
+	authH.SetEnumerationProtection(true)
+	user, error := authH.SignUpWithEmail("anna", "peter@example.com", "supersecret")
+	assert.Equal(t, (*auth.User)(nil), user)
+	assert.Equal(t, nil, error)
+	_, error = authH.GetUserByUserName("anna")
+	assert.ErrorIs(t, error, auth.ErrUserNotFound)
+}
+
+func TestVerifyEmailRefusesInvalidTokens(t *testing.T) {
+	clock := newFakeClock()
+	notifier := &capturingNotifier{}
+	authH := newAuthHandler(t, auth.WithClock(clock), auth.WithNotifier(notifier), auth.WithEmailVerificationTokenLifetime(time.Hour))
+	user, _ := authH.SignUpWithEmail("peter", "peter@example.com", "supersecret")
+	token := notifier.last(t).Token
+
+	// access tokens can not verify addresses and verification tokens
+	// can not be used as access tokens
+	result, _ := authH.LogIn("peter", "supersecret")
+	_, error := authH.VerifyEmail(result.AccessToken)
+	assert.ErrorIs(t, error, auth.ErrInvalidToken)
+	_, error = authH.AuthenticateByJWT(token)
+	assert.ErrorIs(t, error, auth.ErrInvalidToken)
+	_, error = authH.VerifyEmail("not-a-token")
+	assert.ErrorIs(t, error, auth.ErrInvalidToken)
+
+	// tokens for a replaced address are refused
+	assert.Equal(t, nil, authH.SetEmail(user.ID, "pan@example.com"))
+	newToken := notifier.last(t).Token
+	assert.Equal(t, "pan@example.com", notifier.last(t).Email)
+	_, error = authH.VerifyEmail(token)
+	assert.ErrorIs(t, error, auth.ErrInvalidToken)
+
+	// expired tokens are refused
+	clock.Advance(time.Hour + time.Second)
+	_, error = authH.VerifyEmail(newToken)
+	assert.ErrorIs(t, error, auth.ErrTokenExpired)
+
+	assert.Equal(t, nil, authH.SendEmailVerification(user.ID))
+	_, error = authH.VerifyEmail(notifier.last(t).Token)
+	assert.Equal(t, nil, error)
+	assert.ErrorIs(t, authH.SendEmailVerification(user.ID), auth.ErrInvalidInput)
+}
+
+func TestEmailVerificationSurvivesKeyRotation(t *testing.T) {
+	notifier := &capturingNotifier{}
+	keyRing := auth.NewKeyRing()
+	keyRing.Rotate("key-1", newSigningKey(t, "ES256"), 0)
+	authH := newAuthHandler(t, auth.WithKeyRing(keyRing), auth.WithNotifier(notifier))
+	authH.SignUpWithEmail("peter", "peter@example.com", "supersecret")
+
+	// verification tokens do not depend on the keys which sign access tokens
+	keyRing.Rotate("key-2", newSigningKey(t, "ES256"), 0)
+	user, error := authH.VerifyEmail(notifier.last(t).Token)
+	assert.Equal(t, nil, error)
+	assert.Equal(t, true, user.IsEmailVerified())
+}
+
+func TestEmailVerificationCanBeRequiredForLogIn(t *testing.T) {
+	notifier := &capturingNotifier{}
+	authH := newAuthHandler(t, auth.WithNotifier(notifier), auth.WithEmailVerificationRequired())
+	authH.SignUp("anna", "supersecret")
+	authH.SignUpWithEmail("peter", "peter@example.com", "supersecret")
+	token := notifier.last(t).Token
+
+	// the missing verification is only revealed to users who know the password
+	_, error := authH.LogIn("peter", "wrongpassword")
+	assert.ErrorIs(t, error, auth.ErrInvalidCredentials)
+	_, error = authH.LogIn("peter", "supersecret")
+	assert.ErrorIs(t, error, auth.ErrEmailNotVerified)
+	assert.Equal(t, 403, auth.HTTPStatus(error))
+	_, error = authH.LogIn("anna", "supersecret")
+	assert.ErrorIs(t, error, auth.ErrEmailNotVerified)
+
+	_, error = authH.VerifyEmail(token)
+	assert.Equal(t, nil, error)
+	_, error = authH.LogIn("peter", "supersecret")
+	assert.Equal(t, nil, error)
+}
+
+func TestLogInWithEmail(t *testing.T) {
+	notifier := &capturingNotifier{}
+	authH := newAuthHandler(t, auth.WithNotifier(notifier))
+	user, _ := authH.SignUpWithEmail("peter", "peter@example.com", "supersecret")
+
+	for _, identifier := range []string{"peter", "peter@example.com", " Peter@Example.com"} {
+		result, error := authH.LogIn(identifier, "supersecret")
+		assert.Equal(t, nil, error, identifier)
+		assert.Equal(t, user.ID, result.User.ID, identifier)
+	}
+
+	_, error := authH.LogIn("peter@example.com", "wrongpassword")
+	assert.ErrorIs(t, error, auth.ErrInvalidCredentials)
+	_, error = authH.LogIn("anna@example.com", "supersecret")
+	assert.ErrorIs(t, error, auth.ErrInvalidCredentials)
+
+	// a password reset can be requested by email address as well
+	assert.Equal(t, nil, authH.RequestPasswordReset("PETER@example.com"))
+	assert.Equal(t, auth.NotificationPasswordReset, notifier.last(t).Kind)
+	assert.Equal(t, "peter@example.com", notifier.last(t).Email)
+	assert.Equal(t, nil, authH.ResetPassword(notifier.last(t).Token, "evenmoresecret"))
+	_, error = authH.LogIn("peter@example.com", "evenmoresecret")
+	assert.Equal(t, nil, error)
+}
+
+func TestLogInsWithSpellingsOfAnEmailShareRateLimit(t *testing.T) {
+	rateLimiter := auth.NewRateLimiter()
+	rateLimiter.LockoutThreshold = 2
+	rateLimiter.BackoffBase = 0
+	authH := newAuthHandler(t, auth.WithRateLimiter(rateLimiter))
+	authH.SignUpWithEmail("peter", "peter@example.com", "supersecret")
+
+	authH.LogIn("peter@example.com", "wrongpassword")
+	authH.LogIn("PETER@example.com", "wrongpassword")
+	_, error := authH.LogIn("Peter@Example.com", "supersecret")
+	assert.ErrorIs(t, error, auth.ErrAccountLocked)
+
+	assert.Equal(t, nil, authH.UnlockAccount("peter@EXAMPLE.com"))
+	_, error = authH.LogIn("peter@example.com", "supersecret")
+	assert.Equal(t, nil, error)
+}
+
+func TestLogInsByUserNameAndEmailShareRateLimit(t *testing.T) {
+	rateLimiter := auth.NewRateLimiter()
+	rateLimiter.LockoutThreshold = 2
+	rateLimiter.BackoffBase = 0
+	authH := newAuthHandler(t, auth.WithRateLimiter(rateLimiter))
+	authH.SignUpWithEmail("peter", "peter@example.com", "supersecret")
+
+	// the email address gives no further guesses after the user name is locked
+	authH.LogIn("peter", "wrongpassword")
+	authH.LogIn("peter", "wrongpassword")
+	_, error := authH.LogIn("peter@example.com", "supersecret")
+	assert.ErrorIs(t, error, auth.ErrAccountLocked)
+
+	// unlocking by user name unlocks the logins by email address as well
+	assert.Equal(t, nil, authH.UnlockAccount("peter"))
+	_, error = authH.LogIn("peter@example.com", "supersecret")
+	assert.Equal(t, nil, error)
+}
+
+func TestInMemoryUserStoreKeepsEmailsUnique(t *testing.T) {
+	store := auth.NewInMemoryUserStore()
+	assert.Equal(t, nil, store.CreateUser(&auth.User{ID: "1", UserName: "peter", Email: "peter@example.com"}))
+	assert.Equal(t, nil, store.CreateUser(&auth.User{ID: "2", UserName: "anna"}))
+
+	// users without email address never conflict
+	assert.Equal(t, nil, store.CreateUser(&auth.User{ID: "3", UserName: "paul"}))
+	_, error := store.GetUserByEmail("")
+	assert.ErrorIs(t, error, auth.ErrUserNotFound)
+
+	error = store.CreateUser(&auth.User{ID: "4", UserName: "mary", Email: "peter@example.com"})
+	assert.ErrorIs(t, error, auth.ErrEmailTaken)
+	error = store.UpdateUser(&auth.User{ID: "2", UserName: "anna", Email: "peter@example.com"})
+	assert.ErrorIs(t, error, auth.ErrEmailTaken)
+
+	// a changed address is free again
+	assert.Equal(t, nil, store.UpdateUser(&auth.User{ID: "1", UserName: "peter", Email: "pan@example.com"}))
+	assert.Equal(t, nil, store.UpdateUser(&auth.User{ID: "2", UserName: "anna", Email: "peter@example.com"}))
+	user, error := store.GetUserByEmail("peter@example.com")
+	assert.Equal(t, nil, error)
+	assert.Equal(t, "2", user.ID)
+
+	// a deleted address is free again
+	assert.Equal(t, nil, store.DeleteUser("2"))
+	_, error = store.GetUserByEmail("peter@example.com")
+	assert.ErrorIs(t, error, auth.ErrUserNotFound)
+}
+
+func TestSQLUserStoreKeepsEmailsUnique(t *testing.T) {
+	db := openTestDatabase(t, filepath.Join(t.TempDir(), "users.db"))
+	store, error := auth.NewSQLUserStore(db)
+	assert.Equal(t, nil, error)
+
+	verifiedAt := time.Date(2021, 3, 1, 12, 0, 0, 5, time.UTC)
+	assert.Equal(t, nil, store.CreateUser(&auth.User{ID: "1", UserName: "peter", HashedPassword: "hash", Email: "peter@example.com", EmailVerifiedAt: verifiedAt}))
+	assert.Equal(t, nil, store.CreateUser(&auth.User{ID: "2", UserName: "anna", HashedPassword: "hash"}))
+	assert.Equal(t, nil, store.CreateUser(&auth.User{ID: "3", UserName: "paul", HashedPassword: "hash"}))
+
+	user, error := store.GetUserByEmail("peter@example.com")
+	assert.Equal(t, nil, error)
+	assert.Equal(t, "1", user.ID)
+	assert.Equal(t, true, verifiedAt.Equal(user.EmailVerifiedAt))
+	_, error = store.GetUserByEmail("")
+	assert.ErrorIs(t, error, auth.ErrUserNotFound)
+
+	error = store.CreateUser(&auth.User{ID: "4", UserName: "mary", HashedPassword: "hash", Email: "peter@example.com"})
+	assert.ErrorIs(t, error, auth.ErrEmailTaken)
+	error = store.UpdateUser(&auth.User{ID: "2", UserName: "anna", HashedPassword: "hash", Email: "peter@example.com"})
+	assert.ErrorIs(t, error, auth.ErrEmailTaken)
+
+	// the AuthHandler can log in by email with the SQL store
+	authH := newAuthHandler(t, auth.WithUserStore(store))
+	_, error = authH.SignUpWithEmail("mary", "Mary@example.com", "supersecret")
+	assert.Equal(t, nil, error)
+	_, error = authH.LogIn("mary@example.com", "supersecret")
+	assert.Equal(t, nil, error)
+}
+
+func TestUserNamesCanNotShadowEmails(t *testing.T) {
+	notifier := &capturingNotifier{}
+	authH := newAuthHandler(t, auth.WithNotifier(notifier))
+	alice, _ := authH.SignUpWithEmail("alice", "alice@example.com", "supersecret")
+
+	// user names with '@' are refused
+	_, error := authH.SignUp("alice@example.com", "attackerpw")
+	assert.ErrorIs(t, error, auth.ErrInvalidInput)
+	_, error = authH.CreateNewUser("alice@example.com", "attackerpw")
+	assert.ErrorIs(t, error, auth.ErrInvalidInput)
+
+	// an imported user name which looks like the address does not shadow it
+	authH.GetUserStore().CreateUser(&auth.User{ID: "imported", UserName: "alice@example.com", HashedPassword: alice.HashedPassword})
+	result, error := authH.LogIn("alice@example.com", "supersecret")
+	assert.Equal(t, nil, error)
+	assert.Equal(t, alice.ID, result.User.ID)
+	assert.Equal(t, nil, authH.RequestPasswordReset("alice@example.com"))
+	assert.Equal(t, alice.ID, notifier.last(t).UserID)
+
+	// it can still log in as long as no user has the address
+	authH.GetUserStore().CreateUser(&auth.User{ID: "legacy", UserName: "bob@example.com", HashedPassword: alice.HashedPassword})
+	result, error = authH.LogIn("bob@example.com", "supersecret")
+	assert.Equal(t, nil, error)
+	assert.Equal(t, "legacy", result.User.ID)
+}
+
+func TestEmailsCanNotTakeUserNamesOfOtherUsers(t *testing.T) {
+	sqlStore, error := auth.NewSQLUserStore(openTestDatabase(t, filepath.Join(t.TempDir(), "users.db")))
+	assert.Equal(t, nil, error)
+	stores := map[string]auth.UserStore{"in-memory": auth.NewInMemoryUserStore(), "sql": sqlStore}
+
+	for storeName, store := range stores {
+		authH := newAuthHandler(t, auth.WithUserStore(store))
+		authH.ImportUsers([]auth.ImportedUser{{UserName: "bob@example.com", Format: auth.HashFormatSHA256Salted, Hash: legacySHA256Hash("salt", "supersecret"), Salt: "salt"}})
+
+		// the user name of the imported user can not be claimed in any spelling
+		for _, email := range []string{"bob@example.com", "BOB@example.com"} {
+			_, error = authH.SignUpWithEmail("mallory", email, "supersecret")
+			assert.ErrorIs(t, error, auth.ErrEmailTaken, storeName+" "+email)
+		}
+		mallory, _ := authH.SignUp("mallory", "supersecret")
+		assert.ErrorIs(t, authH.SetEmail(mallory.ID, "Bob@Example.com"), auth.ErrEmailTaken, storeName)
+		_, error = authH.LogIn("bob@example.com", "supersecret")
+		assert.Equal(t, nil, error, storeName)
+
+		// user names with an '@' can not be imported if they are used as
+		// email address or in another spelling as user name
+		authH.SetEmail(mallory.ID, "mallory@example.com")
+		report, _ := authH.ImportUsers([]auth.ImportedUser{
+			{UserName: "Mallory@example.com", Format: auth.HashFormatSHA256Salted, Hash: legacySHA256Hash("salt", "pw"), Salt: "salt"},
+			{UserName: "BOB@example.com", Format: auth.HashFormatSHA256Salted, Hash: legacySHA256Hash("salt", "pw"), Salt: "salt"},
+		})
+		assert.Equal(t, []string{"Mallory@example.com", "BOB@example.com"}, report.Conflicting, storeName)
+
+		// a user may have its own user name as email address
+		bob, _ := authH.GetUserByUserName("bob@example.com")
+		assert.Equal(t, nil, authH.SetEmail(bob.ID, "bob@example.com"), storeName)
+	}
+}
@@ -0,0 +1,138 @@
+package main
+
+import (
+	"net/http"
+	"sync"
+	"testing"
+
+	"github.com/mezorian/go-auth-example/pkg/auth"
+	"github.com/stretchr/testify/assert"
+	"golang.org/x/crypto/bcrypt"
+)
+
+// password hasher which counts how often it hashes and verifies
+// passwords and remembers the hashes it verified
+type countingHasher struct {
+	*auth.BcryptHasher
+	mutex          sync.Mutex
+	hashes         int
+	verifiedHashes []string
+}
+
+func newCountingHasher(cost int) *countingHasher {
+	return &countingHasher{BcryptHasher: &auth.BcryptHasher{Cost: cost}}
+}
+
+func (h *countingHasher) Hash(password string) (string, error) {
+	h.mutex.Lock()
+	h.hashes++
+	h.mutex.Unlock()
+
+	return h.BcryptHasher.Hash(password)
+}
+
+func (h *countingHasher) Verify(password string, hashedPassword string) (bool, error) {
+	h.mutex.Lock()
+	h.verifiedHashes = append(h.verifiedHashes, hashedPassword)
+	h.mutex.Unlock()
+
+	return h.BcryptHasher.Verify(password, hashedPassword)
+}
+
+// forget the counted calls
+func (h *countingHasher) reset() {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+
+	h.hashes = 0
+	h.verifiedHashes = nil
+}
+
+func TestLogInOfUnknownUserVerifiesDummyHash(t *testing.T) {
+	hasher := newCountingHasher(bcrypt.MinCost)
+	authH := newAuthHandler(t, auth.WithPasswordHasher(hasher))
+	peter, _ := authH.SignUp("peter", "supersecret")
+
+	// a wrong password verifies the hash of the user
+	hasher.reset()
+	_, error := authH.LogIn("peter", "wrongpassword")
+	assert.ErrorIs(t, error, auth.ErrInvalidCredentials)
+	assert.Equal(t, []string{peter.HashedPassword}, hasher.verifiedHashes)
+
+	// an unknown user verifies a dummy hash of the same hasher and
+	// parameters instead, which is only created once
+	for i := 0; i < 3; i++ {
+		hasher.reset()
+		_, error = authH.LogIn("anna", "supersecret")
+		assert.ErrorIs(t, error, auth.ErrInvalidCredentials)
+		assert.Equal(t, 1, len(hasher.verifiedHashes))
+		assert.NotEqual(t, peter.HashedPassword, hasher.verifiedHashes[0])
+		assert.Equal(t, true, hasher.Supports(hasher.verifiedHashes[0]))
+		assert.Equal(t, false, hasher.NeedsRehash(hasher.verifiedHashes[0]))
+		if i > 0 {
+			assert.Equal(t, 0, hasher.hashes)
+		}
+	}
+}
+
+func TestLogInOfUnknownUserUsesTheCurrentHasher(t *testing.T) {
+	oldHasher := newCountingHasher(bcrypt.MinCost)
+	authH := newAuthHandler(t, auth.WithPasswordHasher(oldHasher))
+	authH.LogIn("anna", "supersecret")
+
+	// the dummy hash of the old cost must not be used anymore
+	newHasher := newCountingHasher(bcrypt.MinCost + 1)
+	authH.SetPasswordHasher(newHasher)
+	oldHasher.reset()
+	authH.LogIn("anna", "supersecret")
+	assert.Equal(t, 0, len(oldHasher.verifiedHashes))
+	assert.Equal(t, 1, len(newHasher.verifiedHashes))
+	assert.Equal(t, false, newHasher.NeedsRehash(newHasher.verifiedHashes[0]))
+}
+
+func TestSignUpWithTakenUserNameHashesLikeNewUserWithEnumerationProtection(t *testing.T) {
+	hasher := newCountingHasher(bcrypt.MinCost)
+	authH := newAuthHandler(t, auth.WithPasswordHasher(hasher), auth.WithEnumerationProtection())
+	authH.SignUp("peter", "supersecret")
+
+	for _, userName := range []string{"peter", "anna"} {
+		hasher.reset()
+		_, error := authH.SignUp(userName, "supersecret")
+		assert.Equal(t, nil, error)
+		assert.Equal(t, 1, hasher.hashes, userName)
+	}
+}
+
+func TestEnumerationProtectionHidesTakenUserNames(t *testing.T) {
+	authH := newAuthHandler(t, auth.WithEnumerationProtection())
+	authH.SignUp("peter", "supersecret")
+
+	// user names can not be checked directly
+	error := authH.CheckIfUserNameIsFree("peter")
+	assert.ErrorIs(t, error, auth.ErrEnumerationProtected)
+	assert.ErrorIs(t, error, auth.ErrNotAllowed)
+	assert.Equal(t, http.StatusForbidden, auth.HTTPStatus(error))
+	assert.ErrorIs(t, authH.CheckIfUserNameIsFree("anna"), auth.ErrEnumerationProtected)
+
+	// a taken user name is answered without user and error
+	user, error := authH.SignUp("peter", "otherpassword")
+	assert.Equal(t, nil, error)
+	assert.Equal(t, (*auth.User)(nil), user)
+	_, error = authH.LogIn("peter", "supersecret")
+	assert.Equal(t, nil, error)
+	_, error = authH.LogIn("peter", "otherpassword")
+	assert.ErrorIs(t, error, auth.ErrInvalidCredentials)
+
+	// other errors and new user names are reported as before
+	_, error = authH.SignUp("anna", "")
+	assert.ErrorIs(t, error, auth.ErrInvalidInput)
+	user, error = authH.SignUp("anna", "supersecret")
+	assert.Equal(t, nil, error)
+	assert.Equal(t, "anna", user.UserName)
+
+	// without the protection the user name is reported as taken
+	authH.SetEnumerationProtection(false)
+	assert.ErrorIs(t, authH.CheckIfUserNameIsFree("peter"), auth.ErrUsernameTaken)
+	_, error = authH.SignUp("peter", "otherpassword")
+	assert.ErrorIs(t, error, auth.ErrUsernameTaken)
+}
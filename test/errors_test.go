@@ -0,0 +1,82 @@
+package main
+
+import (
+	"errors"
+	"net/http"
+	"testing"
+
+	"github.com/mezorian/go-auth-example/pkg/auth"
+	"github.com/sirupsen/logrus/hooks/test"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestErrorsCanBeCheckedWithErrorsIs(t *testing.T) {
+	authH := newAuthHandler(t)
+	authH.SetPasswordPolicy(auth.NewPasswordPolicy(auth.MinLengthRule{Length: 4}))
+	authH.SignUp("peter", "supersecret")
+
+	_, signUpWithEmptyInput := authH.SignUp("", "")
+	_, signUpWithTakenUserName := authH.SignUp("peter", "supersecret")
+	_, signUpWithShortPassword := authH.SignUp("anna", "abc")
+	addInvalidRule := authH.AddUserRule("[a-z")
+	_, logInWithWrongPassword := authH.LogIn("peter", "wrong password")
+	_, logInOfUnknownUser := authH.LogIn("anna", "supersecret")
+	_, authenticateWithInvalidToken := authH.AuthenticateByJWT("RandomStringWhichIsNoRealJWT")
+	_, getUnknownUser := authH.GetUserByUserName("anna")
+
+	testCaseValues := []struct {
+		error      error
+		kind       error
+		httpStatus int
+	}{
+		{signUpWithEmptyInput, auth.ErrInvalidInput, http.StatusBadRequest},
+		{signUpWithTakenUserName, auth.ErrUsernameTaken, http.StatusConflict},
+		{signUpWithShortPassword, auth.ErrPolicyViolation, http.StatusBadRequest},
+		{addInvalidRule, auth.ErrInvalidRule, http.StatusInternalServerError},
+		{logInWithWrongPassword, auth.ErrInvalidCredentials, http.StatusUnauthorized},
+		{logInOfUnknownUser, auth.ErrInvalidCredentials, http.StatusUnauthorized},
+		{authenticateWithInvalidToken, auth.ErrInvalidToken, http.StatusUnauthorized},
+		{getUnknownUser, auth.ErrUserNotFound, http.StatusNotFound},
+	}
+
+	for _, testCaseValue := range testCaseValues {
+		assert.ErrorIs(t, testCaseValue.error, testCaseValue.kind)
+		assert.Equal(t, testCaseValue.httpStatus, auth.HTTPStatus(testCaseValue.error))
+	}
+	assert.Equal(t, http.StatusOK, auth.HTTPStatus(nil))
+}
+
+func TestErrorsKeepTheirDetails(t *testing.T) {
+	authH := newAuthHandler(t)
+	authH.SetPasswordPolicy(auth.NewPasswordPolicy(auth.MinLengthRule{Length: 8}, auth.NoUserNameRule{}))
+
+	// password policy violations can be listed
+	_, error := authH.SignUp("peter", "peter")
+	var policyError *auth.PasswordPolicyError
+	assert.Equal(t, true, errors.As(error, &policyError))
+	assert.Equal(t, 2, len(policyError.Violations))
+
+	// the message of other errors is still human readable
+	var authError *auth.Error
+	_, error = authH.SignUp("", "")
+	assert.Equal(t, true, errors.As(error, &authError))
+	assert.Equal(t, "Error : Please enter a valid username and password!", authError.Message)
+}
+
+func TestLoggingOfErrorsIsOptional(t *testing.T) {
+	logger, hook := test.NewNullLogger()
+
+	// errors are written to the configured logger
+	authH := newAuthHandler(t)
+	authH.SetLogger(logger)
+	authH.SignUp("", "")
+	assert.Equal(t, 1, len(hook.AllEntries()))
+	assert.Equal(t, "Error : Please enter a valid username and password!", hook.LastEntry().Message)
+
+	// nothing is logged after logging was disabled
+	hook.Reset()
+	authH.SetLogger(nil)
+	_, error := authH.SignUp("", "")
+	assert.ErrorIs(t, error, auth.ErrInvalidInput)
+	assert.Equal(t, 0, len(hook.AllEntries()))
+}
@@ -0,0 +1,138 @@
+package main
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/mezorian/go-auth-example/pkg/auth"
+	"github.com/stretchr/testify/assert"
+)
+
+// build a token from raw header and claims and sign it with the
+// given function, so that also invalid headers can be crafted
+func craftToken(header map[string]interface{}, claims map[string]interface{}, sign func(signingInput string) string) string {
+	headerJSON, _ := json.Marshal(header)
+	claimsJSON, _ := json.Marshal(claims)
+	signingInput := base64.RawURLEncoding.EncodeToString(headerJSON) + "." + base64.RawURLEncoding.EncodeToString(claimsJSON)
+
+	return signingInput + "." + sign(signingInput)
+}
+
+// sign with HMAC-SHA256 and the secret of the test handlers
+func signWithSecret(signingInput string) string {
+	mac := hmac.New(sha256.New, testSecret)
+	mac.Write([]byte(signingInput))
+	return base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
+
+func TestAuthWithJWTRejectsMaliciousSigningAlgorithms(t *testing.T) {
+	authH := newAuthHandler(t)
+	logInNewUser(t, authH, "peter")
+	user, _ := authH.GetUserByUserName("peter")
+
+	// claims which would be valid for the user
+	claims := map[string]interface{}{
+		"UserName": "peter",
+		"sub":      user.ID,
+		"jti":      "SomeTokenID",
+		"iat":      time.Now().Unix(),
+		"exp":      time.Now().Add(time.Hour).Unix(),
+	}
+	noSignature := func(signingInput string) string { return "" }
+
+	hs512Token, _ := jwt.NewWithClaims(jwt.SigningMethodHS512, jwt.MapClaims(claims)).SignedString(testSecret)
+	hs384Token, _ := jwt.NewWithClaims(jwt.SigningMethodHS384, jwt.MapClaims(claims)).SignedString(testSecret)
+	noneToken, _ := jwt.NewWithClaims(jwt.SigningMethodNone, jwt.MapClaims(claims)).SignedString(jwt.UnsafeAllowNoneSignatureType)
+
+	testCaseValues := []struct {
+		description string
+		token       string
+	}{
+		{"alg none", noneToken},
+		{"alg None", craftToken(map[string]interface{}{"alg": "None", "typ": "JWT"}, claims, noSignature)},
+		{"alg NONE", craftToken(map[string]interface{}{"alg": "NONE", "typ": "JWT"}, claims, noSignature)},
+		{"alg none with HMAC signature", craftToken(map[string]interface{}{"alg": "none", "typ": "JWT"}, claims, signWithSecret)},
+		{"missing alg", craftToken(map[string]interface{}{"typ": "JWT"}, claims, signWithSecret)},
+		{"unknown alg", craftToken(map[string]interface{}{"alg": "XYZ", "typ": "JWT"}, claims, signWithSecret)},
+		{"HS512 with the right secret", hs512Token},
+		{"HS384 with the right secret", hs384Token},
+		{"RS256 header with HMAC signature", craftToken(map[string]interface{}{"alg": "RS256", "typ": "JWT"}, claims, signWithSecret)},
+		{"ES256 header with HMAC signature", craftToken(map[string]interface{}{"alg": "ES256", "typ": "JWT"}, claims, signWithSecret)},
+		{"EdDSA header with HMAC signature", craftToken(map[string]interface{}{"alg": "EdDSA", "typ": "JWT"}, claims, signWithSecret)},
+	}
+
+	for _, testCaseValue := range testCaseValues {
+		principal, error := authH.AuthenticateByJWT(testCaseValue.token)
+		assert.Equal(t, (*auth.Principal)(nil), principal, testCaseValue.description)
+		assert.ErrorIs(t, error, auth.ErrUnexpectedSigningMethod, testCaseValue.description)
+		assert.ErrorIs(t, error, auth.ErrInvalidToken, testCaseValue.description)
+	}
+}
+
+func TestAuthWithJWTRejectsTamperedTokensWithAllowedAlgorithm(t *testing.T) {
+	authH := newAuthHandler(t)
+	logInNewUser(t, authH, "peter")
+	user, _ := authH.GetUserByUserName("peter")
+
+	claims := map[string]interface{}{
+		"UserName": "peter",
+		"sub":      user.ID,
+		"jti":      "SomeTokenID",
+		"iat":      time.Now().Unix(),
+		"exp":      time.Now().Add(time.Hour).Unix(),
+	}
+	header := map[string]interface{}{"alg": "HS256", "typ": "JWT"}
+
+	testCaseValues := []struct {
+		description string
+		token       string
+	}{
+		{"stripped signature", craftToken(header, claims, func(signingInput string) string { return "" })},
+		{"wrong secret", craftToken(header, claims, func(signingInput string) string {
+			mac := hmac.New(sha256.New, []byte("wrong secret"))
+			mac.Write([]byte(signingInput))
+			return base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+		})},
+		{"valid signature but not issued by the auth handler", craftToken(header, claims, signWithSecret)},
+	}
+
+	for _, testCaseValue := range testCaseValues {
+		_, error := authH.AuthenticateByJWT(testCaseValue.token)
+		assert.ErrorIs(t, error, auth.ErrInvalidToken, testCaseValue.description)
+		assert.NotErrorIs(t, error, auth.ErrUnexpectedSigningMethod, testCaseValue.description)
+	}
+}
+
+func TestAllowedAlgorithmsCanBeConfigured(t *testing.T) {
+	authH := newAuthHandler(t)
+
+	// none and unknown algorithms can never be allowed
+	testCaseValues := [][]string{
+		{"none"},
+		{"HS256", "None"},
+		{"XYZ"},
+		{},
+	}
+	for _, testCaseValue := range testCaseValues {
+		error := authH.SetAllowedAlgorithms(testCaseValue...)
+		assert.ErrorIs(t, error, auth.ErrInvalidInput)
+	}
+
+	// tokens of the auth handler are valid if HS256 is allowed
+	accessToken := logInNewUser(t, authH, "peter")
+	error := authH.SetAllowedAlgorithms("HS256", "HS512")
+	assert.Equal(t, nil, error)
+	_, error = authH.AuthenticateByJWT(accessToken)
+	assert.Equal(t, nil, error)
+
+	// and rejected if HS256 is not allowed anymore
+	error = authH.SetAllowedAlgorithms("HS512")
+	assert.Equal(t, nil, error)
+	_, error = authH.AuthenticateByJWT(accessToken)
+	assert.ErrorIs(t, error, auth.ErrUnexpectedSigningMethod)
+}
@@ -0,0 +1,172 @@
+package main
+
+import (
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/mezorian/go-auth-example/pkg/auth"
+	"github.com/stretchr/testify/assert"
+)
+
+// fakeClock : clock for tests which only moves if it is told to
+type fakeClock struct {
+	mutex sync.Mutex
+	now   time.Time
+}
+
+func newFakeClock() *fakeClock {
+	return &fakeClock{now: time.Date(2021, 3, 1, 12, 0, 0, 0, time.UTC)}
+}
+
+func (c *fakeClock) Now() time.Time {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	return c.now
+}
+
+func (c *fakeClock) Advance(duration time.Duration) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	c.now = c.now.Add(duration)
+}
+
+// sign up and login a user and return the generated access token
+func logInNewUser(t *testing.T, authH *auth.AuthHandler, userName string) string {
+	_, error := authH.SignUp(userName, "supersecret")
+	assert.Equal(t, nil, error)
+	result, error := authH.LogIn(userName, "supersecret")
+	assert.Equal(t, nil, error)
+
+	return result.AccessToken
+}
+
+func TestGeneratedJWTContainsRegisteredClaims(t *testing.T) {
+	clock := newFakeClock()
+
+	authH := newAuthHandler(t)
+	authH.SetClock(clock)
+	authH.SetAccessTokenLifetime(5 * time.Minute)
+	authH.SetIssuer("https://auth.example.com")
+	authH.SetAudience("api.example.com", "admin.example.com")
+
+	accessToken := logInNewUser(t, authH, "peter")
+
+	principal, error := authH.AuthenticateByJWT(accessToken)
+	assert.Equal(t, nil, error)
+	claims := principal.Claims
+	assert.Equal(t, "peter", claims.UserName)
+	assert.Equal(t, principal.User.ID, claims.Subject)
+	assert.Equal(t, "https://auth.example.com", claims.Issuer)
+	assert.Equal(t, jwt.ClaimStrings{"api.example.com", "admin.example.com"}, claims.Audience)
+	assert.Equal(t, clock.Now().Unix(), claims.IssuedAt.Unix())
+	assert.Equal(t, clock.Now().Unix(), claims.NotBefore.Unix())
+	assert.Equal(t, clock.Now().Add(5*time.Minute).Unix(), claims.ExpiresAt.Unix())
+	assert.NotEqual(t, "", claims.ID)
+}
+
+func TestJWTExpiresAfterConfiguredLifetime(t *testing.T) {
+	testCaseValues := []struct {
+		lifetime  time.Duration
+		clockSkew time.Duration
+		advance   time.Duration
+		valid     bool
+	}{
+		{time.Minute, 0, 59 * time.Second, true},
+		{time.Minute, 0, 61 * time.Second, false},
+		{time.Minute, 30 * time.Second, 80 * time.Second, true},
+		{time.Minute, 30 * time.Second, 91 * time.Second, false},
+		{time.Hour, 0, 59 * time.Minute, true},
+		{time.Hour, 0, 2 * time.Hour, false},
+	}
+
+	for _, testCaseValue := range testCaseValues {
+		clock := newFakeClock()
+		authH := newAuthHandler(t)
+		authH.SetClock(clock)
+		authH.SetAccessTokenLifetime(testCaseValue.lifetime)
+		authH.SetClockSkew(testCaseValue.clockSkew)
+
+		accessToken := logInNewUser(t, authH, "peter")
+		clock.Advance(testCaseValue.advance)
+
+		_, error := authH.AuthenticateByJWT(accessToken)
+		if testCaseValue.valid {
+			assert.Equal(t, nil, error)
+		} else {
+			assert.ErrorIs(t, error, auth.ErrTokenExpired)
+			assert.ErrorIs(t, error, auth.ErrInvalidToken)
+		}
+	}
+}
+
+func TestJWTIsNotValidBeforeItWasIssued(t *testing.T) {
+	clock := newFakeClock()
+
+	authH := newAuthHandler(t)
+	authH.SetClock(clock)
+	accessToken := logInNewUser(t, authH, "peter")
+
+	// token seems to be issued in the future for a verifier whose clock is behind
+	clock.Advance(-time.Minute)
+	_, error := authH.AuthenticateByJWT(accessToken)
+	assert.ErrorIs(t, error, auth.ErrInvalidToken)
+
+	// clock skew tolerates the difference
+	authH.SetClockSkew(2 * time.Minute)
+	_, error = authH.AuthenticateByJWT(accessToken)
+	assert.Equal(t, nil, error)
+}
+
+func TestJWTWithWrongIssuerOrAudienceIsNotValid(t *testing.T) {
+	testCaseValues := []struct {
+		issuer           string
+		audience         []string
+		expectedIssuer   string
+		expectedAudience []string
+		valid            bool
+	}{
+		{"issuer", []string{"api"}, "issuer", []string{"api"}, true},
+		{"issuer", []string{"api", "web"}, "issuer", []string{"web"}, true},
+		{"issuer", []string{"api"}, "other issuer", []string{"api"}, false},
+		{"issuer", []string{"api"}, "issuer", []string{"web"}, false},
+		{"", nil, "issuer", nil, false},
+		{"", nil, "", []string{"api"}, false},
+	}
+
+	for _, testCaseValue := range testCaseValues {
+		authH := newAuthHandler(t)
+		authH.SetIssuer(testCaseValue.issuer)
+		authH.SetAudience(testCaseValue.audience...)
+		accessToken := logInNewUser(t, authH, "peter")
+
+		// validate with the expected issuer and audience of a verifier
+		authH.SetIssuer(testCaseValue.expectedIssuer)
+		authH.SetAudience(testCaseValue.expectedAudience...)
+		_, error := authH.AuthenticateByJWT(accessToken)
+		if testCaseValue.valid {
+			assert.Equal(t, nil, error)
+		} else {
+			assert.ErrorIs(t, error, auth.ErrInvalidToken)
+		}
+	}
+}
+
+func TestJWTWithoutExpiryIsNotValid(t *testing.T) {
+	authH := newAuthHandler(t)
+	logInNewUser(t, authH, "peter")
+	user, _ := authH.GetUserByUserName("peter")
+
+	// token for an existing user which never expires
+	token := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.MapClaims{
+		"UserName": "peter",
+		"sub":      user.ID,
+		"jti":      "SomeTokenID",
+		"iat":      time.Now().Unix(),
+	})
+	tokenWithoutExpiry, _ := token.SignedString(testSecret)
+
+	_, error := authH.AuthenticateByJWT(tokenWithoutExpiry)
+	assert.ErrorIs(t, error, auth.ErrInvalidToken)
+}
@@ -1,17 +1,15 @@
 package main
 
 import (
-	"os"
 	"testing"
+	"time"
 
-	jwt "github.com/dgrijalva/jwt-go"
+	"github.com/golang-jwt/jwt/v5"
 	"github.com/mezorian/go-auth-example/pkg/auth"
 	"github.com/stretchr/testify/assert"
 )
 
 func TestAuthWithJWTIsSuccessfulAfterLogin(t *testing.T) {
-	setUpTestEnvironment()
-
 	testCaseValues := []struct {
 		username string
 		password string
@@ -20,33 +18,31 @@ func TestAuthWithJWTIsSuccessfulAfterLogin(t *testing.T) {
 		{"peter", "supersecret"},
 		{"anna", "password"},
 	}
-	authH := auth.NewAuthHandler()
+	authH := newAuthHandler(t)
 
 	// sign up users
 	for _, testCaseValue := range testCaseValues {
-		success, error := authH.SignUp(testCaseValue.username, testCaseValue.password)
-		assert.Equal(t, success, true)
-		assert.Equal(t, error, nil)
+		_, error := authH.SignUp(testCaseValue.username, testCaseValue.password)
+		assert.Equal(t, nil, error)
 	}
 
 	// login and after this JWT authenticate
 	for _, testCaseValue := range testCaseValues {
 		// login
-		success, error := authH.LogIn(testCaseValue.username, testCaseValue.password)
-		assert.Equal(t, success, true)
-		assert.Equal(t, error, nil)
-
-		// authenticate
-		user, _ := authH.GetUserByUserName(testCaseValue.username)
-		success, error = authH.AuthenticateByJWT(user.AccessToken)
-		assert.Equal(t, success, true)
-		assert.Equal(t, error, nil)
+		result, error := authH.LogIn(testCaseValue.username, testCaseValue.password)
+		assert.Equal(t, nil, error)
+		assert.Equal(t, testCaseValue.username, result.User.UserName)
+
+		// authenticate and find out who was authenticated
+		principal, error := authH.AuthenticateByJWT(result.AccessToken)
+		assert.Equal(t, nil, error)
+		assert.Equal(t, result.User.ID, principal.User.ID)
+		assert.Equal(t, testCaseValue.username, principal.User.UserName)
+		assert.Equal(t, testCaseValue.username, principal.Claims.UserName)
 	}
 }
 
 func TestAuthWithJWTIsNotSuccessfulWithWrongJWT(t *testing.T) {
-	setUpTestEnvironment()
-
 	testCaseValues := []struct {
 		username string
 		password string
@@ -55,32 +51,55 @@ func TestAuthWithJWTIsNotSuccessfulWithWrongJWT(t *testing.T) {
 		{"peter", "supersecret"},
 		{"anna", "password"},
 	}
-	authH := auth.NewAuthHandler()
+	authH := newAuthHandler(t)
 
 	// sign up users
 	for _, testCaseValue := range testCaseValues {
-		success, error := authH.SignUp(testCaseValue.username, testCaseValue.password)
-		assert.Equal(t, success, true)
-		assert.Equal(t, error, nil)
+		_, error := authH.SignUp(testCaseValue.username, testCaseValue.password)
+		assert.Equal(t, nil, error)
 	}
 
 	// login and after this JWT authenticate
 	for _, testCaseValue := range testCaseValues {
 		// login
-		success, error := authH.LogIn(testCaseValue.username, testCaseValue.password)
-		assert.Equal(t, success, true)
-		assert.Equal(t, error, nil)
+		_, error := authH.LogIn(testCaseValue.username, testCaseValue.password)
+		assert.Equal(t, nil, error)
 
 		// authenticate
-		success, error = authH.AuthenticateByJWT("RandomStringWhichIsNoRealJWT")
-		assert.Equal(t, success, false)
-		assert.Equal(t, error.Error(), "Error : Authentication Failed. JWT AccessToken is not valid!")
+		_, error = authH.AuthenticateByJWT("RandomStringWhichIsNoRealJWT")
+		assert.ErrorIs(t, error, auth.ErrInvalidToken)
 	}
 }
 
-func TestAuthWithJWTIsNotSuccessfulForTheorecticalValidJWTWhichIsNotAssignedToUser(t *testing.T) {
-	setUpTestEnvironment()
+func TestAuthWithJWTIsNotSuccessfulForForgedJWTOfUnknownUser(t *testing.T) {
+	authH := newAuthHandler(t)
+	result := logInNewUserWithResult(t, authH, "peter")
+
+	// generate theoretically valid JWT with the secret of the auth handler
+	forgeToken := func(userID string, sessionID string) string {
+		token := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.MapClaims{
+			"UserName": "UserIWantToHack",
+			"sub":      userID,
+			"sid":      sessionID,
+			"jti":      "SomeTokenID",
+			"iat":      time.Now().Unix(),
+			"exp":      time.Now().Add(time.Hour).Unix(),
+		})
+		signedToken, _ := token.SignedString(testSecret)
+		return signedToken
+	}
+
+	// the forged JWT passes all checks as long as it belongs to the user
+	_, error := authH.AuthenticateByJWT(forgeToken(result.User.ID, result.Session.ID))
+	assert.Equal(t, nil, error)
 
+	// try to authenticate with theoretically valid JWT of an unknown
+	// user with a valid session --> this will fail
+	_, error = authH.AuthenticateByJWT(forgeToken("IDOfUserIWantToHack", result.Session.ID))
+	assert.ErrorIs(t, error, auth.ErrInvalidToken)
+}
+
+func TestAuthWithJWTIsNotSuccessfulForTheorecticalValidJWTWhichIsNotAssignedToUser(t *testing.T) {
 	testCaseValues := []struct {
 		username string
 		password string
@@ -89,35 +108,34 @@ func TestAuthWithJWTIsNotSuccessfulForTheorecticalValidJWTWhichIsNotAssignedToUs
 		{"peter", "supersecret"},
 		{"anna", "password"},
 	}
-	authH := auth.NewAuthHandler()
+	authH := newAuthHandler(t)
 
 	// sign up users
 	for _, testCaseValue := range testCaseValues {
-		success, error := authH.SignUp(testCaseValue.username, testCaseValue.password)
-		assert.Equal(t, success, true)
-		assert.Equal(t, error, nil)
+		_, error := authH.SignUp(testCaseValue.username, testCaseValue.password)
+		assert.Equal(t, nil, error)
 	}
 
 	// login and after this JWT authenticate
 	for _, testCaseValue := range testCaseValues {
 		// login
-		success, error := authH.LogIn(testCaseValue.username, testCaseValue.password)
-		assert.Equal(t, success, true)
-		assert.Equal(t, error, nil)
+		_, error := authH.LogIn(testCaseValue.username, testCaseValue.password)
+		assert.Equal(t, nil, error)
 
 		// generate theoretically valid JWT
-		secret := os.Getenv("SECRET")
 		token := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.MapClaims{
 			"UserName": "UserIWantToHack",
-			"Test":     "Hello World",
+			"sub":      "IDOfUserIWantToHack",
+			"jti":      "SomeTokenID",
+			"iat":      time.Now().Unix(),
+			"exp":      time.Now().Add(time.Hour).Unix(),
 		})
 
-		hackedToken, _ := token.SignedString([]byte(secret))
+		hackedToken, _ := token.SignedString(testSecret)
 
 		// try to authenticate with theoretically valid JWT
 		// --> this will fail
-		success, error = authH.AuthenticateByJWT(hackedToken)
-		assert.Equal(t, success, false)
-		assert.Equal(t, error.Error(), "Error : Authentication Failed. JWT AccessToken is not valid!")
+		_, error = authH.AuthenticateByJWT(hackedToken)
+		assert.ErrorIs(t, error, auth.ErrInvalidToken)
 	}
 }
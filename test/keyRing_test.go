@@ -0,0 +1,251 @@
+package main
+
+import (
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/mezorian/go-auth-example/pkg/auth"
+	"github.com/stretchr/testify/assert"
+)
+
+// create a new signing key for the algorithm
+func newSigningKey(t *testing.T, algorithm string) *auth.SigningKey {
+	privatePEM, _ := generateKeyPair(t, algorithm)
+	signingKey, error := auth.NewSigningKeyFromPEM(algorithm, privatePEM)
+	assert.Equal(t, nil, error)
+
+	return signingKey
+}
+
+// get the kid header of a token
+func keyIDOfToken(t *testing.T, accessToken string) interface{} {
+	token, _, error := jwt.NewParser().ParseUnverified(accessToken, jwt.MapClaims{})
+	assert.Equal(t, nil, error)
+
+	return token.Header["kid"]
+}
+
+func TestJWTIsStampedWithKeyID(t *testing.T) {
+	keyRing := auth.NewKeyRing()
+	keyRing.Rotate("key-1", newSigningKey(t, "ES256"), 0)
+	authH := newAuthHandler(t)
+	authH.SetKeyRing(keyRing)
+
+	accessToken := logInNewUser(t, authH, "peter")
+	assert.Equal(t, "key-1", keyIDOfToken(t, accessToken))
+	assert.Equal(t, "key-1", keyRing.SigningKeyID())
+
+	// a single signing key is identified by its thumbprint
+	signingKey := newSigningKey(t, "EdDSA")
+	authH.SetSigningKey(signingKey)
+	result, _ := authH.LogIn("peter", "supersecret")
+	assert.Equal(t, signingKey.Thumbprint(), keyIDOfToken(t, result.AccessToken))
+
+	// so is the key of a secret
+	authH = newAuthHandler(t)
+	accessToken = logInNewUser(t, authH, "peter")
+	assert.NotEqual(t, nil, keyIDOfToken(t, accessToken))
+}
+
+func TestKeyRotationKeepsTokensValidDuringOverlap(t *testing.T) {
+	clock := newFakeClock()
+
+	keyRing := auth.NewKeyRing()
+	keyRing.SetClock(clock)
+	keyRing.Rotate("key-1", newSigningKey(t, "RS256"), 0)
+	authH := newAuthHandler(t)
+	authH.SetClock(clock)
+	authH.SetAccessTokenLifetime(time.Hour)
+	authH.SetKeyRing(keyRing)
+
+	_, error := authH.SignUp("peter", "supersecret")
+	assert.Equal(t, nil, error)
+	_, error = authH.SignUp("anna", "supersecret")
+	assert.Equal(t, nil, error)
+	oldResult, _ := authH.LogIn("peter", "supersecret")
+
+	// rotate to a key of another algorithm
+	error = keyRing.Rotate("key-2", newSigningKey(t, "ES256"), 10*time.Minute)
+	assert.Equal(t, nil, error)
+	assert.Equal(t, "key-2", keyRing.SigningKeyID())
+	assert.Equal(t, []string{"key-1", "key-2"}, keyRing.KeyIDs())
+
+	newResult, _ := authH.LogIn("anna", "supersecret")
+	assert.Equal(t, "key-2", keyIDOfToken(t, newResult.AccessToken))
+
+	// both tokens are valid during the overlap
+	clock.Advance(9 * time.Minute)
+	_, error = authH.AuthenticateByJWT(oldResult.AccessToken)
+	assert.Equal(t, nil, error)
+	_, error = authH.AuthenticateByJWT(newResult.AccessToken)
+	assert.Equal(t, nil, error)
+
+	// afterwards the old key is retired
+	clock.Advance(2 * time.Minute)
+	_, error = authH.AuthenticateByJWT(oldResult.AccessToken)
+	assert.ErrorIs(t, error, auth.ErrUnknownKeyID)
+	assert.ErrorIs(t, error, auth.ErrInvalidToken)
+	_, error = authH.AuthenticateByJWT(newResult.AccessToken)
+	assert.Equal(t, nil, error)
+	assert.Equal(t, []string{"key-2"}, keyRing.KeyIDs())
+}
+
+func TestScheduledKeyRotation(t *testing.T) {
+	clock := newFakeClock()
+
+	keyRing := auth.NewKeyRing()
+	keyRing.SetClock(clock)
+	keyRing.Rotate("key-1", newSigningKey(t, "ES256"), 0)
+	authH := newAuthHandler(t)
+	authH.SetClock(clock)
+	authH.SetKeyRing(keyRing)
+
+	// the new key is published before it is used for signing
+	error := keyRing.ScheduleRotation("key-2", newSigningKey(t, "ES256"), clock.Now().Add(time.Hour), 15*time.Minute)
+	assert.Equal(t, nil, error)
+	assert.Equal(t, "key-1", keyRing.SigningKeyID())
+	assert.Equal(t, 2, len(keyRing.JWKS().Keys))
+	accessToken := logInNewUser(t, authH, "peter")
+	assert.Equal(t, "key-1", keyIDOfToken(t, accessToken))
+
+	clock.Advance(time.Hour)
+	assert.Equal(t, "key-2", keyRing.SigningKeyID())
+	result, _ := authH.LogIn("peter", "supersecret")
+	assert.Equal(t, "key-2", keyIDOfToken(t, result.AccessToken))
+
+	clock.Advance(15 * time.Minute)
+	assert.Equal(t, []string{"key-2"}, keyRing.KeyIDs())
+}
+
+func TestInvalidKeyRingOperations(t *testing.T) {
+	keyRing := auth.NewKeyRing()
+	signingKey := newSigningKey(t, "ES256")
+	verificationKey, _ := auth.NewVerificationKeyFromPEM("ES256", mustPublicPEM(t, signingKey))
+
+	assert.Equal(t, nil, keyRing.Rotate("key-1", signingKey, 0))
+	assert.ErrorIs(t, keyRing.Rotate("key-1", newSigningKey(t, "ES256"), 0), auth.ErrInvalidKey)
+	assert.ErrorIs(t, keyRing.Rotate("", newSigningKey(t, "ES256"), 0), auth.ErrInvalidKey)
+	assert.ErrorIs(t, keyRing.Rotate("key-2", verificationKey, 0), auth.ErrInvalidKey)
+	assert.ErrorIs(t, keyRing.Rotate("key-2", newSigningKey(t, "ES256"), -time.Minute), auth.ErrInvalidInput)
+	assert.ErrorIs(t, keyRing.RemoveKey("unknown"), auth.ErrInvalidKey)
+
+	assert.Equal(t, nil, keyRing.AddVerificationKey("key-2", verificationKey))
+	assert.Equal(t, "key-1", keyRing.SigningKeyID())
+	assert.Equal(t, nil, keyRing.RemoveKey("key-1"))
+	assert.Equal(t, "", keyRing.SigningKeyID())
+}
+
+// encode the public key of a signing key as PEM
+func mustPublicPEM(t *testing.T, signingKey *auth.SigningKey) []byte {
+	publicDER, error := x509.MarshalPKIXPublicKey(signingKey.PublicKey())
+	assert.Equal(t, nil, error)
+
+	return pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: publicDER})
+}
+
+func TestRemovedOrUnknownKeyInvalidatesTokens(t *testing.T) {
+	privatePEM, _ := generateKeyPair(t, "ES256")
+	signingKey, _ := auth.NewSigningKeyFromPEM("ES256", privatePEM)
+	keyRing := auth.NewKeyRing()
+	keyRing.Rotate("key-1", signingKey, 0)
+	authH := newAuthHandler(t)
+	authH.SetKeyRing(keyRing)
+	accessToken := logInNewUser(t, authH, "peter")
+	user, _ := authH.GetUserByUserName("peter")
+
+	// token which is signed with the key but claims an unknown kid
+	privateKey, _ := jwt.ParseECPrivateKeyFromPEM(privatePEM)
+	token := jwt.NewWithClaims(jwt.SigningMethodES256, jwt.MapClaims{
+		"UserName": "peter",
+		"sub":      user.ID,
+		"jti":      "SomeTokenID",
+		"iat":      time.Now().Unix(),
+		"exp":      time.Now().Add(time.Hour).Unix(),
+	})
+	token.Header["kid"] = "key-x"
+	tokenWithUnknownKeyID, _ := token.SignedString(privateKey)
+	_, error := authH.AuthenticateByJWT(tokenWithUnknownKeyID)
+	assert.ErrorIs(t, error, auth.ErrUnknownKeyID)
+
+	// a removed key does not verify tokens anymore
+	_, error = authH.AuthenticateByJWT(accessToken)
+	assert.Equal(t, nil, error)
+	keyRing.Rotate("key-2", newSigningKey(t, "ES256"), time.Hour)
+	keyRing.RemoveKey("key-1")
+	_, error = authH.AuthenticateByJWT(accessToken)
+	assert.ErrorIs(t, error, auth.ErrUnknownKeyID)
+}
+
+func TestJWKSHandlerServesPublicKeys(t *testing.T) {
+	keyRing := auth.NewKeyRing()
+	rsaKey := newSigningKey(t, "RS256")
+	keyRing.Rotate("key-1", rsaKey, 0)
+	keyRing.Rotate("key-2", newSigningKey(t, "ES256"), time.Hour)
+	keyRing.Rotate("key-3", newSigningKey(t, "EdDSA"), time.Hour)
+	hmacKey, _ := auth.NewHMACSigningKey("HS256", testSecret)
+	keyRing.AddVerificationKey("key-4", hmacKey)
+	authH := newAuthHandler(t)
+	authH.SetKeyRing(keyRing)
+
+	server := httptest.NewServer(authH.JWKSHandler())
+	defer server.Close()
+
+	response, error := http.Get(server.URL)
+	assert.Equal(t, nil, error)
+	defer response.Body.Close()
+	assert.Equal(t, http.StatusOK, response.StatusCode)
+	assert.Equal(t, "application/jwk-set+json", response.Header.Get("Content-Type"))
+
+	var jwks auth.JSONWebKeySet
+	error = json.NewDecoder(response.Body).Decode(&jwks)
+	assert.Equal(t, nil, error)
+
+	// the secret of the HMAC key is never published
+	assert.Equal(t, 3, len(jwks.Keys))
+	testCaseValues := []struct {
+		keyID     string
+		keyType   string
+		algorithm string
+		curve     string
+	}{
+		{"key-1", "RSA", "RS256", ""},
+		{"key-2", "EC", "ES256", "P-256"},
+		{"key-3", "OKP", "EdDSA", "Ed25519"},
+	}
+	for i, testCaseValue := range testCaseValues {
+		assert.Equal(t, testCaseValue.keyID, jwks.Keys[i].KeyID)
+		assert.Equal(t, testCaseValue.keyType, jwks.Keys[i].KeyType)
+		assert.Equal(t, testCaseValue.algorithm, jwks.Keys[i].Algorithm)
+		assert.Equal(t, testCaseValue.curve, jwks.Keys[i].Curve)
+		assert.Equal(t, "sig", jwks.Keys[i].Use)
+	}
+
+	// the published key is the public key of the signing key
+	n, _ := base64.RawURLEncoding.DecodeString(jwks.Keys[0].N)
+	assert.Equal(t, rsaKey.PublicKey().(*rsa.PublicKey).N, new(big.Int).SetBytes(n))
+
+	response, error = http.Post(server.URL, "application/json", nil)
+	assert.Equal(t, nil, error)
+	response.Body.Close()
+	assert.Equal(t, http.StatusMethodNotAllowed, response.StatusCode)
+}
+
+func TestThumbprintMatchesRFC7638(t *testing.T) {
+	// example key of RFC 7638, section 3.1
+	n, _ := base64.RawURLEncoding.DecodeString("0vx7agoebGcQSuuPiLJXZptN9nndrQmbXEps2aiAFbWhM78LhWx4cbbfAAtVT86zwu1RK7aPFFxuhDR1L6tSoc_BJECPebWKRXjBZCiFV4n3oknjhMstn64tZ_2W-5JsGY4Hc5n9yBXArwl93lqt7_RN5w6Cf0h4QyQ5v-65YGjQR0_FDW2QvzqY368QQMicAtaSqzs8KJZgnYb9c7d0zgdAZHzu6qMQvRL5hajrn1n91CbOpbISD08qNLyrdkt-bFTWhAI4vMQFh6WeZu0fM4lFd2NcRwr3XPksINHaQ-G_xBniIqbw0Ls1jF44-csFCur-kEgU8awapJzKnqDKgw")
+	publicKey := &rsa.PublicKey{N: new(big.Int).SetBytes(n), E: 65537}
+	publicDER, _ := x509.MarshalPKIXPublicKey(publicKey)
+
+	verificationKey, error := auth.NewVerificationKeyFromPEM("RS256", pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: publicDER}))
+	assert.Equal(t, nil, error)
+	assert.Equal(t, "NzbLsXh8uDCcd-6MNwXF4W_7noWXFZAfHkxZsRGC9Xs", verificationKey.Thumbprint())
+}
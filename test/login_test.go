@@ -1,22 +1,37 @@
 package main
 
 import (
-	"os"
 	"testing"
 
-	jwt "github.com/dgrijalva/jwt-go"
+	"github.com/golang-jwt/jwt/v5"
 	"github.com/mezorian/go-auth-example/pkg/auth"
 	"github.com/stretchr/testify/assert"
+	"golang.org/x/crypto/bcrypt"
 )
 
-func setUpTestEnvironment() {
-	// generate jwt
-	os.Setenv("SECRET", "super_secret_example_text")
+// secret which signs the tokens of the test handlers
+var testSecret = []byte("9b1f4c7e2a8d6035e4f1b9c2d7a3e8f05c6b1d9e4a7f2c8b3e0d5a6f1c9b4e72")
+
+// create an AuthHandler which signs its tokens with the test secret and
+// hashes passwords with the lowest bcrypt cost, so that tests stay fast.
+// Tests which need the real cost pass their own WithBcryptCost.
+func newAuthHandler(t *testing.T, options ...auth.Option) *auth.AuthHandler {
+	authH, error := auth.NewAuthHandler(append([]auth.Option{auth.WithSecret(testSecret), auth.WithBcryptCost(bcrypt.MinCost)}, options...)...)
+	assert.Equal(t, nil, error)
+
+	return authH
 }
 
-func TestLoginUpReturnsFalseAndErrorMessageForEmptyInputValues(t *testing.T) {
-	setUpTestEnvironment()
+// parse a JWT which was signed with the secret of the test handlers
+func parseClaimsWithSecret(tokenString string) (jwt.MapClaims, error) {
+	claims := jwt.MapClaims{}
+	_, err := jwt.ParseWithClaims(tokenString, claims, func(token *jwt.Token) (interface{}, error) {
+		return testSecret, nil
+	})
+	return claims, err
+}
 
+func TestLoginUpReturnsFalseAndErrorMessageForEmptyInputValues(t *testing.T) {
 	testCaseValues := []struct {
 		username string
 		password string
@@ -27,16 +42,13 @@ func TestLoginUpReturnsFalseAndErrorMessageForEmptyInputValues(t *testing.T) {
 	}
 
 	for _, testCaseValue := range testCaseValues {
-		authH := auth.NewAuthHandler()
-		success, error := authH.LogIn(testCaseValue.username, testCaseValue.password)
-		assert.Equal(t, false, success)
-		assert.Equal(t, "Error : Please enter a valid username and password!", error.Error())
+		authH := newAuthHandler(t)
+		_, error := authH.LogIn(testCaseValue.username, testCaseValue.password)
+		assert.ErrorIs(t, error, auth.ErrInvalidCredentials)
 	}
 }
 
 func TestLoginUpReturnsFalseForNonExistingUsers(t *testing.T) {
-	setUpTestEnvironment()
-
 	testCaseValues := []struct {
 		username string
 		password string
@@ -47,16 +59,13 @@ func TestLoginUpReturnsFalseForNonExistingUsers(t *testing.T) {
 	}
 
 	for _, testCaseValue := range testCaseValues {
-		authH := auth.NewAuthHandler()
-		success, error := authH.LogIn(testCaseValue.username, testCaseValue.password)
-		assert.Equal(t, false, success)
-		assert.Equal(t, "Error : Please enter a valid username and password!", error.Error())
+		authH := newAuthHandler(t)
+		_, error := authH.LogIn(testCaseValue.username, testCaseValue.password)
+		assert.ErrorIs(t, error, auth.ErrInvalidCredentials)
 	}
 }
 
 func TestLoginOnlySuccesfulForCorrectPasswords(t *testing.T) {
-	setUpTestEnvironment()
-
 	testCaseValues := []struct {
 		username        string
 		correctPassword string
@@ -66,29 +75,24 @@ func TestLoginOnlySuccesfulForCorrectPasswords(t *testing.T) {
 		{"peter", "i am a password", "password"},
 		{"melanie", "superS3cre1P0ssw8rd", "admin"},
 	}
-	authH := auth.NewAuthHandler()
+	authH := newAuthHandler(t)
 
 	for _, testCaseValue := range testCaseValues {
 		// sign up new user
-		success, error := authH.SignUp(testCaseValue.username, testCaseValue.correctPassword)
-		assert.Equal(t, success, true)
-		assert.Equal(t, error, nil)
+		_, error := authH.SignUp(testCaseValue.username, testCaseValue.correctPassword)
+		assert.Equal(t, nil, error)
 
 		// try to login with wrong password --> this will fail
-		success, error = authH.LogIn(testCaseValue.username, testCaseValue.wrongPassword)
-		assert.Equal(t, false, success)
-		assert.Equal(t, "Error : Please enter a valid username and password!", error.Error())
+		_, error = authH.LogIn(testCaseValue.username, testCaseValue.wrongPassword)
+		assert.ErrorIs(t, error, auth.ErrInvalidCredentials)
 
 		// try to login with correct password --> this will be successful
-		success, error = authH.LogIn(testCaseValue.username, testCaseValue.correctPassword)
-		assert.Equal(t, true, success)
+		_, error = authH.LogIn(testCaseValue.username, testCaseValue.correctPassword)
 		assert.Equal(t, nil, error)
 	}
 }
 
 func TestLoginDoesNotWorkWithThePasswordsOfOtherUsers(t *testing.T) {
-	setUpTestEnvironment()
-
 	testCaseValues := []struct {
 		username string
 		password string
@@ -97,32 +101,27 @@ func TestLoginDoesNotWorkWithThePasswordsOfOtherUsers(t *testing.T) {
 		{"peter", "i am a password"},
 		{"melanie", "superS3cre1P0ssw8rd"},
 	}
-	authH := auth.NewAuthHandler()
+	authH := newAuthHandler(t)
 
 	// sign up new user
-	success, error := authH.SignUp("anon", "anon's password")
-	assert.Equal(t, success, true)
-	assert.Equal(t, error, nil)
+	_, error := authH.SignUp("anon", "anon's password")
+	assert.Equal(t, nil, error)
 
 	// sign up other users
 	for _, testCaseValue := range testCaseValues {
-		success, error := authH.SignUp(testCaseValue.username, testCaseValue.password)
-		assert.Equal(t, success, true)
-		assert.Equal(t, error, nil)
+		_, error := authH.SignUp(testCaseValue.username, testCaseValue.password)
+		assert.Equal(t, nil, error)
 	}
 
 	// try to login user anon with passwords of other users
 	for _, testCaseValue := range testCaseValues {
 		// try to login with wrong password --> this will fail
-		success, error = authH.LogIn("anon", testCaseValue.password)
-		assert.Equal(t, false, success)
-		assert.Equal(t, "Error : Please enter a valid username and password!", error.Error())
+		_, error = authH.LogIn("anon", testCaseValue.password)
+		assert.ErrorIs(t, error, auth.ErrInvalidCredentials)
 	}
 }
 
 func TestLoginWorksEvenIfSomeUsersHaveTheSamePasswords(t *testing.T) {
-	setUpTestEnvironment()
-
 	testCaseValues := []struct {
 		username string
 		password string
@@ -134,26 +133,22 @@ func TestLoginWorksEvenIfSomeUsersHaveTheSamePasswords(t *testing.T) {
 		{"john", "some other password"},
 		{"peter2", "long password"},
 	}
-	authH := auth.NewAuthHandler()
+	authH := newAuthHandler(t)
 
 	// sign up all users
 	for _, testCaseValue := range testCaseValues {
-		success, error := authH.SignUp(testCaseValue.username, testCaseValue.password)
-		assert.Equal(t, success, true)
-		assert.Equal(t, error, nil)
+		_, error := authH.SignUp(testCaseValue.username, testCaseValue.password)
+		assert.Equal(t, nil, error)
 	}
 
 	// login all users
 	for _, testCaseValue := range testCaseValues {
-		success, error := authH.LogIn(testCaseValue.username, testCaseValue.password)
-		assert.Equal(t, true, success)
+		_, error := authH.LogIn(testCaseValue.username, testCaseValue.password)
 		assert.Equal(t, nil, error)
 	}
 }
 
 func TestLoginReturnsValidJWToken(t *testing.T) {
-	setUpTestEnvironment()
-
 	testCaseValues := []struct {
 		username string
 		password string
@@ -162,26 +157,28 @@ func TestLoginReturnsValidJWToken(t *testing.T) {
 		{"peter", "supersecret"},
 		{"anna", "password"},
 	}
-	authH := auth.NewAuthHandler()
+	authH := newAuthHandler(t)
 
 	for _, testCaseValue := range testCaseValues {
-		success, error := authH.SignUp(testCaseValue.username, testCaseValue.password)
-		assert.Equal(t, success, true)
-		assert.Equal(t, error, nil)
+		_, error := authH.SignUp(testCaseValue.username, testCaseValue.password)
+		assert.Equal(t, nil, error)
 	}
 
 	for _, testCaseValue := range testCaseValues {
-		secret := os.Getenv("SECRET")
-		token := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.MapClaims{
-			"UserName": testCaseValue.username,
-			"Test":     "Hello World",
-		})
-
-		tokenString, _ := token.SignedString([]byte(secret))
-		success, error := authH.LogIn(testCaseValue.username, testCaseValue.password)
-		assert.Equal(t, success, true)
-		assert.Equal(t, error, nil)
+		result, error := authH.LogIn(testCaseValue.username, testCaseValue.password)
+		assert.Equal(t, nil, error)
 		user, _ := authH.GetUserByUserName(testCaseValue.username)
-		assert.Equal(t, tokenString, user.AccessToken)
+
+		// token is signed with the secret and contains all registered claims
+		claims, error := parseClaimsWithSecret(result.AccessToken)
+		assert.Equal(t, nil, error)
+		assert.Equal(t, testCaseValue.username, claims["UserName"])
+		assert.Equal(t, user.ID, claims["sub"])
+		assert.Equal(t, result.Session.ID, claims["sid"])
+		assert.NotEqual(t, "", claims["jti"])
+		assert.Contains(t, claims, "iat")
+		assert.Contains(t, claims, "nbf")
+		assert.Contains(t, claims, "exp")
+		assert.Equal(t, result.AccessTokenExpiresAt.Unix(), int64(claims["exp"].(float64)))
 	}
 }
@@ -0,0 +1,140 @@
+package main
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/mezorian/go-auth-example/pkg/auth"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestChangePasswordRevokesAllSessions(t *testing.T) {
+	clock := newFakeClock()
+	authH := newAuthHandler(t, auth.WithClock(clock))
+	firstLogIn := logInNewUserWithResult(t, authH, "peter")
+	secondLogIn, _ := authH.LogIn("peter", "supersecret")
+	clock.Advance(time.Second)
+
+	result, error := authH.ChangePassword(firstLogIn.User.ID, "supersecret", "evenmoresecret")
+	assert.Equal(t, nil, error)
+
+	// the tokens of all old sessions are refused
+	for _, oldLogIn := range []*auth.LogInResult{firstLogIn, secondLogIn} {
+		_, error = authH.AuthenticateByJWT(oldLogIn.AccessToken)
+		assert.ErrorIs(t, error, auth.ErrInvalidToken)
+		_, error = authH.Refresh(oldLogIn.RefreshToken)
+		assert.ErrorIs(t, error, auth.ErrInvalidToken)
+	}
+
+	// the returned tokens belong to a new session
+	principal, error := authH.AuthenticateByJWT(result.AccessToken)
+	assert.Equal(t, nil, error)
+	assert.Equal(t, result.Session.ID, principal.Session.ID)
+	sessions, _ := authH.ListSessions(result.User.ID)
+	assert.Equal(t, 1, len(sessions))
+
+	// only the new password is accepted
+	_, error = authH.LogIn("peter", "supersecret")
+	assert.ErrorIs(t, error, auth.ErrInvalidCredentials)
+	_, error = authH.LogIn("peter", "evenmoresecret")
+	assert.Equal(t, nil, error)
+
+	user, _ := authH.GetUserByUserName("peter")
+	assert.Equal(t, clock.Now(), user.PasswordChangedAt)
+}
+
+func TestChangePasswordChecksOldAndNewPassword(t *testing.T) {
+	authH := newAuthHandler(t, auth.WithPasswordPolicy(auth.NewPasswordPolicy(auth.MinLengthRule{Length: 8})))
+	user, _ := authH.SignUp("peter", "supersecret")
+	disabledUser, _ := authH.SignUp("anna", "supersecret")
+	authH.DisableUser(disabledUser.ID)
+
+	testCaseValues := []struct {
+		userID      string
+		oldPassword string
+		newPassword string
+		kind        error
+	}{
+		{user.ID, "wrongpassword", "evenmoresecret", auth.ErrInvalidCredentials},
+		{user.ID, "supersecret", "short", auth.ErrPolicyViolation},
+		{user.ID, "supersecret", "supersecret", auth.ErrInvalidInput},
+		{user.ID, "supersecret", "", auth.ErrInvalidInput},
+		{"unknown-id", "supersecret", "evenmoresecret", auth.ErrUserNotFound},
+		{disabledUser.ID, "supersecret", "evenmoresecret", auth.ErrAccountDisabled},
+	}
+
+	for _, testCaseValue := range testCaseValues {
+		_, error := authH.ChangePassword(testCaseValue.userID, testCaseValue.oldPassword, testCaseValue.newPassword)
+		assert.ErrorIs(t, error, testCaseValue.kind, testCaseValue)
+	}
+
+	// the password was not changed
+	_, error := authH.LogIn("peter", "supersecret")
+	assert.Equal(t, nil, error)
+}
+
+func TestSetPasswordReplacesPasswordWithoutTheOldOne(t *testing.T) {
+	clock := newFakeClock()
+	authH := newAuthHandler(t, auth.WithClock(clock), auth.WithPasswordPolicy(auth.NewPasswordPolicy(auth.MinLengthRule{Length: 8})))
+	result := logInNewUserWithResult(t, authH, "peter")
+	clock.Advance(time.Second)
+
+	assert.ErrorIs(t, authH.SetPassword(result.User.ID, "short"), auth.ErrPolicyViolation)
+	assert.ErrorIs(t, authH.SetPassword("unknown-id", "evenmoresecret"), auth.ErrUserNotFound)
+	assert.Equal(t, nil, authH.SetPassword(result.User.ID, "evenmoresecret"))
+
+	_, error := authH.AuthenticateByJWT(result.AccessToken)
+	assert.ErrorIs(t, error, auth.ErrInvalidToken)
+	sessions, _ := authH.ListSessions(result.User.ID)
+	assert.Equal(t, 0, len(sessions))
+	_, error = authH.LogIn("peter", "evenmoresecret")
+	assert.Equal(t, nil, error)
+
+	// the password of inactive users can be set as well
+	authH.LockUser(result.User.ID)
+	assert.Equal(t, nil, authH.SetPassword(result.User.ID, "yetanothersecret"))
+	authH.ActivateUser(result.User.ID)
+	_, error = authH.LogIn("peter", "yetanothersecret")
+	assert.Equal(t, nil, error)
+}
+
+func TestAccessTokensIssuedBeforePasswordChangeAreRefused(t *testing.T) {
+	clock := newFakeClock()
+	revocationStore := auth.NewInMemoryRevocationStore()
+	authH := newAuthHandler(t, auth.WithClock(clock), auth.WithRevocationStore(revocationStore))
+	result := logInNewUserWithResult(t, authH, "peter")
+
+	// e.g. a change by another server whose revocation list is not shared
+	clock.Advance(time.Second)
+	user, _ := authH.GetUserByUserName("peter")
+	user.PasswordChangedAt = clock.Now()
+	authH.GetUserStore().UpdateUser(user)
+
+	_, error := authH.AuthenticateByJWT(result.AccessToken)
+	assert.ErrorIs(t, error, auth.ErrTokenRevoked)
+	assert.Equal(t, 0, revocationStore.Len())
+
+	// tokens issued afterwards are valid
+	newResult, error := authH.LogIn("peter", "supersecret")
+	assert.Equal(t, nil, error)
+	_, error = authH.AuthenticateByJWT(newResult.AccessToken)
+	assert.Equal(t, nil, error)
+}
+
+func TestSQLUserStoreKeepsPasswordChangedAt(t *testing.T) {
+	db := openTestDatabase(t, filepath.Join(t.TempDir(), "users.db"))
+	store, error := auth.NewSQLUserStore(db)
+	assert.Equal(t, nil, error)
+
+	clock := newFakeClock()
+	authH := newAuthHandler(t, auth.WithUserStore(store), auth.WithClock(clock))
+	user, _ := authH.SignUp("peter", "supersecret")
+	user, _ = store.GetUserByID(user.ID)
+	assert.Equal(t, true, user.PasswordChangedAt.IsZero())
+
+	clock.Advance(90 * time.Millisecond)
+	assert.Equal(t, nil, authH.SetPassword(user.ID, "evenmoresecret"))
+	user, _ = store.GetUserByID(user.ID)
+	assert.Equal(t, true, clock.Now().Equal(user.PasswordChangedAt))
+}
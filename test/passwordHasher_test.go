@@ -0,0 +1,138 @@
+package main
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/mezorian/go-auth-example/pkg/auth"
+	"github.com/stretchr/testify/assert"
+	"golang.org/x/crypto/bcrypt"
+)
+
+// hashers with cheap parameters, so that the tests run fast
+func newFastArgon2idHasher() *auth.Argon2idHasher {
+	hasher := auth.NewArgon2idHasher()
+	hasher.Memory = 1024
+	hasher.Iterations = 1
+	return hasher
+}
+
+func newFastScryptHasher() *auth.ScryptHasher {
+	hasher := auth.NewScryptHasher()
+	hasher.CostLog2 = 10
+	return hasher
+}
+
+func TestPasswordHashersCreateVerifiableHashes(t *testing.T) {
+	testCaseValues := []struct {
+		hasher auth.PasswordHasher
+		prefix string
+	}{
+		{&auth.BcryptHasher{Cost: bcrypt.MinCost}, "$2a$04$"},
+		{newFastArgon2idHasher(), "$argon2id$v=19$m=1024,t=1,p=1$"},
+		{newFastScryptHasher(), "$scrypt$ln=10,r=8,p=1$"},
+	}
+
+	for _, testCaseValue := range testCaseValues {
+		hashedPassword, error := testCaseValue.hasher.Hash("supersecret")
+		assert.Equal(t, nil, error)
+		assert.Equal(t, true, strings.HasPrefix(hashedPassword, testCaseValue.prefix), hashedPassword)
+		assert.Equal(t, true, testCaseValue.hasher.Supports(hashedPassword))
+		assert.Equal(t, false, testCaseValue.hasher.NeedsRehash(hashedPassword))
+
+		matches, error := testCaseValue.hasher.Verify("supersecret", hashedPassword)
+		assert.Equal(t, nil, error)
+		assert.Equal(t, true, matches)
+		matches, error = testCaseValue.hasher.Verify("wrongpassword", hashedPassword)
+		assert.Equal(t, nil, error)
+		assert.Equal(t, false, matches)
+
+		// every hash has its own salt
+		otherHashedPassword, _ := testCaseValue.hasher.Hash("supersecret")
+		assert.NotEqual(t, hashedPassword, otherHashedPassword)
+
+		// malformed hashes can not be verified
+		_, error = testCaseValue.hasher.Verify("supersecret", hashedPassword[:len(hashedPassword)-20]+"$")
+		assert.ErrorIs(t, error, auth.ErrInternal)
+	}
+
+	// changed parameters make a rehash necessary
+	argon2idHash, _ := newFastArgon2idHasher().Hash("supersecret")
+	strongerArgon2idHasher := newFastArgon2idHasher()
+	strongerArgon2idHasher.Iterations = 2
+	assert.Equal(t, true, strongerArgon2idHasher.NeedsRehash(argon2idHash))
+	scryptHash, _ := newFastScryptHasher().Hash("supersecret")
+	strongerScryptHasher := newFastScryptHasher()
+	strongerScryptHasher.CostLog2 = 11
+	assert.Equal(t, true, strongerScryptHasher.NeedsRehash(scryptHash))
+	bcryptHash, _ := (&auth.BcryptHasher{Cost: bcrypt.MinCost}).Hash("supersecret")
+	assert.Equal(t, true, auth.NewBcryptHasher().NeedsRehash(bcryptHash))
+	assert.Equal(t, false, auth.NewBcryptHasher().Supports(argon2idHash))
+}
+
+func TestOutdatedPasswordHashIsReplacedOnLogin(t *testing.T) {
+	authH := newAuthHandler(t)
+	logInNewUser(t, authH, "peter")
+	user, _ := authH.GetUserByUserName("peter")
+	assert.Equal(t, true, strings.HasPrefix(user.HashedPassword, "$2a$04$"))
+
+	testCaseValues := []struct {
+		hasher auth.PasswordHasher
+		prefix string
+	}{
+		{newFastArgon2idHasher(), "$argon2id$v=19$m=1024,t=1,p=1$"},
+		{newFastScryptHasher(), "$scrypt$ln=10,r=8,p=1$"},
+		{&auth.BcryptHasher{Cost: 5}, "$2a$05$"},
+	}
+	for _, testCaseValue := range testCaseValues {
+		error := authH.SetPasswordHasher(testCaseValue.hasher)
+		assert.Equal(t, nil, error)
+
+		// a wrong password does not change the hash
+		hashedPassword := user.HashedPassword
+		_, error = authH.LogIn("peter", "wrongpassword")
+		assert.ErrorIs(t, error, auth.ErrInvalidCredentials)
+		user, _ = authH.GetUserByUserName("peter")
+		assert.Equal(t, hashedPassword, user.HashedPassword)
+
+		// the old hash is verified and replaced by the new algorithm
+		result, error := authH.LogIn("peter", "supersecret")
+		assert.Equal(t, nil, error)
+		assert.Equal(t, true, strings.HasPrefix(result.User.HashedPassword, testCaseValue.prefix))
+		user, _ = authH.GetUserByUserName("peter")
+		assert.Equal(t, result.User.HashedPassword, user.HashedPassword)
+
+		// and only replaced once
+		_, error = authH.LogIn("peter", "supersecret")
+		assert.Equal(t, nil, error)
+		rehashedUser, _ := authH.GetUserByUserName("peter")
+		assert.Equal(t, user.HashedPassword, rehashedUser.HashedPassword)
+	}
+}
+
+func TestUnknownPasswordHashCanNotLogIn(t *testing.T) {
+	authH := newAuthHandler(t)
+	authH.GetUserStore().CreateUser(&auth.User{ID: "some-id", UserName: "peter", HashedPassword: "$md5$supersecret"})
+
+	_, error := authH.LogIn("peter", "supersecret")
+	assert.ErrorIs(t, error, auth.ErrInvalidCredentials)
+}
+
+func TestPasswordHasherCanBeConfigured(t *testing.T) {
+	config, error := auth.ParseConfig([]byte("password_hasher:\n  algorithm: argon2id\n  memory: 1024\n  iterations: 1\n"), "yaml")
+	assert.Equal(t, nil, error)
+	authH := newAuthHandler(t, auth.WithConfig(config))
+	user, error := authH.SignUp("peter", "supersecret")
+	assert.Equal(t, nil, error)
+	assert.Equal(t, true, strings.HasPrefix(user.HashedPassword, "$argon2id$v=19$m=1024,t=1,p=1$"))
+
+	invalidConfigs := []*auth.Config{
+		{PasswordHasher: &auth.PasswordHasherConfig{Algorithm: "md5"}},
+		{PasswordHasher: &auth.PasswordHasherConfig{Algorithm: "bcrypt", Cost: 50}},
+		{PasswordHasher: &auth.PasswordHasherConfig{Algorithm: "scrypt"}, BcryptCost: 10},
+	}
+	for _, invalidConfig := range invalidConfigs {
+		_, error := auth.NewAuthHandler(auth.WithSecret(testSecret), auth.WithConfig(invalidConfig))
+		assert.ErrorIs(t, error, auth.ErrInvalidConfig)
+	}
+}
@@ -0,0 +1,113 @@
+package main
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/mezorian/go-auth-example/pkg/auth"
+	"github.com/stretchr/testify/assert"
+)
+
+// get only the names of the violated rules
+func violatedRules(violations []auth.PolicyViolation) []string {
+	rules := []string{}
+	for _, violation := range violations {
+		rules = append(rules, violation.Rule)
+	}
+	return rules
+}
+
+func TestPasswordPolicyReturnsAllViolatedRules(t *testing.T) {
+	policy := auth.NewPasswordPolicy(
+		auth.MinLengthRule{Length: 8},
+		auth.MaxLengthRule{Length: 32},
+		auth.CharacterClassRule{Classes: []auth.CharacterClass{auth.Lowercase, auth.Uppercase, auth.Digit, auth.Special}},
+		auth.MaxRepeatedCharactersRule{Count: 2},
+		auth.NoUserNameRule{},
+		auth.NewDictionaryRule("password", "Passw0rd!"),
+	)
+
+	testCaseValues := []struct {
+		username   string
+		password   string
+		violations []string
+	}{
+		{"peter", "superS3cret!", []string{}},
+		{"peter", "Sh0rt!", []string{"min_length"}},
+		{"peter", "Toooooooooooooooooooooooooooolong1!", []string{"max_length", "max_repeated_characters"}},
+		{"peter", "alllowercase", []string{"character_classes", "max_repeated_characters"}},
+		{"peter", "MyNameIsPeter1!", []string{"no_username"}},
+		{"peter", "passw0rd!", []string{"character_classes", "dictionary"}},
+		{"peter", "peter", []string{"min_length", "character_classes", "no_username"}},
+	}
+
+	for _, testCaseValue := range testCaseValues {
+		violations := policy.Evaluate(testCaseValue.username, testCaseValue.password)
+		assert.Equal(t, testCaseValue.violations, violatedRules(violations), testCaseValue.password)
+	}
+}
+
+func TestPasswordPolicyErrorListsEveryViolation(t *testing.T) {
+	policy := auth.NewPasswordPolicy(
+		auth.MinLengthRule{Length: 8},
+		auth.CharacterClassRule{Classes: []auth.CharacterClass{auth.Uppercase, auth.Digit}},
+	)
+
+	error := policy.Check("peter", "short")
+	assert.Equal(t, "Error : Password does not comply to the password policy : Password must be at least 8 characters long; Password must contain at least one uppercase letter, digit", error.Error())
+
+	var policyError *auth.PasswordPolicyError
+	assert.Equal(t, true, errors.As(error, &policyError))
+	assert.Equal(t, 2, len(policyError.Violations))
+
+	error = policy.Check("peter", "LongEnough1")
+	assert.Equal(t, nil, error)
+}
+
+func TestDictionaryRuleFromFileSupportsWordsAndSHA1Hashes(t *testing.T) {
+	// SHA-1 of "hunter2" in the format of breached password lists
+	path := filepath.Join(t.TempDir(), "dictionary.txt")
+	content := "123456\nQwerty\n\nF3BBBD66A63D4BF1747940578EC3D0103530E21D:17043\n"
+	assert.Equal(t, nil, os.WriteFile(path, []byte(content), 0600))
+
+	rule, error := auth.NewDictionaryRuleFromFile(path)
+	assert.Equal(t, nil, error)
+
+	testCaseValues := []struct {
+		password string
+		found    bool
+	}{
+		{"123456", true},
+		{"qwerty", true},
+		{"QWERTY", true},
+		{"hunter2", true},
+		{"Hunter2", false},
+		{"superS3cret!", false},
+	}
+
+	for _, testCaseValue := range testCaseValues {
+		violation := rule.Validate("peter", testCaseValue.password)
+		assert.Equal(t, testCaseValue.found, violation != nil, testCaseValue.password)
+	}
+
+	_, error = auth.NewDictionaryRuleFromFile(filepath.Join(t.TempDir(), "missing.txt"))
+	assert.NotEqual(t, nil, error)
+}
+
+func TestSignUpChecksPasswordPolicy(t *testing.T) {
+	authH := newAuthHandler(t)
+	authH.SetPasswordPolicy(auth.NewPasswordPolicy(
+		auth.MinLengthRule{Length: 8},
+		auth.NoUserNameRule{},
+	))
+
+	_, error := authH.SignUp("peter", "peter")
+	var policyError *auth.PasswordPolicyError
+	assert.Equal(t, true, errors.As(error, &policyError))
+	assert.Equal(t, []string{"min_length", "no_username"}, violatedRules(policyError.Violations))
+
+	_, error = authH.SignUp("peter", "supersecret")
+	assert.Equal(t, nil, error)
+}
@@ -0,0 +1,242 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/mezorian/go-auth-example/pkg/auth"
+	"github.com/sirupsen/logrus/hooks/test"
+	"github.com/stretchr/testify/assert"
+)
+
+// notifier which keeps all notifications in memory
+type capturingNotifier struct {
+	mutex         sync.Mutex
+	notifications []auth.Notification
+}
+
+func (n *capturingNotifier) Notify(notification auth.Notification) error {
+	n.mutex.Lock()
+	defer n.mutex.Unlock()
+	n.notifications = append(n.notifications, notification)
+	return nil
+}
+
+func (n *capturingNotifier) last(t *testing.T) auth.Notification {
+	n.mutex.Lock()
+	defer n.mutex.Unlock()
+	assert.NotEqual(t, 0, len(n.notifications))
+	return n.notifications[len(n.notifications)-1]
+}
+
+func TestResetPasswordWithTokenFromNotification(t *testing.T) {
+	clock := newFakeClock()
+	notifier := &capturingNotifier{}
+	authH := newAuthHandler(t, auth.WithClock(clock), auth.WithNotifier(notifier))
+	result := logInNewUserWithResult(t, authH, "peter")
+
+	assert.Equal(t, nil, authH.RequestPasswordReset("peter"))
+	notification := notifier.last(t)
+	assert.Equal(t, auth.NotificationPasswordReset, notification.Kind)
+	assert.Equal(t, result.User.ID, notification.UserID)
+	assert.Equal(t, "peter", notification.UserName)
+	assert.Equal(t, clock.Now().Add(auth.DefaultPasswordResetTokenLifetime), notification.ExpiresAt)
+
+	clock.Advance(time.Second)
+	assert.Equal(t, nil, authH.ResetPassword(notification.Token, "evenmoresecret"))
+
+	// only the new password is accepted and all old sessions are revoked
+	_, error := authH.LogIn("peter", "supersecret")
+	assert.ErrorIs(t, error, auth.ErrInvalidCredentials)
+	_, error = authH.LogIn("peter", "evenmoresecret")
+	assert.Equal(t, nil, error)
+	_, error = authH.AuthenticateByJWT(result.AccessToken)
+	assert.ErrorIs(t, error, auth.ErrInvalidToken)
+	_, error = authH.Refresh(result.RefreshToken)
+	assert.ErrorIs(t, error, auth.ErrInvalidToken)
+
+	// the token can only be used once
+	assert.ErrorIs(t, authH.ResetPassword(notification.Token, "yetanothersecret"), auth.ErrInvalidToken)
+}
+
+func TestResetPasswordRefusesInvalidTokens(t *testing.T) {
+	clock := newFakeClock()
+	notifier := &capturingNotifier{}
+	authH := newAuthHandler(t, auth.WithClock(clock), auth.WithNotifier(notifier), auth.WithPasswordResetTokenLifetime(10*time.Minute))
+	authH.SignUp("peter", "supersecret")
+
+	// unknown tokens and refresh tokens are refused
+	result, _ := authH.LogIn("peter", "supersecret")
+	assert.ErrorIs(t, authH.ResetPassword("unknown-token", "evenmoresecret"), auth.ErrInvalidToken)
+	assert.ErrorIs(t, authH.ResetPassword(result.RefreshToken, "evenmoresecret"), auth.ErrInvalidToken)
+
+	// a new request replaces the earlier token
+	authH.RequestPasswordReset("peter")
+	oldToken := notifier.last(t).Token
+	authH.RequestPasswordReset("peter")
+	newToken := notifier.last(t).Token
+	assert.NotEqual(t, oldToken, newToken)
+	assert.ErrorIs(t, authH.ResetPassword(oldToken, "evenmoresecret"), auth.ErrInvalidToken)
+
+	// expired tokens are refused
+	clock.Advance(10 * time.Minute)
+	assert.ErrorIs(t, authH.ResetPassword(newToken, "evenmoresecret"), auth.ErrTokenExpired)
+
+	_, error := authH.LogIn("peter", "supersecret")
+	assert.Equal(t, nil, error)
+}
+
+func TestResetPasswordKeepsTokenIfNewPasswordIsRefused(t *testing.T) {
+	notifier := &capturingNotifier{}
+	authH := newAuthHandler(t, auth.WithNotifier(notifier), auth.WithPasswordPolicy(auth.NewPasswordPolicy(auth.MinLengthRule{Length: 8})))
+	authH.SignUp("peter", "supersecret")
+	authH.RequestPasswordReset("peter")
+	token := notifier.last(t).Token
+
+	assert.ErrorIs(t, authH.ResetPassword(token, "short"), auth.ErrPolicyViolation)
+	assert.ErrorIs(t, authH.ResetPassword(token, ""), auth.ErrInvalidInput)
+	assert.Equal(t, nil, authH.ResetPassword(token, "evenmoresecret"))
+	assert.ErrorIs(t, authH.ResetPassword(token, "yetanothersecret"), auth.ErrInvalidToken)
+}
+
+// password rule which counts how often it was checked
+type countingPasswordRule struct {
+	checks int
+}
+
+func (r *countingPasswordRule) Validate(userName string, password string) *auth.PolicyViolation {
+	r.checks++
+	return nil
+}
+
+func TestResetPasswordChecksPolicyOnce(t *testing.T) {
+	notifier := &capturingNotifier{}
+	rule := &countingPasswordRule{}
+	authH := newAuthHandler(t, auth.WithNotifier(notifier), auth.WithPasswordPolicy(auth.NewPasswordPolicy(rule)))
+	authH.SignUp("peter", "supersecret")
+	authH.RequestPasswordReset("peter")
+	rule.checks = 0
+
+	assert.Equal(t, nil, authH.ResetPassword(notifier.last(t).Token, "evenmoresecret"))
+	assert.Equal(t, 1, rule.checks)
+}
+
+func TestChangingPasswordInvalidatesResetTokens(t *testing.T) {
+	notifier := &capturingNotifier{}
+	store := auth.NewInMemoryOneTimeTokenStore()
+	authH := newAuthHandler(t, auth.WithNotifier(notifier), auth.WithOneTimeTokenStore(store))
+	peter, _ := authH.SignUp("peter", "supersecret")
+
+	// a token which was requested before the password was changed or set can not be used anymore
+	authH.RequestPasswordReset("peter")
+	_, error := authH.ChangePassword(peter.ID, "supersecret", "evenmoresecret")
+	assert.Equal(t, nil, error)
+	assert.Equal(t, 0, store.Len())
+	assert.ErrorIs(t, authH.ResetPassword(notifier.last(t).Token, "yetanothersecret"), auth.ErrInvalidToken)
+
+	authH.RequestPasswordReset("peter")
+	assert.Equal(t, nil, authH.SetPassword(peter.ID, "yetanothersecret"))
+	assert.ErrorIs(t, authH.ResetPassword(notifier.last(t).Token, "supersecret"), auth.ErrInvalidToken)
+	_, error = authH.LogIn("peter", "yetanothersecret")
+	assert.Equal(t, nil, error)
+}
+
+func TestRequestPasswordResetDoesNotRevealUsers(t *testing.T) {
+	notifier := &capturingNotifier{}
+	store := auth.NewInMemoryOneTimeTokenStore()
+	authH := newAuthHandler(t, auth.WithNotifier(notifier), auth.WithOneTimeTokenStore(store))
+	disabledUser, _ := authH.SignUp("anna", "supersecret")
+	authH.DisableUser(disabledUser.ID)
+
+	// unknown and inactive users get the same result but no token
+	assert.Equal(t, nil, authH.RequestPasswordReset("unknown"))
+	assert.Equal(t, nil, authH.RequestPasswordReset("anna"))
+	assert.Equal(t, 0, len(notifier.notifications))
+	assert.Equal(t, 0, store.Len())
+
+	// tokens of users which became inactive can not be used
+	authH.SignUp("peter", "supersecret")
+	authH.RequestPasswordReset("peter")
+	peter, _ := authH.GetUserByUserName("peter")
+	authH.LockUser(peter.ID)
+	assert.ErrorIs(t, authH.ResetPassword(notifier.last(t).Token, "evenmoresecret"), auth.ErrAccountInactive)
+}
+
+func TestPasswordResetTokensAreStoredAsHash(t *testing.T) {
+	notifier := &capturingNotifier{}
+	store := auth.NewInMemoryOneTimeTokenStore()
+	authH := newAuthHandler(t, auth.WithNotifier(notifier), auth.WithOneTimeTokenStore(store))
+	authH.SignUp("peter", "supersecret")
+	authH.RequestPasswordReset("peter")
+	token := notifier.last(t).Token
+
+	_, error := store.GetOneTimeTokenByHash(token)
+	assert.ErrorIs(t, error, auth.ErrInvalidToken)
+	assert.Equal(t, 1, store.Len())
+}
+
+func TestResetPasswordEndsLockout(t *testing.T) {
+	notifier := &capturingNotifier{}
+	rateLimiter := auth.NewRateLimiter()
+	rateLimiter.LockoutThreshold = 3
+	rateLimiter.BackoffBase = 0
+	authH := newAuthHandler(t, auth.WithNotifier(notifier), auth.WithRateLimiter(rateLimiter))
+	authH.SignUp("peter", "supersecret")
+
+	for i := 0; i < 3; i++ {
+		authH.LogIn("peter", "wrongpassword")
+	}
+	_, error := authH.LogIn("peter", "supersecret")
+	assert.ErrorIs(t, error, auth.ErrAccountLocked)
+
+	authH.RequestPasswordReset("peter")
+	assert.Equal(t, nil, authH.ResetPassword(notifier.last(t).Token, "evenmoresecret"))
+	_, error = authH.LogIn("peter", "evenmoresecret")
+	assert.Equal(t, nil, error)
+}
+
+func TestRequestPasswordResetNeedsNotifier(t *testing.T) {
+	authH := newAuthHandler(t)
+	authH.SignUp("peter", "supersecret")
+	assert.ErrorIs(t, authH.RequestPasswordReset("peter"), auth.ErrNotAllowed)
+
+	_, error := auth.NewAuthHandler(auth.WithSecret(testSecret), auth.WithNotifier(nil))
+	assert.ErrorIs(t, error, auth.ErrInvalidInput)
+	_, error = auth.NewAuthHandler(auth.WithSecret(testSecret), auth.WithPasswordResetTokenLifetime(0))
+	assert.ErrorIs(t, error, auth.ErrInvalidInput)
+}
+
+func TestNotifiersForLocalTesting(t *testing.T) {
+	// the file notifier appends every notification as line of JSON
+	path := filepath.Join(t.TempDir(), "notifications.jsonl")
+	authH := newAuthHandler(t, auth.WithNotifier(auth.NewFileNotifier(path)))
+	authH.SignUp("peter", "supersecret")
+	authH.RequestPasswordReset("peter")
+	authH.RequestPasswordReset("peter")
+
+	file, error := os.Open(path)
+	assert.Equal(t, nil, error)
+	defer file.Close()
+	var notifications []auth.Notification
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		var notification auth.Notification
+		assert.Equal(t, nil, json.Unmarshal(scanner.Bytes(), &notification))
+		notifications = append(notifications, notification)
+	}
+	assert.Equal(t, 2, len(notifications))
+	assert.Equal(t, nil, authH.ResetPassword(notifications[1].Token, "evenmoresecret"))
+
+	// the log notifier writes the token to the logger
+	logger, hook := test.NewNullLogger()
+	authH = newAuthHandler(t, auth.WithNotifier(&auth.LogNotifier{Logger: logger}))
+	authH.SignUp("peter", "supersecret")
+	authH.RequestPasswordReset("peter")
+	token, _ := hook.LastEntry().Data["token"].(string)
+	assert.Equal(t, nil, authH.ResetPassword(token, "evenmoresecret"))
+}
@@ -0,0 +1,301 @@
+package main
+
+import (
+	"errors"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/mezorian/go-auth-example/pkg/auth"
+	"github.com/stretchr/testify/assert"
+)
+
+// create an AuthHandler with the rate limiter and a fake clock and
+// sign up the users with the password supersecret
+func newRateLimitedAuthHandler(t *testing.T, rateLimiter *auth.RateLimiter, userNames ...string) (*auth.AuthHandler, *fakeClock) {
+	clock := newFakeClock()
+	authH := newAuthHandler(t, auth.WithRateLimiter(rateLimiter), auth.WithClock(clock))
+	for _, userName := range userNames {
+		_, error := authH.SignUp(userName, "supersecret")
+		assert.Equal(t, nil, error)
+	}
+
+	return authH, clock
+}
+
+// get the time after which the login can be tried again
+func retryAfter(t *testing.T, error error) time.Duration {
+	var rateLimitError *auth.RateLimitError
+	assert.Equal(t, true, errors.As(error, &rateLimitError), error)
+	if rateLimitError == nil {
+		return 0
+	}
+
+	return rateLimitError.RetryAfter
+}
+
+func TestLogInsPerUserNameAreLimited(t *testing.T) {
+	authH, clock := newRateLimitedAuthHandler(t, &auth.RateLimiter{
+		UserNameLimit: auth.RateLimit{Burst: 3, Interval: time.Minute},
+		CounterStore:  auth.NewInMemoryCounterStore(),
+	}, "peter", "anna")
+
+	for i := 0; i < 3; i++ {
+		_, error := authH.LogIn("peter", "supersecret")
+		assert.Equal(t, nil, error)
+	}
+
+	// the bucket of the user name is empty
+	_, error := authH.LogIn("peter", "supersecret")
+	assert.ErrorIs(t, error, auth.ErrRateLimited)
+	assert.Equal(t, false, errors.Is(error, auth.ErrAccountLocked))
+	assert.Equal(t, http.StatusTooManyRequests, auth.HTTPStatus(error))
+	assert.Equal(t, time.Minute, retryAfter(t, error))
+
+	// other user names have their own bucket
+	_, error = authH.LogIn("anna", "supersecret")
+	assert.Equal(t, nil, error)
+
+	// a token is gained every interval
+	clock.Advance(time.Minute)
+	_, error = authH.LogIn("peter", "supersecret")
+	assert.Equal(t, nil, error)
+	_, error = authH.LogIn("peter", "supersecret")
+	assert.ErrorIs(t, error, auth.ErrRateLimited)
+}
+
+func TestLogInsPerIPAreLimited(t *testing.T) {
+	authH, clock := newRateLimitedAuthHandler(t, &auth.RateLimiter{
+		IPLimit:      auth.RateLimit{Burst: 2, Interval: time.Second},
+		CounterStore: auth.NewInMemoryCounterStore(),
+	}, "peter", "anna", "paul")
+
+	client := auth.ClientInfo{IP: "203.0.113.7"}
+	_, error := authH.LogInFromClient("peter", "supersecret", client)
+	assert.Equal(t, nil, error)
+	_, error = authH.LogInFromClient("anna", "wrongpassword", client)
+	assert.ErrorIs(t, error, auth.ErrInvalidCredentials)
+
+	// the IP is limited independent of the user name
+	_, error = authH.LogInFromClient("paul", "supersecret", client)
+	assert.ErrorIs(t, error, auth.ErrRateLimited)
+	assert.Equal(t, time.Second, retryAfter(t, error))
+
+	// other IPs and logins without IP are not affected
+	_, error = authH.LogInFromClient("paul", "supersecret", auth.ClientInfo{IP: "198.51.100.1"})
+	assert.Equal(t, nil, error)
+	_, error = authH.LogIn("paul", "supersecret")
+	assert.Equal(t, nil, error)
+
+	clock.Advance(time.Second)
+	_, error = authH.LogInFromClient("paul", "supersecret", client)
+	assert.Equal(t, nil, error)
+}
+
+func TestFailedLogInsDelayTheNextLogInExponentially(t *testing.T) {
+	authH, clock := newRateLimitedAuthHandler(t, &auth.RateLimiter{
+		BackoffBase:   time.Second,
+		BackoffMax:    4 * time.Second,
+		FailureWindow: time.Hour,
+		CounterStore:  auth.NewInMemoryCounterStore(),
+	}, "peter")
+
+	for _, backoff := range []time.Duration{time.Second, 2 * time.Second, 4 * time.Second, 4 * time.Second} {
+		_, error := authH.LogIn("peter", "wrongpassword")
+		assert.ErrorIs(t, error, auth.ErrInvalidCredentials)
+
+		// even the right password is refused until the backoff is over
+		_, error = authH.LogIn("peter", "supersecret")
+		assert.ErrorIs(t, error, auth.ErrRateLimited)
+		assert.Equal(t, backoff, retryAfter(t, error))
+		clock.Advance(backoff)
+	}
+
+	// a successful login resets the backoff
+	_, error := authH.LogIn("peter", "supersecret")
+	assert.Equal(t, nil, error)
+	authH.LogIn("peter", "wrongpassword")
+	_, error = authH.LogIn("peter", "supersecret")
+	assert.Equal(t, time.Second, retryAfter(t, error))
+}
+
+func TestSuccessfulLogInDoesNotResetBackoffOfIP(t *testing.T) {
+	authH, clock := newRateLimitedAuthHandler(t, &auth.RateLimiter{
+		BackoffBase:   time.Second,
+		BackoffMax:    time.Minute,
+		FailureWindow: time.Hour,
+		CounterStore:  auth.NewInMemoryCounterStore(),
+	}, "peter", "mallory")
+
+	// guess passwords of another account from the same IP
+	client := auth.ClientInfo{IP: "203.0.113.7"}
+	authH.LogInFromClient("peter", "wrongpassword", client)
+	clock.Advance(time.Second)
+	authH.LogInFromClient("peter", "wrongpassword", client)
+	clock.Advance(2 * time.Second)
+
+	// a login of an own account does not reset the backoff of the IP
+	_, error := authH.LogInFromClient("mallory", "supersecret", client)
+	assert.Equal(t, nil, error)
+	_, error = authH.LogInFromClient("anna", "wrongpassword", client)
+	assert.ErrorIs(t, error, auth.ErrInvalidCredentials)
+	_, error = authH.LogInFromClient("mallory", "supersecret", client)
+	assert.Equal(t, 4*time.Second, retryAfter(t, error))
+}
+
+func TestAccountIsLockedAfterTooManyFailedLogIns(t *testing.T) {
+	authH, clock := newRateLimitedAuthHandler(t, &auth.RateLimiter{
+		LockoutThreshold: 3,
+		LockoutDuration:  15 * time.Minute,
+		FailureWindow:    time.Hour,
+		CounterStore:     auth.NewInMemoryCounterStore(),
+	}, "peter")
+
+	for i := 0; i < 3; i++ {
+		_, error := authH.LogIn("peter", "wrongpassword")
+		assert.ErrorIs(t, error, auth.ErrInvalidCredentials)
+	}
+
+	_, error := authH.LogIn("peter", "supersecret")
+	assert.ErrorIs(t, error, auth.ErrAccountLocked)
+	assert.ErrorIs(t, error, auth.ErrRateLimited)
+	assert.Equal(t, 15*time.Minute, retryAfter(t, error))
+
+	// the lockout ends after its duration
+	clock.Advance(15 * time.Minute)
+	_, error = authH.LogIn("peter", "supersecret")
+	assert.Equal(t, nil, error)
+
+	// unknown user names are locked as well
+	for i := 0; i < 3; i++ {
+		_, error = authH.LogIn("anna", "supersecret")
+		assert.ErrorIs(t, error, auth.ErrInvalidCredentials)
+	}
+	_, error = authH.LogIn("anna", "supersecret")
+	assert.ErrorIs(t, error, auth.ErrAccountLocked)
+}
+
+func TestLockedAccountCanBeUnlocked(t *testing.T) {
+	authH, _ := newRateLimitedAuthHandler(t, &auth.RateLimiter{
+		LockoutThreshold: 2,
+		LockoutDuration:  time.Hour,
+		FailureWindow:    time.Hour,
+		CounterStore:     auth.NewInMemoryCounterStore(),
+	}, "peter")
+
+	authH.LogIn("peter", "wrongpassword")
+	authH.LogIn("peter", "wrongpassword")
+	_, error := authH.LogIn("peter", "supersecret")
+	assert.ErrorIs(t, error, auth.ErrAccountLocked)
+
+	assert.Equal(t, nil, authH.UnlockAccount("peter"))
+	_, error = authH.LogIn("peter", "supersecret")
+	assert.Equal(t, nil, error)
+
+	// the failures before the unlock are forgotten
+	authH.LogIn("peter", "wrongpassword")
+	_, error = authH.LogIn("peter", "supersecret")
+	assert.Equal(t, nil, error)
+}
+
+func TestFailedLogInsAreForgottenAfterTheFailureWindow(t *testing.T) {
+	authH, clock := newRateLimitedAuthHandler(t, &auth.RateLimiter{
+		LockoutThreshold: 3,
+		LockoutDuration:  time.Hour,
+		FailureWindow:    10 * time.Minute,
+		CounterStore:     auth.NewInMemoryCounterStore(),
+	}, "peter")
+
+	authH.LogIn("peter", "wrongpassword")
+	authH.LogIn("peter", "wrongpassword")
+	clock.Advance(10*time.Minute + time.Second)
+	authH.LogIn("peter", "wrongpassword")
+	authH.LogIn("peter", "wrongpassword")
+
+	_, error := authH.LogIn("peter", "supersecret")
+	assert.Equal(t, nil, error)
+}
+
+func TestAuthHandlersWithTheSameCounterStoreShareTheirLimits(t *testing.T) {
+	clock := newFakeClock()
+	userStore := auth.NewInMemoryUserStore()
+	counterStore := auth.NewInMemoryCounterStore()
+	newServer := func() *auth.AuthHandler {
+		return newAuthHandler(t, auth.WithUserStore(userStore), auth.WithClock(clock), auth.WithRateLimiter(&auth.RateLimiter{
+			UserNameLimit: auth.RateLimit{Burst: 2, Interval: time.Minute},
+			CounterStore:  counterStore,
+		}))
+	}
+	firstServer, secondServer := newServer(), newServer()
+	firstServer.SignUp("peter", "supersecret")
+
+	_, error := firstServer.LogIn("peter", "supersecret")
+	assert.Equal(t, nil, error)
+	_, error = secondServer.LogIn("peter", "supersecret")
+	assert.Equal(t, nil, error)
+	_, error = firstServer.LogIn("peter", "supersecret")
+	assert.ErrorIs(t, error, auth.ErrRateLimited)
+	_, error = secondServer.LogIn("peter", "supersecret")
+	assert.ErrorIs(t, error, auth.ErrRateLimited)
+}
+
+func TestExpiredCountersArePruned(t *testing.T) {
+	rateLimiter := auth.NewRateLimiter()
+	counterStore := rateLimiter.CounterStore.(*auth.InMemoryCounterStore)
+	authH, clock := newRateLimitedAuthHandler(t, rateLimiter, "peter")
+
+	authH.LogInFromClient("peter", "supersecret", auth.ClientInfo{IP: "203.0.113.7"})
+	authH.LogInFromClient("anna", "supersecret", auth.ClientInfo{IP: "203.0.113.8"})
+	assert.Equal(t, 4, counterStore.Len())
+
+	// after the failure window every bucket is full again and the failure
+	// of anna is forgotten, so only the new counter of paul is left
+	clock.Advance(time.Hour)
+	authH.LogIn("paul", "supersecret")
+	assert.Equal(t, 1, counterStore.Len())
+}
+
+func TestInvalidRateLimiterIsRejected(t *testing.T) {
+	testCaseValues := []struct {
+		description string
+		rateLimiter *auth.RateLimiter
+	}{
+		{"no counter store", &auth.RateLimiter{}},
+		{"negative burst", &auth.RateLimiter{UserNameLimit: auth.RateLimit{Burst: -1, Interval: time.Second}, CounterStore: auth.NewInMemoryCounterStore()}},
+		{"no interval", &auth.RateLimiter{IPLimit: auth.RateLimit{Burst: 1}, CounterStore: auth.NewInMemoryCounterStore()}},
+		{"negative backoff", &auth.RateLimiter{BackoffBase: -time.Second, CounterStore: auth.NewInMemoryCounterStore()}},
+		{"no lockout duration", &auth.RateLimiter{LockoutThreshold: 3, CounterStore: auth.NewInMemoryCounterStore()}},
+	}
+
+	for _, testCaseValue := range testCaseValues {
+		_, error := auth.NewAuthHandler(auth.WithSecret(testSecret), auth.WithRateLimiter(testCaseValue.rateLimiter))
+		assert.ErrorIs(t, error, auth.ErrInvalidInput, testCaseValue.description)
+	}
+
+	// logins are not limited by default and after removing the rate limiter
+	authH := newAuthHandler(t)
+	assert.Equal(t, (*auth.RateLimiter)(nil), authH.GetRateLimiter())
+	assert.Equal(t, nil, authH.SetRateLimiter(nil))
+	assert.Equal(t, nil, authH.UnlockAccount("peter"))
+}
+
+func TestRateLimiterCanBeConfigured(t *testing.T) {
+	config, error := auth.ParseConfig([]byte(`
+rate_limiter:
+  user_name_burst: 5
+  ip_interval: 2s
+  lockout_threshold: 4
+`), "yaml")
+	assert.Equal(t, nil, error)
+	authH := newAuthHandler(t, auth.WithConfig(config))
+
+	rateLimiter := authH.GetRateLimiter()
+	assert.Equal(t, auth.RateLimit{Burst: 5, Interval: time.Minute}, rateLimiter.UserNameLimit)
+	assert.Equal(t, auth.RateLimit{Burst: 100, Interval: 2 * time.Second}, rateLimiter.IPLimit)
+	assert.Equal(t, 4, rateLimiter.LockoutThreshold)
+	assert.Equal(t, 15*time.Minute, rateLimiter.LockoutDuration)
+
+	config, _ = auth.ParseConfig([]byte(`{"rate_limiter": {"ip_burst": -1}}`), "json")
+	_, error = auth.NewAuthHandler(auth.WithSecret(testSecret), auth.WithConfig(config))
+	assert.ErrorIs(t, error, auth.ErrInvalidConfig)
+}
@@ -0,0 +1,173 @@
+package main
+
+import (
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/mezorian/go-auth-example/pkg/auth"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLogInIssuesRefreshToken(t *testing.T) {
+	clock := newFakeClock()
+
+	authH := newAuthHandler(t)
+	authH.SetClock(clock)
+	authH.SetRefreshTokenLifetime(24 * time.Hour)
+	authH.SignUp("peter", "supersecret")
+
+	result, error := authH.LogIn("peter", "supersecret")
+	assert.Equal(t, nil, error)
+	assert.NotEqual(t, "", result.RefreshToken)
+	assert.Equal(t, clock.Now().Add(24*time.Hour), result.RefreshTokenExpiresAt)
+
+	// the refresh token is opaque and no JWT
+	assert.Equal(t, false, strings.Contains(result.RefreshToken, "."))
+
+	// two logins get different refresh tokens
+	otherResult, _ := authH.LogIn("peter", "supersecret")
+	assert.NotEqual(t, result.RefreshToken, otherResult.RefreshToken)
+}
+
+func TestRefreshTokenIsStoredHashed(t *testing.T) {
+	authH := newAuthHandler(t)
+	result := logInNewUserWithResult(t, authH, "peter")
+
+	_, error := authH.GetRefreshTokenStore().GetRefreshTokenByHash(result.RefreshToken)
+	assert.ErrorIs(t, error, auth.ErrInvalidToken)
+}
+
+// sign up and login a user and return the result of the login
+func logInNewUserWithResult(t *testing.T, authH *auth.AuthHandler, userName string) *auth.LogInResult {
+	_, error := authH.SignUp(userName, "supersecret")
+	assert.Equal(t, nil, error)
+	result, error := authH.LogIn(userName, "supersecret")
+	assert.Equal(t, nil, error)
+
+	return result
+}
+
+func TestRefreshRotatesTokens(t *testing.T) {
+	clock := newFakeClock()
+
+	authH := newAuthHandler(t)
+	authH.SetClock(clock)
+	result := logInNewUserWithResult(t, authH, "peter")
+
+	// the access token expires, but the refresh token gets a new one
+	clock.Advance(20 * time.Minute)
+	_, error := authH.AuthenticateByJWT(result.AccessToken)
+	assert.ErrorIs(t, error, auth.ErrTokenExpired)
+
+	refreshed, error := authH.Refresh(result.RefreshToken)
+	assert.Equal(t, nil, error)
+	assert.Equal(t, "peter", refreshed.User.UserName)
+	assert.NotEqual(t, result.RefreshToken, refreshed.RefreshToken)
+	assert.Equal(t, clock.Now().Add(auth.DefaultAccessTokenLifetime).Unix(), refreshed.AccessTokenExpiresAt.Unix())
+
+	principal, error := authH.AuthenticateByJWT(refreshed.AccessToken)
+	assert.Equal(t, nil, error)
+	assert.Equal(t, "peter", principal.User.UserName)
+
+	// the new refresh token can be used again
+	_, error = authH.Refresh(refreshed.RefreshToken)
+	assert.Equal(t, nil, error)
+}
+
+func TestReusedRefreshTokenRevokesFamily(t *testing.T) {
+	authH := newAuthHandler(t)
+	result := logInNewUserWithResult(t, authH, "peter")
+	otherLogIn, _ := authH.LogIn("peter", "supersecret")
+
+	// the legitimate client rotates its refresh token
+	refreshed, error := authH.Refresh(result.RefreshToken)
+	assert.Equal(t, nil, error)
+
+	// an attacker replays the stolen refresh token
+	_, error = authH.Refresh(result.RefreshToken)
+	assert.ErrorIs(t, error, auth.ErrRefreshTokenReused)
+	assert.ErrorIs(t, error, auth.ErrInvalidToken)
+
+	// so all tokens of the family are revoked
+	_, error = authH.Refresh(refreshed.RefreshToken)
+	assert.ErrorIs(t, error, auth.ErrInvalidToken)
+	assert.NotErrorIs(t, error, auth.ErrRefreshTokenReused)
+
+	// but other logins of the user are not affected
+	_, error = authH.Refresh(otherLogIn.RefreshToken)
+	assert.Equal(t, nil, error)
+}
+
+func TestRefreshTokenCanOnlyBeUsedOnceConcurrently(t *testing.T) {
+	authH := newAuthHandler(t)
+	result := logInNewUserWithResult(t, authH, "peter")
+
+	const numberOfRefreshes = 20
+	var waitGroup sync.WaitGroup
+	successes := make(chan *auth.LogInResult, numberOfRefreshes)
+	for i := 0; i < numberOfRefreshes; i++ {
+		waitGroup.Add(1)
+		go func() {
+			defer waitGroup.Done()
+			if refreshed, error := authH.Refresh(result.RefreshToken); error == nil {
+				successes <- refreshed
+			}
+		}()
+	}
+	waitGroup.Wait()
+	close(successes)
+
+	assert.Equal(t, 1, len(successes))
+}
+
+func TestExpiredRefreshTokensArePrunedWhileTokensAreIssued(t *testing.T) {
+	clock := newFakeClock()
+	authH := newAuthHandler(t, auth.WithClock(clock), auth.WithRefreshTokenLifetime(time.Hour))
+	result := logInNewUserWithResult(t, authH, "peter")
+
+	// a login after the lifetime removes the expired token from the store
+	clock.Advance(time.Hour + time.Minute)
+	_, error := authH.Refresh(result.RefreshToken)
+	assert.ErrorIs(t, error, auth.ErrTokenExpired)
+	_, error = authH.LogIn("peter", "supersecret")
+	assert.Equal(t, nil, error)
+	_, error = authH.Refresh(result.RefreshToken)
+	assert.ErrorIs(t, error, auth.ErrInvalidToken)
+	assert.NotErrorIs(t, error, auth.ErrTokenExpired)
+}
+
+func TestInvalidRefreshTokens(t *testing.T) {
+	clock := newFakeClock()
+
+	authH := newAuthHandler(t)
+	authH.SetClock(clock)
+	authH.SetRefreshTokenLifetime(time.Hour)
+	result := logInNewUserWithResult(t, authH, "peter")
+
+	// unknown tokens
+	testCaseValues := []string{"", "RandomStringWhichIsNoRefreshToken", result.AccessToken}
+	for _, testCaseValue := range testCaseValues {
+		_, error := authH.Refresh(testCaseValue)
+		assert.ErrorIs(t, error, auth.ErrInvalidToken)
+	}
+
+	// expired token
+	clock.Advance(time.Hour)
+	_, error := authH.Refresh(result.RefreshToken)
+	assert.ErrorIs(t, error, auth.ErrTokenExpired)
+
+	// expired tokens can be deleted from the store
+	error = authH.GetRefreshTokenStore().DeleteExpiredRefreshTokens(clock.Now().Add(time.Second))
+	assert.Equal(t, nil, error)
+	_, error = authH.Refresh(result.RefreshToken)
+	assert.ErrorIs(t, error, auth.ErrInvalidToken)
+	assert.NotErrorIs(t, error, auth.ErrTokenExpired)
+
+	// token of a deleted user
+	other := logInNewUserWithResult(t, authH, "anna")
+	authH.GetUserStore().DeleteUser(other.User.ID)
+	_, error = authH.Refresh(other.RefreshToken)
+	assert.ErrorIs(t, error, auth.ErrInvalidToken)
+}
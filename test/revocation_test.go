@@ -0,0 +1,140 @@
+package main
+
+import (
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/mezorian/go-auth-example/pkg/auth"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLogOutRevokesTokensOfSession(t *testing.T) {
+	authH := newAuthHandler(t)
+	laptop := logInNewUserWithResult(t, authH, "peter")
+	phone, _ := authH.LogIn("peter", "supersecret")
+
+	error := authH.LogOut(laptop.AccessToken)
+	assert.Equal(t, nil, error)
+
+	// access and refresh token of the session can not be used anymore
+	_, error = authH.AuthenticateByJWT(laptop.AccessToken)
+	assert.ErrorIs(t, error, auth.ErrTokenRevoked)
+	assert.ErrorIs(t, error, auth.ErrInvalidToken)
+	_, error = authH.Refresh(laptop.RefreshToken)
+	assert.ErrorIs(t, error, auth.ErrInvalidToken)
+	revoked, _ := authH.GetRevocationStore().IsTokenRevoked(parseTokenID(t, laptop.AccessToken))
+	assert.Equal(t, true, revoked)
+
+	// the other session is still logged in
+	_, error = authH.AuthenticateByJWT(phone.AccessToken)
+	assert.Equal(t, nil, error)
+
+	// logging out twice or with an invalid token fails
+	error = authH.LogOut(laptop.AccessToken)
+	assert.ErrorIs(t, error, auth.ErrInvalidToken)
+	error = authH.LogOut("RandomStringWhichIsNoRealJWT")
+	assert.ErrorIs(t, error, auth.ErrInvalidToken)
+}
+
+// get the jti claim of a token without validating it
+func parseTokenID(t *testing.T, accessToken string) string {
+	claims := jwt.MapClaims{}
+	_, _, error := jwt.NewParser().ParseUnverified(accessToken, claims)
+	assert.Equal(t, nil, error)
+
+	return claims["jti"].(string)
+}
+
+func TestRevokedTokenIsRejectedEvenIfSessionIsActive(t *testing.T) {
+	authH := newAuthHandler(t)
+	result := logInNewUserWithResult(t, authH, "peter")
+
+	error := authH.GetRevocationStore().RevokeToken(parseTokenID(t, result.AccessToken), result.AccessTokenExpiresAt)
+	assert.Equal(t, nil, error)
+
+	_, error = authH.AuthenticateByJWT(result.AccessToken)
+	assert.ErrorIs(t, error, auth.ErrTokenRevoked)
+}
+
+func TestAllTokensOfUserCanBeRevoked(t *testing.T) {
+	clock := newFakeClock()
+
+	authH := newAuthHandler(t)
+	authH.SetClock(clock)
+	first := logInNewUserWithResult(t, authH, "peter")
+	second, _ := authH.LogIn("peter", "supersecret")
+	other := logInNewUserWithResult(t, authH, "anna")
+
+	clock.Advance(time.Second)
+	error := authH.RevokeAllTokensForUser(first.User.ID)
+	assert.Equal(t, nil, error)
+
+	for _, result := range []*auth.LogInResult{first, second} {
+		_, error = authH.AuthenticateByJWT(result.AccessToken)
+		assert.ErrorIs(t, error, auth.ErrTokenRevoked)
+		_, error = authH.Refresh(result.RefreshToken)
+		assert.ErrorIs(t, error, auth.ErrInvalidToken)
+	}
+
+	// tokens of other users are not affected
+	_, error = authH.AuthenticateByJWT(other.AccessToken)
+	assert.Equal(t, nil, error)
+
+	// tokens which are issued afterwards are valid
+	clock.Advance(time.Second)
+	result, error := authH.LogIn("peter", "supersecret")
+	assert.Equal(t, nil, error)
+	_, error = authH.AuthenticateByJWT(result.AccessToken)
+	assert.Equal(t, nil, error)
+}
+
+func TestExpiredRevocationsArePruned(t *testing.T) {
+	clock := newFakeClock()
+
+	revocationStore := auth.NewInMemoryRevocationStore()
+	authH := newAuthHandler(t)
+	authH.SetClock(clock)
+	authH.SetAccessTokenLifetime(5 * time.Minute)
+	authH.SetRevocationStore(revocationStore)
+
+	first := logInNewUserWithResult(t, authH, "peter")
+	authH.LogOut(first.AccessToken)
+	authH.RevokeAllTokensForUser(first.User.ID)
+	assert.Equal(t, 2, revocationStore.Len())
+
+	// the entries are kept as long as the revoked tokens are not expired
+	clock.Advance(4 * time.Minute)
+	second := logInNewUserWithResult(t, authH, "anna")
+	authH.LogOut(second.AccessToken)
+	assert.Equal(t, 3, revocationStore.Len())
+
+	// and removed afterwards
+	clock.Advance(2 * time.Minute)
+	third, _ := authH.LogIn("anna", "supersecret")
+	authH.LogOut(third.AccessToken)
+	assert.Equal(t, 2, revocationStore.Len())
+	revoked, _ := revocationStore.IsTokenRevoked(parseTokenID(t, first.AccessToken))
+	assert.Equal(t, false, revoked)
+	revoked, _ = revocationStore.IsTokenRevoked(parseTokenID(t, second.AccessToken))
+	assert.Equal(t, true, revoked)
+}
+
+func TestRevocationStoreCanBeShared(t *testing.T) {
+	// two instances of a service share users, sessions and the revocation list
+	revocationStore := auth.NewInMemoryRevocationStore()
+	first := newAuthHandler(t)
+	first.SetRevocationStore(revocationStore)
+	second := newAuthHandler(t, auth.WithUserStore(first.GetUserStore()))
+	second.SetSessionStore(first.GetSessionStore())
+	second.SetRevocationStore(revocationStore)
+
+	result := logInNewUserWithResult(t, first, "peter")
+	_, error := second.AuthenticateByJWT(result.AccessToken)
+	assert.Equal(t, nil, error)
+
+	error = first.LogOut(result.AccessToken)
+	assert.Equal(t, nil, error)
+	_, error = second.AuthenticateByJWT(result.AccessToken)
+	assert.ErrorIs(t, error, auth.ErrTokenRevoked)
+}
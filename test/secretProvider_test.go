@@ -0,0 +1,112 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/mezorian/go-auth-example/pkg/auth"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAuthHandlerCanNotBeCreatedWithoutSecret(t *testing.T) {
+	authH, error := auth.NewAuthHandler()
+	assert.Equal(t, (*auth.AuthHandler)(nil), authH)
+	assert.ErrorIs(t, error, auth.ErrNoSecret)
+
+	t.Setenv("JWT_SECRET", "")
+	_, error = auth.NewAuthHandler(auth.WithSecretProvider(auth.NewEnvSecretProvider("JWT_SECRET")))
+	assert.ErrorIs(t, error, auth.ErrNoSecret)
+
+	_, error = auth.NewAuthHandler(auth.WithSecretProvider(auth.NewFileSecretProvider(filepath.Join(t.TempDir(), "missing"))))
+	assert.ErrorIs(t, error, auth.ErrNoSecret)
+}
+
+func TestWeakSecretsAreRejectedAtStartup(t *testing.T) {
+	testCaseValues := []struct {
+		description string
+		secret      string
+	}{
+		{"empty", ""},
+		{"too short", "super_secret_example_text"},
+		{"one character", strings.Repeat("a", 64)},
+		{"repeated pattern", strings.Repeat("ab", 32)},
+		{"few characters", strings.Repeat("secret", 8)},
+	}
+
+	for _, testCaseValue := range testCaseValues {
+		authH, error := auth.NewAuthHandler(auth.WithSecret([]byte(testCaseValue.secret)))
+		assert.Equal(t, (*auth.AuthHandler)(nil), authH, testCaseValue.description)
+		assert.NotEqual(t, nil, error, testCaseValue.description)
+		if testCaseValue.secret != "" {
+			assert.ErrorIs(t, error, auth.ErrWeakSecret, testCaseValue.description)
+			assert.ErrorIs(t, error, auth.ErrInvalidKey, testCaseValue.description)
+		}
+	}
+
+	// a random secret is accepted
+	assert.Equal(t, nil, auth.CheckSecretStrength(testSecret))
+}
+
+func TestSecretCanBeReadFromEnvironment(t *testing.T) {
+	t.Setenv("JWT_SECRET", string(testSecret))
+
+	authH, error := auth.NewAuthHandler(auth.WithSecretProvider(auth.NewEnvSecretProvider("JWT_SECRET")))
+	assert.Equal(t, nil, error)
+	accessToken := logInNewUser(t, authH, "peter")
+
+	// the token is signed with the secret of the environment
+	claims, error := parseClaimsWithSecret(accessToken)
+	assert.Equal(t, nil, error)
+	assert.Equal(t, "peter", claims["UserName"])
+}
+
+func TestSecretFileIsReloadedWhenItChanges(t *testing.T) {
+	clock := newFakeClock()
+	path := filepath.Join(t.TempDir(), "jwt-secret")
+	os.WriteFile(path, append(testSecret, '\n'), 0600)
+
+	authH, error := auth.NewAuthHandler(auth.WithSecretProvider(auth.NewFileSecretProvider(path)))
+	assert.Equal(t, nil, error)
+	authH.SetClock(clock)
+	authH.SetAccessTokenLifetime(time.Hour)
+	oldToken := logInNewUser(t, authH, "peter")
+	assert.Equal(t, keyIDOfSecret(t, testSecret), keyIDOfToken(t, oldToken))
+
+	// the new secret is used after the next reload
+	newSecret := []byte("c4e8a1f7b3d95e2064f8b1c7a9d3e5f2b6c0d8a4e1f7b9c3d5a2e6f0b8c4d1a7e9")
+	os.WriteFile(path, newSecret, 0600)
+	clock.Advance(time.Minute)
+	result, error := authH.LogIn("peter", "supersecret")
+	assert.Equal(t, nil, error)
+	assert.Equal(t, keyIDOfSecret(t, newSecret), keyIDOfToken(t, result.AccessToken))
+
+	// tokens of the old secret stay valid until they expire
+	_, error = authH.AuthenticateByJWT(oldToken)
+	assert.Equal(t, nil, error)
+	_, error = authH.AuthenticateByJWT(result.AccessToken)
+	assert.Equal(t, nil, error)
+
+	// a weak secret is not used, the current one is kept
+	os.WriteFile(path, []byte("weak"), 0600)
+	clock.Advance(time.Minute)
+	weakResult, error := authH.LogIn("peter", "supersecret")
+	assert.Equal(t, nil, error)
+	assert.Equal(t, keyIDOfToken(t, result.AccessToken), keyIDOfToken(t, weakResult.AccessToken))
+
+	// after the overlap the old secret is retired
+	clock.Advance(time.Hour)
+	_, error = authH.AuthenticateByJWT(oldToken)
+	assert.ErrorIs(t, error, auth.ErrUnknownKeyID)
+	assert.Equal(t, []string{keyIDOfSecret(t, newSecret)}, authH.GetKeyRing().KeyIDs())
+}
+
+// get the key ID of the HS256 key of a secret
+func keyIDOfSecret(t *testing.T, secret []byte) string {
+	signingKey, error := auth.NewHMACSigningKey("HS256", secret)
+	assert.Equal(t, nil, error)
+
+	return signingKey.Thumbprint()
+}
@@ -0,0 +1,183 @@
+package main
+
+import (
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/mezorian/go-auth-example/pkg/auth"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestUserCanHaveSeveralSessions(t *testing.T) {
+	clock := newFakeClock()
+
+	authH := newAuthHandler(t)
+	authH.SetClock(clock)
+	user, _ := authH.SignUp("peter", "supersecret")
+
+	laptop, error := authH.LogInFromClient("peter", "supersecret", auth.ClientInfo{UserAgent: "Firefox", IP: "192.0.2.1"})
+	assert.Equal(t, nil, error)
+	clock.Advance(time.Minute)
+	phone, error := authH.LogInFromClient("peter", "supersecret", auth.ClientInfo{UserAgent: "Mobile Safari", IP: "198.51.100.7"})
+	assert.Equal(t, nil, error)
+	assert.NotEqual(t, laptop.Session.ID, phone.Session.ID)
+
+	// logging in on the phone does not log out the laptop
+	for _, result := range []*auth.LogInResult{laptop, phone} {
+		principal, error := authH.AuthenticateByJWT(result.AccessToken)
+		assert.Equal(t, nil, error)
+		assert.Equal(t, result.Session.ID, principal.Session.ID)
+		assert.Equal(t, result.Session.ID, principal.Claims.SessionID)
+	}
+
+	sessions, error := authH.ListSessions(user.ID)
+	assert.Equal(t, nil, error)
+	assert.Equal(t, 2, len(sessions))
+	testCaseValues := []struct {
+		id        string
+		userAgent string
+		ip        string
+		createdAt time.Time
+	}{
+		{laptop.Session.ID, "Firefox", "192.0.2.1", clock.Now().Add(-time.Minute)},
+		{phone.Session.ID, "Mobile Safari", "198.51.100.7", clock.Now()},
+	}
+	for i, testCaseValue := range testCaseValues {
+		assert.Equal(t, testCaseValue.id, sessions[i].ID)
+		assert.Equal(t, user.ID, sessions[i].UserID)
+		assert.Equal(t, testCaseValue.userAgent, sessions[i].UserAgent)
+		assert.Equal(t, testCaseValue.ip, sessions[i].IP)
+		assert.Equal(t, testCaseValue.createdAt, sessions[i].CreatedAt)
+		assert.Equal(t, testCaseValue.createdAt.Add(auth.DefaultRefreshTokenLifetime), sessions[i].ExpiresAt)
+	}
+}
+
+func TestSessionRemembersWhenItWasLastSeen(t *testing.T) {
+	clock := newFakeClock()
+
+	authH := newAuthHandler(t)
+	authH.SetClock(clock)
+	authH.SetAccessTokenLifetime(time.Hour)
+	result := logInNewUserWithResult(t, authH, "peter")
+	loggedInAt := clock.Now()
+
+	testCaseValues := []struct {
+		advance    time.Duration
+		lastSeenAt time.Time
+	}{
+		{10 * time.Second, loggedInAt},
+		{5 * time.Minute, loggedInAt.Add(10*time.Second + 5*time.Minute)},
+		{10 * time.Minute, loggedInAt.Add(10*time.Second + 15*time.Minute)},
+	}
+	for _, testCaseValue := range testCaseValues {
+		clock.Advance(testCaseValue.advance)
+		_, error := authH.AuthenticateByJWT(result.AccessToken)
+		assert.Equal(t, nil, error)
+
+		session, _ := authH.GetSessionStore().GetSession(result.Session.ID)
+		assert.Equal(t, testCaseValue.lastSeenAt, session.LastSeenAt)
+	}
+
+	// refreshing extends the session
+	clock.Advance(time.Hour)
+	refreshed, error := authH.Refresh(result.RefreshToken)
+	assert.Equal(t, nil, error)
+	assert.Equal(t, result.Session.ID, refreshed.Session.ID)
+	assert.Equal(t, clock.Now(), refreshed.Session.LastSeenAt)
+	assert.Equal(t, clock.Now().Add(auth.DefaultRefreshTokenLifetime), refreshed.Session.ExpiresAt)
+}
+
+func TestRevokedSessionIsLoggedOut(t *testing.T) {
+	authH := newAuthHandler(t)
+	laptop := logInNewUserWithResult(t, authH, "peter")
+	phone, _ := authH.LogIn("peter", "supersecret")
+
+	error := authH.RevokeSession(laptop.Session.ID)
+	assert.Equal(t, nil, error)
+
+	// access and refresh token of the revoked session are not valid anymore
+	_, error = authH.AuthenticateByJWT(laptop.AccessToken)
+	assert.ErrorIs(t, error, auth.ErrInvalidToken)
+	_, error = authH.Refresh(laptop.RefreshToken)
+	assert.ErrorIs(t, error, auth.ErrInvalidToken)
+
+	// the other session is still logged in
+	_, error = authH.AuthenticateByJWT(phone.AccessToken)
+	assert.Equal(t, nil, error)
+	sessions, _ := authH.ListSessions(phone.User.ID)
+	assert.Equal(t, 1, len(sessions))
+
+	// unknown sessions can not be revoked
+	error = authH.RevokeSession(laptop.Session.ID)
+	assert.ErrorIs(t, error, auth.ErrSessionNotFound)
+	assert.Equal(t, http.StatusNotFound, auth.HTTPStatus(error))
+}
+
+func TestAllSessionsOfUserCanBeRevoked(t *testing.T) {
+	authH := newAuthHandler(t)
+	first := logInNewUserWithResult(t, authH, "peter")
+	second, _ := authH.LogIn("peter", "supersecret")
+	other := logInNewUserWithResult(t, authH, "anna")
+
+	error := authH.RevokeAllSessions(first.User.ID)
+	assert.Equal(t, nil, error)
+
+	for _, result := range []*auth.LogInResult{first, second} {
+		_, error = authH.AuthenticateByJWT(result.AccessToken)
+		assert.ErrorIs(t, error, auth.ErrInvalidToken)
+		_, error = authH.Refresh(result.RefreshToken)
+		assert.ErrorIs(t, error, auth.ErrInvalidToken)
+	}
+	sessions, _ := authH.ListSessions(first.User.ID)
+	assert.Equal(t, 0, len(sessions))
+
+	// sessions of other users are not affected
+	_, error = authH.AuthenticateByJWT(other.AccessToken)
+	assert.Equal(t, nil, error)
+}
+
+func TestExpiredSessionIsNotValid(t *testing.T) {
+	clock := newFakeClock()
+
+	authH := newAuthHandler(t)
+	authH.SetClock(clock)
+	authH.SetAccessTokenLifetime(time.Hour)
+	authH.SetRefreshTokenLifetime(10 * time.Minute)
+	result := logInNewUserWithResult(t, authH, "peter")
+
+	// the access token is still valid, but its session expired
+	clock.Advance(11 * time.Minute)
+	_, error := authH.AuthenticateByJWT(result.AccessToken)
+	assert.ErrorIs(t, error, auth.ErrInvalidToken)
+	sessions, _ := authH.ListSessions(result.User.ID)
+	assert.Equal(t, 0, len(sessions))
+
+	// expired sessions can be deleted from the store
+	error = authH.GetSessionStore().DeleteExpiredSessions(clock.Now())
+	assert.Equal(t, nil, error)
+	_, error = authH.GetSessionStore().GetSession(result.Session.ID)
+	assert.ErrorIs(t, error, auth.ErrSessionNotFound)
+}
+
+func TestExpiredSessionsArePrunedAtLogInAndRefresh(t *testing.T) {
+	clock := newFakeClock()
+	authH := newAuthHandler(t, auth.WithClock(clock), auth.WithRefreshTokenLifetime(10*time.Minute))
+	expired := logInNewUserWithResult(t, authH, "peter")
+
+	// the next login after the lifetime removes the expired session
+	clock.Advance(11 * time.Minute)
+	result, error := authH.LogIn("peter", "supersecret")
+	assert.Equal(t, nil, error)
+	_, error = authH.GetSessionStore().GetSession(expired.Session.ID)
+	assert.ErrorIs(t, error, auth.ErrSessionNotFound)
+
+	// so does a refresh of a session which is still valid
+	clock.Advance(time.Minute)
+	other := logInNewUserWithResult(t, authH, "anna")
+	clock.Advance(9*time.Minute + 30*time.Second)
+	_, error = authH.Refresh(other.RefreshToken)
+	assert.Equal(t, nil, error)
+	_, error = authH.GetSessionStore().GetSession(result.Session.ID)
+	assert.ErrorIs(t, error, auth.ErrSessionNotFound)
+}
@@ -0,0 +1,170 @@
+package main
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/pem"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/mezorian/go-auth-example/pkg/auth"
+	"github.com/stretchr/testify/assert"
+)
+
+// encode the private and the public key of a generated key pair as PEM
+func encodeKeyPair(t *testing.T, privateKey crypto.Signer) (privatePEM []byte, publicPEM []byte) {
+	privateDER, error := x509.MarshalPKCS8PrivateKey(privateKey)
+	assert.Equal(t, nil, error)
+	publicDER, error := x509.MarshalPKIXPublicKey(privateKey.Public())
+	assert.Equal(t, nil, error)
+
+	privatePEM = pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: privateDER})
+	publicPEM = pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: publicDER})
+	return privatePEM, publicPEM
+}
+
+// generate a key pair which fits to the algorithm
+func generateKeyPair(t *testing.T, algorithm string) (privatePEM []byte, publicPEM []byte) {
+	var privateKey crypto.Signer
+	switch algorithm {
+	case "RS256", "PS256":
+		privateKey, _ = rsa.GenerateKey(rand.Reader, 2048)
+	case "ES256":
+		privateKey, _ = ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	case "ES384":
+		privateKey, _ = ecdsa.GenerateKey(elliptic.P384(), rand.Reader)
+	case "EdDSA":
+		_, privateKey, _ = ed25519.GenerateKey(rand.Reader)
+	}
+
+	return encodeKeyPair(t, privateKey)
+}
+
+func TestJWTCanBeSignedWithAsymmetricKeys(t *testing.T) {
+	for _, algorithm := range []string{"RS256", "PS256", "ES256", "ES384", "EdDSA"} {
+		privatePEM, publicPEM := generateKeyPair(t, algorithm)
+
+		// the issuing handler holds the private key
+		signingKey, error := auth.NewSigningKeyFromPEM(algorithm, privatePEM)
+		assert.Equal(t, nil, error, algorithm)
+		assert.Equal(t, true, signingKey.CanSign())
+		assert.Equal(t, algorithm, signingKey.Algorithm())
+
+		authH := newAuthHandler(t)
+		error = authH.SetSigningKey(signingKey)
+		assert.Equal(t, nil, error)
+		accessToken := logInNewUser(t, authH, "peter")
+
+		token, _, error := jwt.NewParser().ParseUnverified(accessToken, jwt.MapClaims{})
+		assert.Equal(t, nil, error)
+		assert.Equal(t, algorithm, token.Header["alg"])
+
+		principal, error := authH.AuthenticateByJWT(accessToken)
+		assert.Equal(t, nil, error, algorithm)
+		assert.Equal(t, "peter", principal.User.UserName)
+
+		// a downstream service only holds the public key
+		verificationKey, error := auth.NewVerificationKeyFromPEM(algorithm, publicPEM)
+		assert.Equal(t, nil, error, algorithm)
+		assert.Equal(t, false, verificationKey.CanSign())
+		assert.Equal(t, signingKey.PublicKey(), verificationKey.PublicKey())
+
+		verifier := newAuthHandler(t, auth.WithUserStore(authH.GetUserStore()))
+		verifier.SetSessionStore(authH.GetSessionStore())
+		error = verifier.SetSigningKey(verificationKey)
+		assert.Equal(t, nil, error)
+		principal, error = verifier.AuthenticateByJWT(accessToken)
+		assert.Equal(t, nil, error, algorithm)
+		assert.Equal(t, "peter", principal.User.UserName)
+
+		// and is not able to issue tokens
+		_, error = verifier.LogIn("peter", "supersecret")
+		assert.ErrorIs(t, error, auth.ErrNoSecret)
+	}
+}
+
+func TestSigningKeysCanBeLoadedFromFiles(t *testing.T) {
+	privatePEM, publicPEM := generateKeyPair(t, "ES256")
+	directory := t.TempDir()
+	os.WriteFile(filepath.Join(directory, "private.pem"), privatePEM, 0600)
+	os.WriteFile(filepath.Join(directory, "public.pem"), publicPEM, 0644)
+
+	signingKey, error := auth.LoadSigningKeyFromFile("ES256", filepath.Join(directory, "private.pem"))
+	assert.Equal(t, nil, error)
+	verificationKey, error := auth.LoadVerificationKeyFromFile("ES256", filepath.Join(directory, "public.pem"))
+	assert.Equal(t, nil, error)
+	assert.Equal(t, signingKey.PublicKey(), verificationKey.PublicKey())
+
+	_, error = auth.LoadSigningKeyFromFile("ES256", filepath.Join(directory, "missing.pem"))
+	assert.ErrorIs(t, error, auth.ErrInvalidKey)
+}
+
+func TestInvalidSigningKeysAreRejected(t *testing.T) {
+	rsaPEM, _ := generateKeyPair(t, "RS256")
+	ecPEM, ecPublicPEM := generateKeyPair(t, "ES256")
+	smallRSAKey, _ := rsa.GenerateKey(rand.Reader, 1024)
+	smallRSAPEM, _ := encodeKeyPair(t, smallRSAKey)
+
+	testCaseValues := []struct {
+		description string
+		createKey   func() (*auth.SigningKey, error)
+	}{
+		{"HMAC algorithm for PEM key", func() (*auth.SigningKey, error) { return auth.NewSigningKeyFromPEM("HS256", rsaPEM) }},
+		{"unknown algorithm", func() (*auth.SigningKey, error) { return auth.NewSigningKeyFromPEM("XYZ", rsaPEM) }},
+		{"key of other type", func() (*auth.SigningKey, error) { return auth.NewSigningKeyFromPEM("RS256", ecPEM) }},
+		{"curve does not fit to algorithm", func() (*auth.SigningKey, error) { return auth.NewSigningKeyFromPEM("ES384", ecPEM) }},
+		{"RSA key too small", func() (*auth.SigningKey, error) { return auth.NewSigningKeyFromPEM("RS256", smallRSAPEM) }},
+		{"no PEM", func() (*auth.SigningKey, error) { return auth.NewSigningKeyFromPEM("RS256", []byte("no key")) }},
+		{"public key as private key", func() (*auth.SigningKey, error) { return auth.NewSigningKeyFromPEM("ES256", ecPublicPEM) }},
+		{"private key as public key", func() (*auth.SigningKey, error) { return auth.NewVerificationKeyFromPEM("ES256", ecPEM) }},
+		{"asymmetric algorithm for HMAC key", func() (*auth.SigningKey, error) { return auth.NewHMACSigningKey("RS256", []byte("secret")) }},
+		{"empty HMAC secret", func() (*auth.SigningKey, error) { return auth.NewHMACSigningKey("HS256", nil) }},
+		{"short HMAC secret", func() (*auth.SigningKey, error) { return auth.NewHMACSigningKey("HS256", []byte("secret")) }},
+		{"predictable HMAC secret", func() (*auth.SigningKey, error) {
+			return auth.NewHMACSigningKey("HS256", []byte(strings.Repeat("s", 32)))
+		}},
+	}
+
+	for _, testCaseValue := range testCaseValues {
+		signingKey, error := testCaseValue.createKey()
+		assert.Equal(t, (*auth.SigningKey)(nil), signingKey, testCaseValue.description)
+		assert.ErrorIs(t, error, auth.ErrInvalidKey, testCaseValue.description)
+	}
+
+	authH := newAuthHandler(t)
+	assert.ErrorIs(t, authH.SetSigningKey(nil), auth.ErrInvalidKey)
+}
+
+func TestPublicKeyCanNotBeUsedAsHMACSecret(t *testing.T) {
+	privatePEM, publicPEM := generateKeyPair(t, "RS256")
+	signingKey, _ := auth.NewSigningKeyFromPEM("RS256", privatePEM)
+
+	authH := newAuthHandler(t)
+	authH.SetSigningKey(signingKey)
+	logInNewUser(t, authH, "peter")
+	user, _ := authH.GetUserByUserName("peter")
+
+	// the public key is known to everybody, so an attacker could use it as secret
+	claims := map[string]interface{}{"UserName": "peter", "sub": user.ID, "jti": "SomeTokenID"}
+	forgedToken, _ := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.MapClaims(claims)).SignedString(publicPEM)
+	_, error := authH.AuthenticateByJWT(forgedToken)
+	assert.ErrorIs(t, error, auth.ErrUnexpectedSigningMethod)
+
+	// even if HS256 was allowed additionally
+	error = authH.SetAllowedAlgorithms("RS256", "HS256")
+	assert.Equal(t, nil, error)
+	_, error = authH.AuthenticateByJWT(forgedToken)
+	assert.ErrorIs(t, error, auth.ErrUnexpectedSigningMethod)
+
+	// the secret of an HMAC key is never handed out
+	hmacKey, _ := auth.NewHMACSigningKey("HS256", testSecret)
+	assert.Equal(t, nil, hmacKey.PublicKey())
+}
@@ -21,10 +21,9 @@ func TestSignUpReturnsFalseAndErrorMessageForEmptyInputValues(t *testing.T) {
 	}
 
 	for _, testCaseValue := range testCaseValues {
-		authH := auth.NewAuthHandler()
-		success, error := authH.SignUp(testCaseValue.username, testCaseValue.password)
-		assert.Equal(t, success, false)
-		assert.Equal(t, error.Error(), "Error : Please enter a valid username and password!")
+		authH := newAuthHandler(t)
+		_, error := authH.SignUp(testCaseValue.username, testCaseValue.password)
+		assert.ErrorIs(t, error, auth.ErrInvalidInput)
 	}
 }
 
@@ -39,10 +38,9 @@ func TestSignUpReturnsTrueAndNoErrorMessageForValidInputValues(t *testing.T) {
 	}
 
 	for _, testCaseValue := range testCaseValues {
-		authH := auth.NewAuthHandler()
-		success, error := authH.SignUp(testCaseValue.username, testCaseValue.password)
-		assert.Equal(t, success, true)
-		assert.Equal(t, error, nil)
+		authH := newAuthHandler(t)
+		_, error := authH.SignUp(testCaseValue.username, testCaseValue.password)
+		assert.Equal(t, nil, error)
 	}
 }
 
@@ -57,11 +55,12 @@ func TestSignUpCreatesNewUserInAuthHandler(t *testing.T) {
 	}
 
 	for _, testCaseValue := range testCaseValues {
-		authH := auth.NewAuthHandler()
-		success, error := authH.SignUp(testCaseValue.username, testCaseValue.password)
-		assert.Equal(t, success, true)
-		assert.Equal(t, error, nil)
+		authH := newAuthHandler(t)
+		createdUser, error := authH.SignUp(testCaseValue.username, testCaseValue.password)
+		assert.Equal(t, nil, error)
+		assert.Equal(t, testCaseValue.username, createdUser.UserName)
 		user, error := authH.GetUserByUserName(testCaseValue.username)
+		assert.Equal(t, createdUser.ID, user.ID)
 		assert.NotEqual(t, user, nil)
 		assert.Equal(t, error, nil)
 		assert.Equal(t, user.UserName, testCaseValue.username)
@@ -81,11 +80,10 @@ func TestSignUpDoesNotAllowTwoTimesTheSameUserName(t *testing.T) {
 	}
 
 	for _, testCaseValue := range testCaseValues {
-		authH := auth.NewAuthHandler()
+		authH := newAuthHandler(t)
 		// do first sign up (which is successful)
-		success, error := authH.SignUp(testCaseValue.username, testCaseValue.password)
-		assert.Equal(t, success, true)
-		assert.Equal(t, error, nil)
+		_, error := authH.SignUp(testCaseValue.username, testCaseValue.password)
+		assert.Equal(t, nil, error)
 		user, error := authH.GetUserByUserName(testCaseValue.username)
 		assert.NotEqual(t, user, nil)
 		assert.Equal(t, error, nil)
@@ -93,8 +91,7 @@ func TestSignUpDoesNotAllowTwoTimesTheSameUserName(t *testing.T) {
 		err := bcrypt.CompareHashAndPassword([]byte(user.HashedPassword), []byte(testCaseValue.password))
 		assert.Equal(t, err, nil)
 		// do second sign up (which fails)
-		success, error = authH.SignUp(testCaseValue.username, testCaseValue.password)
-		assert.Equal(t, success, false)
-		assert.Equal(t, error.Error(), "Error : Username '"+testCaseValue.username+"' already used. Please choose a different Username!")
+		_, error = authH.SignUp(testCaseValue.username, testCaseValue.password)
+		assert.ErrorIs(t, error, auth.ErrUsernameTaken)
 	}
 }
@@ -0,0 +1,62 @@
+package main
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/mezorian/go-auth-example/pkg/auth"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSQLRefreshTokenStoreMarksTokensUsedOnlyOnce(t *testing.T) {
+	db := openTestDatabase(t, filepath.Join(t.TempDir(), "users.db"))
+	store, error := auth.NewSQLRefreshTokenStore(db)
+	assert.Equal(t, nil, error)
+	now := newFakeClock().Now()
+
+	testCaseValues := []struct {
+		id       string
+		familyID string
+		hash     string
+	}{
+		{"1", "laptop", "hash 1"},
+		{"2", "laptop", "hash 2"},
+		{"3", "phone", "hash 3"},
+	}
+	for _, testCaseValue := range testCaseValues {
+		error = store.CreateRefreshToken(&auth.RefreshToken{ID: testCaseValue.id, FamilyID: testCaseValue.familyID, UserID: "peter",
+			TokenHash: testCaseValue.hash, IssuedAt: now, ExpiresAt: now.Add(time.Hour)})
+		assert.Equal(t, nil, error)
+	}
+	error = store.CreateRefreshToken(&auth.RefreshToken{ID: "4", TokenHash: "hash 1"})
+	assert.ErrorIs(t, error, auth.ErrInvalidInput)
+
+	// tokens are found by their hash
+	token, error := store.GetRefreshTokenByHash("hash 2")
+	assert.Equal(t, nil, error)
+	assert.Equal(t, "2", token.ID)
+	assert.Equal(t, true, token.UsedAt.IsZero())
+	_, error = store.GetRefreshTokenByHash("unknown hash")
+	assert.ErrorIs(t, error, auth.ErrInvalidToken)
+
+	// a token can only be used once
+	assert.Equal(t, nil, store.MarkRefreshTokenUsed("1", now))
+	assert.ErrorIs(t, store.MarkRefreshTokenUsed("1", now), auth.ErrRefreshTokenReused)
+	assert.ErrorIs(t, store.MarkRefreshTokenUsed("unknown", now), auth.ErrInvalidToken)
+	token, _ = store.GetRefreshTokenByHash("hash 1")
+	assert.Equal(t, true, token.UsedAt.Equal(now))
+
+	// revoking a family keeps the first revocation time
+	assert.Equal(t, nil, store.RevokeRefreshTokenFamily("laptop", now))
+	assert.Equal(t, nil, store.RevokeRefreshTokenFamily("laptop", now.Add(time.Minute)))
+	token, _ = store.GetRefreshTokenByHash("hash 2")
+	assert.Equal(t, true, token.RevokedAt.Equal(now))
+	token, _ = store.GetRefreshTokenByHash("hash 3")
+	assert.Equal(t, true, token.RevokedAt.IsZero())
+
+	// expired tokens are removed
+	assert.Equal(t, nil, store.DeleteExpiredRefreshTokens(now.Add(time.Hour+time.Second)))
+	_, error = store.GetRefreshTokenByHash("hash 3")
+	assert.ErrorIs(t, error, auth.ErrInvalidToken)
+}
@@ -0,0 +1,107 @@
+package main
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/mezorian/go-auth-example/pkg/auth"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSQLSessionStoreCreatesUpdatesAndDeletesSessions(t *testing.T) {
+	db := openTestDatabase(t, filepath.Join(t.TempDir(), "users.db"))
+	store, error := auth.NewSQLSessionStore(db)
+	assert.Equal(t, nil, error)
+	now := newFakeClock().Now()
+
+	testCaseValues := []struct {
+		id     string
+		userID string
+	}{
+		{"laptop", "peter"},
+		{"phone", "peter"},
+		{"tablet", "anna"},
+	}
+	for i, testCaseValue := range testCaseValues {
+		createdAt := now.Add(time.Duration(i) * time.Minute)
+		error = store.CreateSession(&auth.Session{ID: testCaseValue.id, UserID: testCaseValue.userID, CreatedAt: createdAt,
+			LastSeenAt: createdAt, ExpiresAt: createdAt.Add(time.Hour), UserAgent: "Firefox", IP: "127.0.0.1"})
+		assert.Equal(t, nil, error)
+	}
+	error = store.CreateSession(&auth.Session{ID: "laptop", UserID: "anna"})
+	assert.ErrorIs(t, error, auth.ErrInvalidInput)
+
+	// sessions are found by ID and by user
+	session, error := store.GetSession("laptop")
+	assert.Equal(t, nil, error)
+	assert.Equal(t, "peter", session.UserID)
+	assert.Equal(t, "Firefox", session.UserAgent)
+	assert.Equal(t, true, session.ExpiresAt.Equal(now.Add(time.Hour)))
+	sessions, error := store.ListSessionsByUserID("peter")
+	assert.Equal(t, nil, error)
+	assert.Equal(t, 2, len(sessions))
+	assert.Equal(t, "laptop", sessions[0].ID)
+
+	// LastSeenAt only moves forward
+	assert.Equal(t, nil, store.TouchSession("laptop", now.Add(time.Minute)))
+	assert.Equal(t, nil, store.TouchSession("laptop", now))
+	session, _ = store.GetSession("laptop")
+	assert.Equal(t, true, session.LastSeenAt.Equal(now.Add(time.Minute)))
+
+	// sessions can only be updated by their own user
+	session.IP = "10.0.0.1"
+	assert.Equal(t, nil, store.UpdateSession(session))
+	session.UserID = "anna"
+	assert.ErrorIs(t, store.UpdateSession(session), auth.ErrSessionNotFound)
+	session, _ = store.GetSession("laptop")
+	assert.Equal(t, "10.0.0.1", session.IP)
+
+	// delete single, expired and all sessions of a user
+	assert.Equal(t, nil, store.DeleteSession("laptop"))
+	assert.ErrorIs(t, store.DeleteSession("laptop"), auth.ErrSessionNotFound)
+	assert.ErrorIs(t, store.TouchSession("laptop", now), auth.ErrSessionNotFound)
+	_, error = store.GetSession("laptop")
+	assert.ErrorIs(t, error, auth.ErrSessionNotFound)
+
+	assert.Equal(t, nil, store.DeleteExpiredSessions(now.Add(time.Hour+time.Second)))
+	_, error = store.GetSession("phone")
+	assert.Equal(t, nil, error)
+	assert.Equal(t, nil, store.DeleteSessionsByUserID("peter"))
+	sessions, _ = store.ListSessionsByUserID("peter")
+	assert.Equal(t, 0, len(sessions))
+	_, error = store.GetSession("tablet")
+	assert.Equal(t, nil, error)
+}
+
+func TestSQLStoresKeepSessionsAfterRestart(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "users.db")
+	newAuthHandlerOnDatabase := func() *auth.AuthHandler {
+		db := openTestDatabase(t, path)
+		userStore, error := auth.NewSQLUserStore(db)
+		assert.Equal(t, nil, error)
+		sessionStore, error := auth.NewSQLSessionStore(db)
+		assert.Equal(t, nil, error)
+		refreshTokenStore, error := auth.NewSQLRefreshTokenStore(db)
+		assert.Equal(t, nil, error)
+
+		return newAuthHandler(t, auth.WithUserStore(userStore), auth.WithSessionStore(sessionStore), auth.WithRefreshTokenStore(refreshTokenStore))
+	}
+
+	// login with the first auth handler
+	result := logInNewUserWithResult(t, newAuthHandlerOnDatabase(), "peter")
+
+	// the second auth handler knows the session and its refresh token
+	authH := newAuthHandlerOnDatabase()
+	principal, error := authH.AuthenticateByJWT(result.AccessToken)
+	assert.Equal(t, nil, error)
+	assert.Equal(t, result.Session.ID, principal.Session.ID)
+	refreshed, error := authH.Refresh(result.RefreshToken)
+	assert.Equal(t, nil, error)
+	_, error = authH.Refresh(result.RefreshToken)
+	assert.ErrorIs(t, error, auth.ErrRefreshTokenReused)
+
+	// reusing the refresh token revoked the whole family
+	_, error = newAuthHandlerOnDatabase().Refresh(refreshed.RefreshToken)
+	assert.ErrorIs(t, error, auth.ErrInvalidToken)
+}
@@ -0,0 +1,185 @@
+package main
+
+import (
+	"database/sql"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/mezorian/go-auth-example/pkg/auth"
+	"github.com/stretchr/testify/assert"
+	_ "modernc.org/sqlite"
+)
+
+// open a new SQLite database file in a temporary directory
+func openTestDatabase(t *testing.T, path string) *sql.DB {
+	db, error := sql.Open("sqlite", "file:"+path+"?_pragma=busy_timeout(5000)")
+	assert.Equal(t, nil, error)
+	t.Cleanup(func() { db.Close() })
+
+	return db
+}
+
+func TestSQLUserStoreMigratesSchemaOnlyOnce(t *testing.T) {
+	db := openTestDatabase(t, filepath.Join(t.TempDir(), "users.db"))
+
+	_, error := auth.NewSQLUserStore(db)
+	assert.Equal(t, nil, error)
+
+	migrations, error := auth.Migrations()
+	assert.Equal(t, nil, error)
+	version, error := auth.SchemaVersion(db)
+	assert.Equal(t, nil, error)
+	assert.Equal(t, migrations[len(migrations)-1].Version, version)
+
+	// migrating an up to date database again changes nothing
+	error = auth.Migrate(db)
+	assert.Equal(t, nil, error)
+	version, error = auth.SchemaVersion(db)
+	assert.Equal(t, nil, error)
+	assert.Equal(t, migrations[len(migrations)-1].Version, version)
+}
+
+func TestSQLUserStoreCreatesUpdatesAndDeletesUsers(t *testing.T) {
+	db := openTestDatabase(t, filepath.Join(t.TempDir(), "users.db"))
+	store, error := auth.NewSQLUserStore(db)
+	assert.Equal(t, nil, error)
+
+	testCaseValues := []struct {
+		id       string
+		username string
+	}{
+		{"1", "peter"},
+		{"2", "anna"},
+		{"3", "admin"},
+	}
+
+	for _, testCaseValue := range testCaseValues {
+		error = store.CreateUser(&auth.User{ID: testCaseValue.id, UserName: testCaseValue.username, HashedPassword: "hash"})
+		assert.Equal(t, nil, error)
+	}
+
+	// find users by ID and by user name
+	for _, testCaseValue := range testCaseValues {
+		user, error := store.GetUserByID(testCaseValue.id)
+		assert.Equal(t, nil, error)
+		assert.Equal(t, testCaseValue.username, user.UserName)
+
+		user, error = store.GetUserByUserName(testCaseValue.username)
+		assert.Equal(t, nil, error)
+		assert.Equal(t, testCaseValue.id, user.ID)
+		assert.Equal(t, "hash", user.HashedPassword)
+	}
+
+	users, error := store.ListUsers()
+	assert.Equal(t, nil, error)
+	assert.Equal(t, 3, len(users))
+	assert.Equal(t, "admin", users[0].UserName)
+
+	// user names have to be unique
+	error = store.CreateUser(&auth.User{ID: "4", UserName: "peter"})
+	assert.Equal(t, "Error : Username 'peter' already used. Please choose a different Username!", error.Error())
+	error = store.UpdateUser(&auth.User{ID: "2", UserName: "peter"})
+	assert.Equal(t, "Error : Username 'peter' already used. Please choose a different Username!", error.Error())
+
+	// update user
+	error = store.UpdateUser(&auth.User{ID: "1", UserName: "peter", HashedPassword: "new hash"})
+	assert.Equal(t, nil, error)
+	user, _ := store.GetUserByID("1")
+	assert.Equal(t, "new hash", user.HashedPassword)
+
+	// delete user
+	error = store.DeleteUser("1")
+	assert.Equal(t, nil, error)
+	_, error = store.GetUserByUserName("peter")
+	assert.Equal(t, "Error : No user found for UserName : 'peter' !", error.Error())
+	error = store.DeleteUser("1")
+	assert.Equal(t, "Error : No user found for ID : '1' !", error.Error())
+	error = store.UpdateUser(&auth.User{ID: "1", UserName: "peter"})
+	assert.Equal(t, "Error : No user found for ID : '1' !", error.Error())
+}
+
+func TestSQLUserStoreOnlyUpdatesUnchangedUsers(t *testing.T) {
+	db := openTestDatabase(t, filepath.Join(t.TempDir(), "users.db"))
+	store, error := auth.NewSQLUserStore(db)
+	assert.Equal(t, nil, error)
+	store.CreateUser(&auth.User{ID: "1", UserName: "peter", HashedPassword: "hash", PasswordChangedAt: time.Now(), Email: "peter@example.com"})
+
+	// the update succeeds as long as the user was not changed in between
+	oldUser, _ := store.GetUserByID("1")
+	user, _ := store.GetUserByID("1")
+	user.Status = auth.StatusDisabled
+	assert.Equal(t, nil, store.CompareAndUpdateUser(oldUser, user))
+
+	user, _ = store.GetUserByID("1")
+	user.HashedPassword = "new hash"
+	assert.ErrorIs(t, store.CompareAndUpdateUser(oldUser, user), auth.ErrConflict)
+	storedUser, _ := store.GetUserByID("1")
+	assert.Equal(t, auth.StatusDisabled, storedUser.Status)
+	assert.Equal(t, "hash", storedUser.HashedPassword)
+
+	user.ID = "2"
+	assert.ErrorIs(t, store.CompareAndUpdateUser(user, user), auth.ErrUserNotFound)
+}
+
+func TestSQLUserStoreKeepsUsersAfterRestart(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "users.db")
+
+	// sign up user with first auth handler
+	db := openTestDatabase(t, path)
+	store, error := auth.NewSQLUserStore(db)
+	assert.Equal(t, nil, error)
+	authH := newAuthHandler(t, auth.WithUserStore(store))
+	_, error = authH.SignUp("peter", "supersecret")
+	assert.Equal(t, nil, error)
+	db.Close()
+
+	// login with second auth handler on the same database file
+	db = openTestDatabase(t, path)
+	store, error = auth.NewSQLUserStore(db)
+	assert.Equal(t, nil, error)
+	authH = newAuthHandler(t, auth.WithUserStore(store))
+	result, error := authH.LogIn("peter", "supersecret")
+	assert.Equal(t, nil, error)
+
+	// the generated access token belongs to the persisted user
+	user, _ := store.GetUserByUserName("peter")
+	principal, error := authH.AuthenticateByJWT(result.AccessToken)
+	assert.Equal(t, nil, error)
+	assert.Equal(t, user.ID, principal.User.ID)
+}
+
+func TestSQLUserStoreSignUpOfSameUserNameCanNotRace(t *testing.T) {
+	db := openTestDatabase(t, filepath.Join(t.TempDir(), "users.db"))
+	store, error := auth.NewSQLUserStore(db)
+	assert.Equal(t, nil, error)
+	authH := newAuthHandler(t, auth.WithUserStore(store))
+
+	// sign up the same user name from several goroutines at once
+	var waitGroup sync.WaitGroup
+	results := make(chan *auth.User, 10)
+	for i := 0; i < 10; i++ {
+		waitGroup.Add(1)
+		go func() {
+			defer waitGroup.Done()
+			user, _ := authH.SignUp("peter", "supersecret")
+			results <- user
+		}()
+	}
+	waitGroup.Wait()
+	close(results)
+
+	// exactly one sign up is successful
+	successfulSignUps := 0
+	for user := range results {
+		if user != nil {
+			successfulSignUps++
+		}
+	}
+	assert.Equal(t, 1, successfulSignUps)
+
+	users, error := store.ListUsers()
+	assert.Equal(t, nil, error)
+	assert.Equal(t, 1, len(users))
+}
@@ -0,0 +1,121 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/mezorian/go-auth-example/pkg/auth"
+	"github.com/stretchr/testify/assert"
+)
+
+// create a resource server which only holds the public key of the issuer
+func newResourceServer(t *testing.T, issuer *auth.AuthHandler, validationMode auth.ValidationMode) *auth.AuthHandler {
+	privatePEM, publicPEM := generateKeyPair(t, "ES256")
+	signingKey, error := auth.NewSigningKeyFromPEM("ES256", privatePEM)
+	assert.Equal(t, nil, error)
+	assert.Equal(t, nil, issuer.SetSigningKey(signingKey))
+
+	verificationKey, error := auth.NewVerificationKeyFromPEM("ES256", publicPEM)
+	assert.Equal(t, nil, error)
+	resourceServer, error := auth.NewAuthHandler(auth.WithSigningKey(verificationKey))
+	assert.Equal(t, nil, error)
+	resourceServer.SetValidationMode(validationMode)
+
+	return resourceServer
+}
+
+func TestStatelessValidationDoesNotNeedUsersAndSessions(t *testing.T) {
+	issuer := newAuthHandler(t)
+	resourceServer := newResourceServer(t, issuer, auth.StatelessValidation)
+	result := logInNewUserWithResult(t, issuer, "peter")
+
+	// the resource server neither knows the user nor the session
+	principal, error := resourceServer.AuthenticateByJWT(result.AccessToken)
+	assert.Equal(t, nil, error)
+	assert.Equal(t, result.User.ID, principal.User.ID)
+	assert.Equal(t, "peter", principal.User.UserName)
+	assert.Equal(t, (*auth.Session)(nil), principal.Session)
+	assert.Equal(t, result.Session.ID, principal.Claims.SessionID)
+
+	// the signature and the claims are still validated
+	testCaseValues := []string{
+		result.AccessToken + "x",
+		"RandomStringWhichIsNoRealJWT",
+		"",
+	}
+	for _, testCaseValue := range testCaseValues {
+		_, error = resourceServer.AuthenticateByJWT(testCaseValue)
+		assert.ErrorIs(t, error, auth.ErrInvalidToken)
+	}
+}
+
+func TestStatelessValidationHonorsRevocationList(t *testing.T) {
+	revocationStore := auth.NewInMemoryRevocationStore()
+	issuer := newAuthHandler(t)
+	issuer.SetRevocationStore(revocationStore)
+	resourceServer := newResourceServer(t, issuer, auth.StatelessValidation)
+	resourceServer.SetRevocationStore(revocationStore)
+
+	laptop := logInNewUserWithResult(t, issuer, "peter")
+	phone, _ := issuer.LogIn("peter", "supersecret")
+
+	error := issuer.LogOut(laptop.AccessToken)
+	assert.Equal(t, nil, error)
+	_, error = resourceServer.AuthenticateByJWT(laptop.AccessToken)
+	assert.ErrorIs(t, error, auth.ErrTokenRevoked)
+
+	// revoking a session without the revocation list is not noticed
+	error = issuer.RevokeSession(phone.Session.ID)
+	assert.Equal(t, nil, error)
+	_, error = resourceServer.AuthenticateByJWT(phone.AccessToken)
+	assert.Equal(t, nil, error)
+	_, error = issuer.AuthenticateByJWT(phone.AccessToken)
+	assert.ErrorIs(t, error, auth.ErrInvalidToken)
+}
+
+func TestStatelessValidationWithoutRevocationIgnoresRevocationList(t *testing.T) {
+	revocationStore := auth.NewInMemoryRevocationStore()
+	issuer := newAuthHandler(t)
+	issuer.SetRevocationStore(revocationStore)
+	resourceServer := newResourceServer(t, issuer, auth.StatelessValidationWithoutRevocation)
+	resourceServer.SetRevocationStore(revocationStore)
+
+	result := logInNewUserWithResult(t, issuer, "peter")
+	error := issuer.LogOut(result.AccessToken)
+	assert.Equal(t, nil, error)
+
+	// the token stays valid until it expires
+	principal, error := resourceServer.AuthenticateByJWT(result.AccessToken)
+	assert.Equal(t, nil, error)
+	assert.Equal(t, result.User.ID, principal.User.ID)
+
+	// switching back to stateful validation rejects it again
+	resourceServer.SetValidationMode(auth.StatefulValidation)
+	_, error = resourceServer.AuthenticateByJWT(result.AccessToken)
+	assert.ErrorIs(t, error, auth.ErrTokenRevoked)
+}
+
+func TestLogOutEndsSessionInEveryValidationMode(t *testing.T) {
+	validationModes := []auth.ValidationMode{auth.StatefulValidation, auth.StatelessValidation, auth.StatelessValidationWithoutRevocation}
+	for _, validationMode := range validationModes {
+		authH := newAuthHandler(t, auth.WithValidationMode(validationMode))
+		result := logInNewUserWithResult(t, authH, "peter")
+
+		error := authH.LogOut(result.AccessToken)
+		assert.Equal(t, nil, error)
+
+		// the session and its refresh tokens are gone
+		_, error = authH.Refresh(result.RefreshToken)
+		assert.ErrorIs(t, error, auth.ErrInvalidToken)
+		sessions, _ := authH.GetSessionStore().ListSessionsByUserID(result.User.ID)
+		assert.Equal(t, 0, len(sessions))
+
+		// only without revocation list the access token stays valid
+		_, error = authH.AuthenticateByJWT(result.AccessToken)
+		if validationMode == auth.StatelessValidationWithoutRevocation {
+			assert.Equal(t, nil, error)
+			assert.Equal(t, nil, authH.LogOut(result.AccessToken))
+		} else {
+			assert.ErrorIs(t, error, auth.ErrTokenRevoked)
+		}
+	}
+}
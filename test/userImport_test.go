@@ -0,0 +1,148 @@
+package main
+
+import (
+	"crypto/sha1"
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/hex"
+	"strings"
+	"testing"
+
+	"github.com/mezorian/go-auth-example/pkg/auth"
+	"github.com/stretchr/testify/assert"
+	"golang.org/x/crypto/bcrypt"
+	"golang.org/x/crypto/pbkdf2"
+)
+
+// hash the password like a legacy system which used salted SHA-256
+func legacySHA256Hash(salt string, password string) string {
+	digest := sha256.Sum256([]byte(salt + password))
+	return hex.EncodeToString(digest[:])
+}
+
+func TestImportedUsersCanLogInWithLegacyHashes(t *testing.T) {
+	bcryptHash, _ := bcrypt.GenerateFromPassword([]byte("bcryptpassword"), bcrypt.MinCost)
+	importedUsers := []auth.ImportedUser{
+		{ID: "legacy-1", UserName: "peter", Format: auth.HashFormatSHA256Salted, Hash: legacySHA256Hash("s4lt", "sha256password"), Salt: "s4lt"},
+		{UserName: "anna", Format: auth.HashFormatPBKDF2SHA1, Hash: hex.EncodeToString(pbkdf2.Key([]byte("sha1password"), []byte("salt1"), 1000, 20, sha1.New)), Salt: "salt1", Iterations: 1000},
+		{UserName: "paul", Format: auth.HashFormatPBKDF2SHA256, Hash: hex.EncodeToString(pbkdf2.Key([]byte("sha256password"), []byte("salt2"), 1000, 32, sha256.New)), Salt: "salt2", Iterations: 1000},
+		{UserName: "mary", Format: auth.HashFormatPBKDF2SHA512, Hash: hex.EncodeToString(pbkdf2.Key([]byte("sha512password"), []byte("salt3"), 1000, 64, sha512.New)), Salt: "salt3", Iterations: 1000},
+		{UserName: "john", Format: auth.HashFormatPHC, Hash: string(bcryptHash)},
+	}
+	passwords := []string{"sha256password", "sha1password", "sha256password", "sha512password", "bcryptpassword"}
+
+	authH := newAuthHandler(t, auth.WithPasswordHasher(newFastArgon2idHasher()))
+	report, error := authH.ImportUsers(importedUsers)
+	assert.Equal(t, nil, error)
+	assert.Equal(t, []string{"peter", "anna", "paul", "mary", "john"}, report.Imported)
+	assert.Equal(t, 0, len(report.Skipped))
+	assert.Equal(t, 0, len(report.Conflicting))
+
+	user, _ := authH.GetUserByUserName("peter")
+	assert.Equal(t, "legacy-1", user.ID)
+
+	for i, importedUser := range importedUsers {
+		// a wrong password is rejected by the legacy hash
+		_, error = authH.LogIn(importedUser.UserName, "wrongpassword")
+		assert.ErrorIs(t, error, auth.ErrInvalidCredentials, importedUser.UserName)
+
+		// the right one is accepted and the hash is upgraded
+		_, error = authH.LogIn(importedUser.UserName, passwords[i])
+		assert.Equal(t, nil, error, importedUser.UserName)
+		user, _ := authH.GetUserByUserName(importedUser.UserName)
+		assert.Equal(t, true, strings.HasPrefix(user.HashedPassword, "$argon2id$"), importedUser.UserName)
+
+		_, error = authH.LogIn(importedUser.UserName, passwords[i])
+		assert.Equal(t, nil, error, importedUser.UserName)
+	}
+}
+
+func TestImportReportListsSkippedAndConflictingUsers(t *testing.T) {
+	authH := newAuthHandler(t)
+	logInNewUser(t, authH, "peter")
+	validHash := legacySHA256Hash("salt", "supersecret")
+
+	report, error := authH.ImportUsers([]auth.ImportedUser{
+		{UserName: "peter", Format: auth.HashFormatSHA256Salted, Hash: validHash, Salt: "salt"},
+		{UserName: "anna", Format: auth.HashFormatSHA256Salted, Hash: validHash, Salt: "salt"},
+		{UserName: "anna", Format: auth.HashFormatSHA256Salted, Hash: validHash, Salt: "salt"},
+		{ID: "taken-id", UserName: "paul", Format: auth.HashFormatSHA256Salted, Hash: validHash, Salt: "salt"},
+		{ID: "taken-id", UserName: "mary", Format: auth.HashFormatSHA256Salted, Hash: validHash, Salt: "salt"},
+		{UserName: "", Format: auth.HashFormatSHA256Salted, Hash: validHash},
+		{UserName: "short", Format: auth.HashFormatSHA256Salted, Hash: "abcdef"},
+		{UserName: "nohex", Format: auth.HashFormatPBKDF2SHA256, Hash: "not hex", Iterations: 1000},
+		{UserName: "noiterations", Format: auth.HashFormatPBKDF2SHA256, Hash: validHash},
+		{UserName: "md5", Format: "md5", Hash: validHash},
+		{UserName: "unknownphc", Format: auth.HashFormatPHC, Hash: "$md5$abc"},
+		{UserName: "brokenphc", Format: auth.HashFormatPHC, Hash: "$argon2id$v=19$broken"},
+	})
+	assert.Equal(t, nil, error)
+	assert.Equal(t, []string{"anna", "paul"}, report.Imported)
+	assert.Equal(t, []string{"peter", "anna", "mary"}, report.Conflicting)
+
+	var skippedUserNames []string
+	for _, problem := range report.Skipped {
+		skippedUserNames = append(skippedUserNames, problem.UserName)
+		assert.NotEqual(t, "", problem.Reason)
+	}
+	assert.Equal(t, []string{"", "short", "nohex", "noiterations", "md5", "unknownphc", "brokenphc"}, skippedUserNames)
+
+	// the existing user keeps the password
+	_, error = authH.LogIn("peter", "supersecret")
+	assert.Equal(t, nil, error)
+}
+
+func TestImportedPendingUsersCanLogInAfterActivation(t *testing.T) {
+	validHash := legacySHA256Hash("salt", "supersecret")
+	authH := newAuthHandler(t)
+	report, error := authH.ImportUsers([]auth.ImportedUser{
+		{ID: "pending-1", UserName: "peter", Format: auth.HashFormatSHA256Salted, Hash: validHash, Salt: "salt", Status: auth.StatusPending},
+		{UserName: "anna", Format: auth.HashFormatSHA256Salted, Hash: validHash, Salt: "salt", Status: "unknown"},
+	})
+	assert.Equal(t, nil, error)
+	assert.Equal(t, []string{"peter"}, report.Imported)
+	assert.Equal(t, "anna", report.Skipped[0].UserName)
+
+	// pending users can not log in until they are activated
+	_, error = authH.LogIn("peter", "supersecret")
+	assert.ErrorIs(t, error, auth.ErrAccountPending)
+	assert.Equal(t, nil, authH.ActivateUser("pending-1"))
+	_, error = authH.LogIn("peter", "supersecret")
+	assert.Equal(t, nil, error)
+}
+
+func TestImportSkipsHashesWithUnsafeParameters(t *testing.T) {
+	key := "a2V5a2V5a2V5"
+	unsafeHashes := map[string]string{
+		"argon2-no-rounds":     "$argon2id$v=19$m=16,t=0,p=1$c2FsdHNhbHQ$" + key,
+		"argon2-no-threads":    "$argon2id$v=19$m=16,t=1,p=0$c2FsdHNhbHQ$" + key,
+		"argon2-little-memory": "$argon2id$v=19$m=8,t=1,p=2$c2FsdHNhbHQ$" + key,
+		"argon2-much-memory":   "$argon2id$v=19$m=4194304,t=1,p=1$c2FsdHNhbHQ$" + key,
+		"argon2-many-rounds":   "$argon2id$v=19$m=16,t=100000,p=1$c2FsdHNhbHQ$" + key,
+		"scrypt-much-memory":   "$scrypt$ln=30,r=8,p=1$c2FsdHNhbHQ$" + key,
+		"scrypt-no-blocks":     "$scrypt$ln=10,r=0,p=1$c2FsdHNhbHQ$" + key,
+		"scrypt-many-threads":  "$scrypt$ln=10,r=8,p=1000$c2FsdHNhbHQ$" + key,
+		"pbkdf2-many-rounds":   "$pbkdf2-sha256$i=1000000000$c2FsdHNhbHQ$" + key,
+		"pbkdf2-no-rounds":     "$pbkdf2-sha256$i=0$c2FsdHNhbHQ$" + key,
+	}
+
+	var importedUsers []auth.ImportedUser
+	for userName, hash := range unsafeHashes {
+		importedUsers = append(importedUsers, auth.ImportedUser{UserName: userName, Format: auth.HashFormatPHC, Hash: hash})
+	}
+	importedUsers = append(importedUsers, auth.ImportedUser{UserName: "legacy-many-rounds", Format: auth.HashFormatPBKDF2SHA256, Hash: legacySHA256Hash("salt", "pw"), Salt: "salt", Iterations: 1000000000})
+
+	authH := newAuthHandler(t)
+	report, error := authH.ImportUsers(importedUsers)
+	assert.Equal(t, nil, error)
+	assert.Equal(t, 0, len(report.Imported))
+	assert.Equal(t, len(importedUsers), len(report.Skipped))
+
+	// hashes which were written to the store directly are refused at login
+	// instead of crashing the process
+	for userName, hash := range unsafeHashes {
+		authH.GetUserStore().CreateUser(&auth.User{ID: userName, UserName: userName, HashedPassword: hash})
+		_, error = authH.LogIn(userName, "pw")
+		assert.ErrorIs(t, error, auth.ErrInvalidCredentials, userName)
+	}
+}
@@ -0,0 +1,180 @@
+package main
+
+import (
+	"net/http"
+	"path/filepath"
+	"testing"
+
+	"github.com/mezorian/go-auth-example/pkg/auth"
+	"github.com/stretchr/testify/assert"
+)
+
+// change the status of the user directly in the user store
+func setStatusInUserStore(t *testing.T, authH *auth.AuthHandler, userName string, status auth.UserStatus) {
+	user, error := authH.GetUserByUserName(userName)
+	assert.Equal(t, nil, error)
+	user.Status = status
+	assert.Equal(t, nil, authH.GetUserStore().UpdateUser(user))
+}
+
+func TestInactiveUsersCanNotLogIn(t *testing.T) {
+	testCaseValues := []struct {
+		status auth.UserStatus
+		kind   error
+	}{
+		{auth.StatusPending, auth.ErrAccountPending},
+		{auth.StatusLocked, auth.ErrAccountLocked},
+		{auth.StatusDisabled, auth.ErrAccountDisabled},
+		{auth.StatusDeleted, auth.ErrAccountDeleted},
+	}
+
+	for _, testCaseValue := range testCaseValues {
+		authH := newAuthHandler(t)
+		authH.SignUp("peter", "supersecret")
+		setStatusInUserStore(t, authH, "peter", testCaseValue.status)
+
+		_, error := authH.LogIn("peter", "supersecret")
+		assert.ErrorIs(t, error, testCaseValue.kind, testCaseValue.status)
+		assert.ErrorIs(t, error, auth.ErrAccountInactive, testCaseValue.status)
+		assert.Equal(t, http.StatusForbidden, auth.HTTPStatus(error))
+		var statusError *auth.AccountStatusError
+		assert.ErrorAs(t, error, &statusError)
+		assert.Equal(t, testCaseValue.status, statusError.Status)
+
+		// the status is not revealed without the right password
+		_, error = authH.LogIn("peter", "wrongpassword")
+		assert.ErrorIs(t, error, auth.ErrInvalidCredentials, testCaseValue.status)
+	}
+}
+
+func TestTokensOfInactiveUsersAreRefused(t *testing.T) {
+	authH := newAuthHandler(t)
+	result := logInNewUserWithResult(t, authH, "peter")
+	setStatusInUserStore(t, authH, "peter", auth.StatusDisabled)
+
+	_, error := authH.AuthenticateByJWT(result.AccessToken)
+	assert.ErrorIs(t, error, auth.ErrAccountDisabled)
+	_, error = authH.Refresh(result.RefreshToken)
+	assert.ErrorIs(t, error, auth.ErrAccountDisabled)
+
+	// users without status are active
+	setStatusInUserStore(t, authH, "peter", "")
+	_, error = authH.AuthenticateByJWT(result.AccessToken)
+	assert.Equal(t, nil, error)
+}
+
+func TestDeactivatingUserRevokesItsSessions(t *testing.T) {
+	deactivations := map[string]func(authH *auth.AuthHandler, userID string) error{
+		"lock":    (*auth.AuthHandler).LockUser,
+		"disable": (*auth.AuthHandler).DisableUser,
+		"delete":  (*auth.AuthHandler).SoftDeleteUser,
+	}
+
+	for name, deactivate := range deactivations {
+		authH := newAuthHandler(t)
+		result := logInNewUserWithResult(t, authH, "peter")
+		assert.Equal(t, nil, deactivate(authH, result.User.ID), name)
+
+		sessions, _ := authH.ListSessions(result.User.ID)
+		assert.Equal(t, 0, len(sessions), name)
+		_, error := authH.AuthenticateByJWT(result.AccessToken)
+		assert.NotEqual(t, nil, error, name)
+
+		// after the activation only new logins are valid
+		assert.Equal(t, nil, authH.ActivateUser(result.User.ID), name)
+		_, error = authH.Refresh(result.RefreshToken)
+		assert.NotEqual(t, nil, error, name)
+		_, error = authH.LogIn("peter", "supersecret")
+		assert.Equal(t, nil, error, name)
+	}
+}
+
+func TestOnlyAllowedStatusTransitionsArePossible(t *testing.T) {
+	testCaseValues := []struct {
+		from    auth.UserStatus
+		to      auth.UserStatus
+		allowed bool
+	}{
+		{auth.StatusActive, auth.StatusLocked, true},
+		{auth.StatusActive, auth.StatusDisabled, true},
+		{auth.StatusActive, auth.StatusDeleted, true},
+		{auth.StatusActive, auth.StatusPending, false},
+		{auth.StatusActive, auth.StatusActive, false},
+		{auth.StatusPending, auth.StatusActive, true},
+		{auth.StatusPending, auth.StatusLocked, false},
+		{auth.StatusLocked, auth.StatusActive, true},
+		{auth.StatusLocked, auth.StatusDisabled, true},
+		{auth.StatusDisabled, auth.StatusActive, true},
+		{auth.StatusDisabled, auth.StatusLocked, false},
+		{auth.StatusDeleted, auth.StatusActive, true},
+		{auth.StatusDeleted, auth.StatusDisabled, false},
+	}
+
+	for _, testCaseValue := range testCaseValues {
+		assert.Equal(t, testCaseValue.allowed, testCaseValue.from.CanTransitionTo(testCaseValue.to), testCaseValue)
+
+		authH := newAuthHandler(t)
+		user, _ := authH.SignUp("peter", "supersecret")
+		setStatusInUserStore(t, authH, "peter", testCaseValue.from)
+
+		error := authH.SetUserStatus(user.ID, testCaseValue.to)
+		user, _ = authH.GetUserByUserName("peter")
+		if testCaseValue.allowed {
+			assert.Equal(t, nil, error, testCaseValue)
+			assert.Equal(t, testCaseValue.to, user.Status)
+		} else {
+			assert.ErrorIs(t, error, auth.ErrInvalidStatusTransition, testCaseValue)
+			assert.ErrorIs(t, error, auth.ErrInvalidInput, testCaseValue)
+			assert.Equal(t, testCaseValue.from, user.Status)
+		}
+	}
+}
+
+func TestSetUserStatusRejectsUnknownStatusAndUser(t *testing.T) {
+	authH := newAuthHandler(t)
+	user, _ := authH.SignUp("peter", "supersecret")
+	assert.Equal(t, auth.StatusActive, user.Status)
+
+	assert.ErrorIs(t, authH.SetUserStatus(user.ID, "banned"), auth.ErrInvalidInput)
+	assert.ErrorIs(t, authH.DisableUser("unknown-id"), auth.ErrUserNotFound)
+}
+
+func TestSoftDeletedUserKeepsItsUserName(t *testing.T) {
+	authH := newAuthHandler(t)
+	user, _ := authH.SignUp("peter", "supersecret")
+	assert.Equal(t, nil, authH.SoftDeleteUser(user.ID))
+
+	_, error := authH.SignUp("peter", "supersecret")
+	assert.ErrorIs(t, error, auth.ErrUsernameTaken)
+
+	// and can be restored
+	assert.Equal(t, nil, authH.ActivateUser(user.ID))
+	_, error = authH.LogIn("peter", "supersecret")
+	assert.Equal(t, nil, error)
+}
+
+func TestSQLUserStoreKeepsStatus(t *testing.T) {
+	db := openTestDatabase(t, filepath.Join(t.TempDir(), "users.db"))
+
+	// users which existed before the status column are active
+	_, error := db.Exec(`CREATE TABLE schema_migrations (version INTEGER PRIMARY KEY, name TEXT NOT NULL);
+		INSERT INTO schema_migrations (version, name) VALUES (1, '0001_create_users'), (2, '0002_drop_access_token');
+		CREATE TABLE users (id TEXT PRIMARY KEY, user_name TEXT NOT NULL, hashed_password TEXT NOT NULL);
+		CREATE UNIQUE INDEX users_user_name_idx ON users (user_name);
+		INSERT INTO users (id, user_name, hashed_password) VALUES ('1', 'peter', 'hash');`)
+	assert.Equal(t, nil, error)
+	store, error := auth.NewSQLUserStore(db)
+	assert.Equal(t, nil, error)
+	user, error := store.GetUserByID("1")
+	assert.Equal(t, nil, error)
+	assert.Equal(t, auth.StatusActive, user.Status)
+
+	authH := newAuthHandler(t, auth.WithUserStore(store))
+	assert.Equal(t, nil, authH.DisableUser("1"))
+	user, _ = store.GetUserByUserName("peter")
+	assert.Equal(t, auth.StatusDisabled, user.Status)
+
+	assert.Equal(t, nil, store.CreateUser(&auth.User{ID: "2", UserName: "anna", HashedPassword: "hash", Status: auth.StatusPending}))
+	users, _ := store.ListUsers()
+	assert.Equal(t, auth.StatusPending, users[0].Status)
+}
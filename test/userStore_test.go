@@ -0,0 +1,147 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/mezorian/go-auth-example/pkg/auth"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestInMemoryUserStoreCreatesAndFindsUsers(t *testing.T) {
+	testCaseValues := []struct {
+		id       string
+		username string
+	}{
+		{"1", "admin"},
+		{"2", "peter"},
+		{"3", "anna"},
+	}
+	store := auth.NewInMemoryUserStore()
+
+	for _, testCaseValue := range testCaseValues {
+		error := store.CreateUser(&auth.User{ID: testCaseValue.id, UserName: testCaseValue.username})
+		assert.Equal(t, nil, error)
+	}
+
+	for _, testCaseValue := range testCaseValues {
+		user, error := store.GetUserByID(testCaseValue.id)
+		assert.Equal(t, nil, error)
+		assert.Equal(t, testCaseValue.username, user.UserName)
+
+		user, error = store.GetUserByUserName(testCaseValue.username)
+		assert.Equal(t, nil, error)
+		assert.Equal(t, testCaseValue.id, user.ID)
+	}
+
+	users, error := store.ListUsers()
+	assert.Equal(t, nil, error)
+	assert.Equal(t, 3, len(users))
+	assert.Equal(t, "admin", users[0].UserName)
+	assert.Equal(t, "anna", users[1].UserName)
+	assert.Equal(t, "peter", users[2].UserName)
+}
+
+func TestInMemoryUserStoreDoesNotAllowTwoTimesTheSameUserName(t *testing.T) {
+	store := auth.NewInMemoryUserStore()
+
+	error := store.CreateUser(&auth.User{ID: "1", UserName: "peter"})
+	assert.Equal(t, nil, error)
+
+	error = store.CreateUser(&auth.User{ID: "2", UserName: "peter"})
+	assert.Equal(t, "Error : Username 'peter' already used. Please choose a different Username!", error.Error())
+
+	// renaming another user to an already used user name fails as well
+	error = store.CreateUser(&auth.User{ID: "3", UserName: "anna"})
+	assert.Equal(t, nil, error)
+	error = store.UpdateUser(&auth.User{ID: "3", UserName: "peter"})
+	assert.Equal(t, "Error : Username 'peter' already used. Please choose a different Username!", error.Error())
+}
+
+func TestInMemoryUserStoreUpdatesAndDeletesUsers(t *testing.T) {
+	store := auth.NewInMemoryUserStore()
+
+	error := store.CreateUser(&auth.User{ID: "1", UserName: "peter"})
+	assert.Equal(t, nil, error)
+
+	// rename user
+	error = store.UpdateUser(&auth.User{ID: "1", UserName: "peter2"})
+	assert.Equal(t, nil, error)
+	_, error = store.GetUserByUserName("peter")
+	assert.Equal(t, "Error : No user found for UserName : 'peter' !", error.Error())
+	user, error := store.GetUserByUserName("peter2")
+	assert.Equal(t, nil, error)
+	assert.Equal(t, "1", user.ID)
+
+	// delete user
+	error = store.DeleteUser("1")
+	assert.Equal(t, nil, error)
+	_, error = store.GetUserByID("1")
+	assert.Equal(t, "Error : No user found for ID : '1' !", error.Error())
+	error = store.DeleteUser("1")
+	assert.Equal(t, "Error : No user found for ID : '1' !", error.Error())
+
+	// updating a deleted user fails
+	error = store.UpdateUser(&auth.User{ID: "1", UserName: "peter"})
+	assert.Equal(t, "Error : No user found for ID : '1' !", error.Error())
+}
+
+func TestInMemoryUserStoreReturnsCopiesOfUsers(t *testing.T) {
+	store := auth.NewInMemoryUserStore()
+	user := &auth.User{ID: "1", UserName: "peter", HashedPassword: "hash"}
+
+	error := store.CreateUser(user)
+	assert.Equal(t, nil, error)
+
+	// changing the struct which was handed in does not change the store
+	user.HashedPassword = "changed"
+	storedUser, _ := store.GetUserByID("1")
+	assert.Equal(t, "hash", storedUser.HashedPassword)
+
+	// changing the struct which was returned does not change the store
+	storedUser.HashedPassword = "changed"
+	storedUser, _ = store.GetUserByID("1")
+	assert.Equal(t, "hash", storedUser.HashedPassword)
+}
+
+func TestInMemoryUserStoreOnlyUpdatesUnchangedUsers(t *testing.T) {
+	store := auth.NewInMemoryUserStore()
+	store.CreateUser(&auth.User{ID: "1", UserName: "peter", HashedPassword: "hash"})
+
+	// the update succeeds as long as the user was not changed in between
+	oldUser, _ := store.GetUserByID("1")
+	user, _ := store.GetUserByID("1")
+	user.Status = auth.StatusDisabled
+	assert.Equal(t, nil, store.CompareAndUpdateUser(oldUser, user))
+
+	user, _ = store.GetUserByID("1")
+	user.HashedPassword = "new hash"
+	assert.ErrorIs(t, store.CompareAndUpdateUser(oldUser, user), auth.ErrConflict)
+	storedUser, _ := store.GetUserByID("1")
+	assert.Equal(t, auth.StatusDisabled, storedUser.Status)
+	assert.Equal(t, "hash", storedUser.HashedPassword)
+
+	user.ID = "2"
+	assert.ErrorIs(t, store.CompareAndUpdateUser(user, user), auth.ErrUserNotFound)
+}
+
+func TestAuthHandlerUsesGivenUserStore(t *testing.T) {
+	store := auth.NewInMemoryUserStore()
+	authH := newAuthHandler(t, auth.WithUserStore(store))
+
+	// sign up and login via auth handler
+	_, error := authH.SignUp("peter", "supersecret")
+	assert.Equal(t, nil, error)
+	result, error := authH.LogIn("peter", "supersecret")
+	assert.Equal(t, nil, error)
+
+	// user of the session can be found in the store
+	user, error := store.GetUserByUserName("peter")
+	assert.Equal(t, nil, error)
+	assert.Equal(t, user.ID, result.Session.UserID)
+
+	// users created directly in the store are known to the auth handler
+	error = store.CreateUser(&auth.User{ID: "2", UserName: "anna"})
+	assert.Equal(t, nil, error)
+	_, error = authH.SignUp("anna", "password")
+	assert.Equal(t, "Error : Username 'anna' already used. Please choose a different Username!", error.Error())
+}